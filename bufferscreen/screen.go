@@ -0,0 +1,114 @@
+// Package bufferscreen wraps a Displayer to assemble a complete frame in RAM
+// before sending it to the display. This is useful on devices where many small
+// updates are much slower than a single big update (for example, when the whole
+// frame can be sent at once using DMA).
+package bufferscreen
+
+import (
+	"errors"
+	"image/color"
+
+	"github.com/aykevl/tilegraphics"
+)
+
+var (
+	// ErrBufferSizeMismatch is returned when the size of the buffer passed to
+	// FillRectangleWithBuffer doesn't match the to-be-updated area.
+	ErrBufferSizeMismatch = errors.New("bufferscreen: buffer size did not match width*height")
+)
+
+// Screen stores all updates in a framebuffer and only sends the complete frame
+// to the wrapped display on Display. It needs width*height*4 bytes of memory.
+type Screen struct {
+	display       tilegraphics.Displayer
+	width, height int16
+
+	// frame is the framebuffer, in row major order.
+	frame []color.RGBA
+
+	// dirty is true when the frame has been changed since the last call to
+	// Display.
+	dirty bool
+}
+
+// NewScreen returns a new screen that buffers all updates before sending them
+// to the wrapped display. The wrapped display must not change in size.
+func NewScreen(display tilegraphics.Displayer) *Screen {
+	width, height := display.Size()
+	return &Screen{
+		display: display,
+		width:   width,
+		height:  height,
+		frame:   make([]color.RGBA, int(width)*int(height)),
+	}
+}
+
+// Size returns the size of the wrapped display.
+func (s *Screen) Size() (int16, int16) {
+	return s.width, s.height
+}
+
+// Display sends the whole frame to the wrapped display in a single update, if
+// anything changed since the last call to Display.
+func (s *Screen) Display() error {
+	if s.dirty {
+		err := s.display.FillRectangleWithBuffer(0, 0, s.width, s.height, s.frame)
+		if err != nil {
+			return err
+		}
+		s.dirty = false
+	}
+	return s.display.Display()
+}
+
+// FillRectangle fills the given rectangle in the framebuffer with the given
+// color.
+func (s *Screen) FillRectangle(x, y, width, height int16, c color.RGBA) error {
+	x1, y1, x2, y2 := s.clip(x, y, width, height)
+	for pixelY := y1; pixelY < y2; pixelY++ {
+		row := s.frame[int(pixelY)*int(s.width):]
+		for pixelX := x1; pixelX < x2; pixelX++ {
+			row[pixelX] = c
+		}
+	}
+	s.dirty = true
+	return nil
+}
+
+// FillRectangleWithBuffer copies the given buffer into the framebuffer. The
+// buffer must be in row major order.
+func (s *Screen) FillRectangleWithBuffer(x, y, width, height int16, buffer []color.RGBA) error {
+	if width < 0 || height < 0 || len(buffer) != int(width)*int(height) {
+		return ErrBufferSizeMismatch
+	}
+	x1, y1, x2, y2 := s.clip(x, y, width, height)
+	if x1 >= x2 {
+		// Nothing to copy.
+		return nil
+	}
+	for pixelY := y1; pixelY < y2; pixelY++ {
+		src := buffer[int(pixelY-y)*int(width)+int(x1-x):]
+		dst := s.frame[int(pixelY)*int(s.width)+int(x1):]
+		copy(dst[:x2-x1], src)
+	}
+	s.dirty = true
+	return nil
+}
+
+// clip returns the part of the given rectangle that lies within the screen.
+func (s *Screen) clip(x, y, width, height int16) (x1, y1, x2, y2 int16) {
+	x1, y1, x2, y2 = x, y, x+width, y+height
+	if x1 < 0 {
+		x1 = 0
+	}
+	if y1 < 0 {
+		y1 = 0
+	}
+	if x2 > s.width {
+		x2 = s.width
+	}
+	if y2 > s.height {
+		y2 = s.height
+	}
+	return
+}
@@ -0,0 +1,115 @@
+package bufferscreen
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/aykevl/tilegraphics"
+	"github.com/aykevl/tilegraphics/imagescreen"
+)
+
+var _ tilegraphics.Displayer = (*Screen)(nil)
+
+// countingScreen counts the number of updates sent to the underlying screen.
+type countingScreen struct {
+	*imagescreen.Screen
+	calls    int
+	displays int
+}
+
+func (s *countingScreen) Display() error {
+	s.displays++
+	return s.Screen.Display()
+}
+
+func (s *countingScreen) FillRectangle(x, y, width, height int16, c color.RGBA) error {
+	s.calls++
+	return s.Screen.FillRectangle(x, y, width, height, c)
+}
+
+func (s *countingScreen) FillRectangleWithBuffer(x, y, width, height int16, buffer []color.RGBA) error {
+	s.calls++
+	return s.Screen.FillRectangleWithBuffer(x, y, width, height, buffer)
+}
+
+// drawUpdates sends a few (partially overlapping and out of bounds) updates to
+// the given display.
+func drawUpdates(display tilegraphics.Displayer) {
+	buffer := make([]color.RGBA, 7*5)
+	for i := range buffer {
+		buffer[i] = color.RGBA{uint8(i * 7), 0, 255 - uint8(i*7), 255}
+	}
+	display.FillRectangle(0, 0, 30, 20, color.RGBA{0, 0, 0, 255})
+	display.FillRectangle(3, 4, 10, 5, color.RGBA{255, 0, 0, 255})
+	display.FillRectangleWithBuffer(8, 6, 7, 5, buffer)
+	display.FillRectangleWithBuffer(-3, -2, 7, 5, buffer)
+	display.FillRectangleWithBuffer(26, 17, 7, 5, buffer)
+	display.FillRectangle(25, -5, 10, 10, color.RGBA{0, 255, 0, 255})
+}
+
+// Check that a buffered frame ends up the same as when writing all updates
+// directly, and that it is sent in a single update.
+func TestBuffer(t *testing.T) {
+	reference := imagescreen.NewScreen(30, 20)
+	drawUpdates(reference)
+
+	display := &countingScreen{Screen: imagescreen.NewScreen(30, 20)}
+	screen := NewScreen(display)
+	if width, height := screen.Size(); width != 30 || height != 20 {
+		t.Errorf("unexpected size %dx%d", width, height)
+	}
+	drawUpdates(screen)
+	if display.calls != 0 {
+		t.Errorf("expected no updates before Display, got %d", display.calls)
+	}
+	if err := screen.Display(); err != nil {
+		t.Fatal("could not display:", err)
+	}
+	if display.calls != 1 || display.displays != 1 {
+		t.Errorf("expected a single update, got %d updates and %d calls to Display", display.calls, display.displays)
+	}
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 30; x++ {
+			if c1, c2 := display.RGBAAt(x, y), reference.RGBAAt(x, y); c1 != c2 {
+				t.Fatalf("pixel mismatch at X=%d Y=%d: expected %v, got %v", x, y, c2, c1)
+			}
+		}
+	}
+
+	// Nothing changed, so nothing should be sent.
+	screen.Display()
+	if display.calls != 1 {
+		t.Errorf("expected no update for an unchanged frame, got %d updates", display.calls)
+	}
+
+	if err := screen.FillRectangleWithBuffer(0, 0, 2, 2, make([]color.RGBA, 3)); err != ErrBufferSizeMismatch {
+		t.Errorf("expected ErrBufferSizeMismatch, got %v", err)
+	}
+}
+
+// Compare the number of updates sent to the display when rendering a frame
+// with and without buffering.
+func BenchmarkDisplay(b *testing.B) {
+	for _, buffered := range []bool{false, true} {
+		name := "direct"
+		if buffered {
+			name = "buffered"
+		}
+		b.Run(name, func(b *testing.B) {
+			display := &countingScreen{Screen: imagescreen.NewScreen(160, 128)}
+			var screen tilegraphics.Displayer = display
+			if buffered {
+				screen = NewScreen(display)
+			}
+			engine := tilegraphics.NewEngine(screen)
+			rect := engine.NewRectangle(0, 0, 40, 30, color.RGBA{255, 0, 0, 255})
+			engine.NewCircle(80, 64, 40, color.RGBA{0, 0, 127, 127})
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				rect.Move(int16(i%120), 20, 40, 30)
+				engine.Display()
+			}
+			b.ReportMetric(float64(display.calls)/float64(b.N), "updates/op")
+		})
+	}
+}
@@ -4,12 +4,23 @@ import (
 	"image/color"
 )
 
-// Blend takes a fully opaque background color and a foreground color that may
-// be semi-transparent and blends them together.
+// Blend takes a background color and a foreground color that may be
+// semi-transparent and blends them together, by drawing the foreground over
+// the background. Both colors must be premultiplied in linear color space, see
+// ConvertColor. Other colors (where a color component is too big for the alpha
+// value) result in colors that are too bright. It implements the Porter-Duff
+// "over" operator in linear color space, including the alpha channel: the
+// result is fully opaque when the background is fully opaque. Otherwise, it is
+// the premultiplied color of both combined, so that blending it over another
+// color has the same result as blending the background and foreground over
+// that color one after another. This makes it possible to composite nested
+// transparent layers first and blend the result over the screen later.
 //
-// Color blending uses a gamma of 2.0, which is close to the commonly used gamma
-// of ~2.2 but is much easier to calculate efficiently. It is slightly off the
-// ideal gamma curve, but in practice it looks almost identical.
+// Color blending uses a gamma of 2.0 by default, which is close to the commonly
+// used gamma of ~2.2 but is much easier to calculate efficiently. It is
+// slightly off the ideal gamma curve, but in practice it looks almost
+// identical. Use SetGammaMode to select the more accurate (but slower) gamma
+// of 2.2.
 //
 // For more information on why blending isn't trivial in sRGB color space:
 // https://www.youtube.com/watch?v=LKnqECcg6Gw
@@ -20,7 +31,7 @@ func Blend(bottom, top color.RGBA) color.RGBA {
 		R: encodeGamma((decodeGamma(bottom.R)*uint32(255-top.A))/255 + decodeGamma(top.R)),
 		G: encodeGamma((decodeGamma(bottom.G)*uint32(255-top.A))/255 + decodeGamma(top.G)),
 		B: encodeGamma((decodeGamma(bottom.B)*uint32(255-top.A))/255 + decodeGamma(top.B)),
-		A: 255,
+		A: uint8(uint32(top.A) + uint32(bottom.A)*uint32(255-top.A)/255),
 	}
 }
 
@@ -36,9 +47,119 @@ func ApplyAlpha(c color.RGBA, alpha uint8) color.RGBA {
 	}
 }
 
+// BlendBuffer blends every color in src over the color at the same index in
+// dst, storing the result in dst. It is a thin wrapper that calls Blend for
+// every pixel (which remains the only implementation of the blending math), but
+// it is faster for a whole row of pixels at once as it skips opaque pixels. The
+// src slice must be at least as long as dst.
+func BlendBuffer(dst, src []color.RGBA) {
+	src = src[:len(dst)]
+	for i, top := range src {
+		if top.A == 255 {
+			// Fast path: the result is the top color itself.
+			dst[i] = top
+			continue
+		}
+		dst[i] = Blend(dst[i], top)
+	}
+}
+
+// ApplyAlphaBuffer applies the given alpha to every color in the buffer, in
+// place. It is a thin wrapper that calls ApplyAlpha for every pixel.
+func ApplyAlphaBuffer(buf []color.RGBA, alpha uint8) {
+	for i, c := range buf {
+		buf[i] = ApplyAlpha(c, alpha)
+	}
+}
+
+// Lerp interpolates between colors a and b, where t=0 results in a and t=255
+// results in b. The interpolation (including the alpha channel) is done in
+// linear color space, like Blend. Neither color needs to be opaque. This is
+// useful for animating between two colors, for example.
+func Lerp(a, b color.RGBA, t uint8) color.RGBA {
+	ta := uint32(255 - t)
+	tb := uint32(t)
+	return color.RGBA{
+		R: encodeGamma((decodeGamma(a.R)*ta + decodeGamma(b.R)*tb) / 255),
+		G: encodeGamma((decodeGamma(a.G)*ta + decodeGamma(b.G)*tb) / 255),
+		B: encodeGamma((decodeGamma(a.B)*ta + decodeGamma(b.B)*tb) / 255),
+		A: uint8((uint32(a.A)*ta + uint32(b.A)*tb) / 255),
+	}
+}
+
+// premultiply converts a color with straight (non-premultiplied) alpha to a
+// color with the alpha premultiplied in linear color space, as expected by
+// Blend.
+func premultiply(r, g, b, a uint8) color.RGBA {
+	if a == 255 {
+		return color.RGBA{r, g, b, a}
+	}
+	return color.RGBA{
+		R: encodeGamma(decodeGamma(r) * uint32(a) / 255),
+		G: encodeGamma(decodeGamma(g) * uint32(a) / 255),
+		B: encodeGamma(decodeGamma(b) * uint32(a) / 255),
+		A: a,
+	}
+}
+
+// Premultiply converts a color with straight (non-premultiplied) alpha, such as
+// a pixel from a PNG image, to a color with the alpha premultiplied in linear
+// color space as used everywhere in this package. Fully transparent colors are
+// all converted to transparent black.
+func Premultiply(c color.RGBA) color.RGBA {
+	return premultiply(c.R, c.G, c.B, c.A)
+}
+
+// Unpremultiply is the reverse of Premultiply: it converts a color that is
+// premultiplied in linear color space back to straight alpha. This loses
+// precision for very transparent colors. Transparent black is returned for
+// fully transparent colors, as their original color cannot be recovered.
+func Unpremultiply(c color.RGBA) color.RGBA {
+	switch c.A {
+	case 0:
+		return color.RGBA{}
+	case 255:
+		return c
+	}
+	return color.RGBA{
+		R: encodeGamma(decodeGamma(c.R) * 255 / uint32(c.A)),
+		G: encodeGamma(decodeGamma(c.G) * 255 / uint32(c.A)),
+		B: encodeGamma(decodeGamma(c.B) * 255 / uint32(c.A)),
+		A: c.A,
+	}
+}
+
+// ConvertColor converts an arbitrary color (such as color.Gray, color.NRGBA or
+// a color from a palette) to the color.RGBA representation used in this
+// package, so that it can be passed to any of the constructors. A color.RGBA is
+// returned as-is. Other colors are converted taking gamma into account: colors
+// with straight alpha (like color.NRGBA) are premultiplied in linear color
+// space, as expected by Blend.
+func ConvertColor(c color.Color) color.RGBA {
+	switch c := c.(type) {
+	case color.RGBA:
+		return c
+	case color.NRGBA:
+		return premultiply(c.R, c.G, c.B, c.A)
+	}
+
+	// The colors returned by the RGBA method are premultiplied in gamma
+	// encoded color space. Undo that premultiplication, and premultiply again
+	// in linear color space.
+	r, g, b, a := c.RGBA()
+	if a == 0 {
+		return color.RGBA{}
+	}
+	return premultiply(uint8(r*0xffff/a>>8), uint8(g*0xffff/a>>8), uint8(b*0xffff/a>>8), uint8(a>>8))
+}
+
 // decodeGamma decodes a single 8-bit gamma-encoded (compressed) value to a
 // mostly linear color intensity.
 func decodeGamma(component uint8) uint32 {
+	if gammaMode == GammaAccurate {
+		return decodeGammaAccurate(component)
+	}
+
 	// This is the correct decoding formula:
 	//     return math.Pow(float64(component)/255, 2.2)
 	// However, pow is slow. So alternatively, there is this:
@@ -51,6 +172,18 @@ func decodeGamma(component uint8) uint32 {
 // encodeGamma converts a linear color intensity to an 8-bit gamma-encoded
 // (compressed) form.
 func encodeGamma(x uint32) uint8 {
+	switch gammaMode {
+	case GammaAccurate:
+		return encodeGammaAccurate(x)
+	case GammaFastTable:
+		return encodeGammaTable(x)
+	}
+	return encodeGammaFast(x)
+}
+
+// encodeGammaFast is the gamma-2.0 encoding of GammaFast: an approximation of
+// the integer square root.
+func encodeGammaFast(x uint32) uint8 {
 	// This is the correct encoding formula:
 	//     return uint8(math.Pow(component, 1/2.2) * 255)
 	// The following might be a little bit faster, and matches DecodeGamma:
@@ -66,6 +199,11 @@ func encodeGamma(x uint32) uint8 {
 		// avoid division by 0
 		return 0
 	}
+	if x >= 255*255 {
+		// Saturate instead of overflowing, which can happen when blending
+		// colors that are not correctly premultiplied.
+		return 255
+	}
 
 	// The starting value 32 results in ~8% faster code than most other starting
 	// values. Note that this value has been selected because it roundtrips
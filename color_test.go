@@ -40,17 +40,188 @@ func TestBlend(t *testing.T) {
 }
 
 // TestGamma checks whether all values decoded with decodeGamma are encoded to
-// the same value with encodeGamma.
+// the same value with encodeGamma, in all gamma modes.
 func TestGamma(t *testing.T) {
-	for n := 0; n <= 255; n++ {
-		linear := decodeGamma(uint8(n))
-		n2 := encodeGamma(linear)
-		if n2 != uint8(n) {
-			t.Errorf("gamma conversion roundtrip failed for: %d -> %d -> %d", n, linear, n2)
+	defer SetGammaMode(GammaFast)
+	for _, mode := range []GammaMode{GammaFast, GammaAccurate, GammaFastTable} {
+		SetGammaMode(mode)
+		for n := 0; n <= 255; n++ {
+			linear := decodeGamma(uint8(n))
+			n2 := encodeGamma(linear)
+			if n2 != uint8(n) {
+				t.Errorf("gamma mode %d: gamma conversion roundtrip failed for: %d -> %d -> %d", mode, n, linear, n2)
+			}
 		}
 	}
 }
 
+// TestGammaTable checks that the lookup tables of GammaFastTable give exactly
+// the same results as the square root approximation of GammaFast, for all
+// linear values (including those that need saturating).
+func TestGammaTable(t *testing.T) {
+	for x := uint32(0); x <= 256*256; x++ {
+		if expected, result := encodeGammaFast(x), encodeGammaTable(x); result != expected {
+			t.Errorf("encoding %d: expected %d, got %d", x, expected, result)
+		}
+	}
+}
+
+// TestBlendAccurate compares blending in the accurate gamma mode against the
+// floating point reference implementation.
+func TestBlendAccurate(t *testing.T) {
+	SetGammaMode(GammaAccurate)
+	defer SetGammaMode(GammaFast)
+
+	bottoms := []color.RGBA{
+		{0, 0, 0, 255},
+		{255, 255, 255, 255},
+		{255, 0, 0, 255},
+		{30, 200, 100, 255},
+	}
+	for _, bottom := range bottoms {
+		for a := 0; a <= 255; a += 5 {
+			for _, c := range []uint8{0, 1, 50, 200, 255} {
+				// Construct a valid (premultiplied) top color.
+				top := color.RGBA{uint8(a * int(c) / 255), uint8(a), 0, uint8(a)}
+				expected := blendFloat(bottom, top)
+				result := Blend(bottom, top)
+				if !closeColor(expected, result, 1) {
+					t.Errorf("Blend(%v, %v): expected %v, got %v", bottom, top, expected, result)
+				}
+			}
+		}
+	}
+}
+
+// TestBlendTransparent checks that blending over a semi-transparent background
+// results in the correct alpha and premultiplied color, by comparing against
+// the Porter-Duff "over" operator in linear color space. This is what makes it
+// possible to composite transparent layers before blending them over the
+// screen.
+func TestBlendTransparent(t *testing.T) {
+	SetGammaMode(GammaAccurate)
+	defer SetGammaMode(GammaFast)
+
+	bottoms := []color.RGBA{
+		{0, 0, 0, 0},
+		{128, 0, 0, 128},
+		{200, 100, 50, 200},
+		{20, 20, 20, 40},
+	}
+	for _, bottom := range bottoms {
+		for a := 0; a <= 255; a += 5 {
+			for _, c := range []uint8{0, 1, 50, 200, 255} {
+				top := color.RGBA{0, uint8(a * int(c) / 255), uint8(a), uint8(a)}
+				expected := overFloat(bottom, top)
+				result := Blend(bottom, top)
+				if !closeColor(expected, result, 1) {
+					t.Errorf("Blend(%v, %v): expected %v, got %v", bottom, top, expected, result)
+				}
+			}
+		}
+	}
+}
+
+// TestLerp compares color interpolation in the accurate gamma mode against the
+// floating point reference implementation.
+func TestLerp(t *testing.T) {
+	SetGammaMode(GammaAccurate)
+	defer SetGammaMode(GammaFast)
+
+	pairs := [][2]color.RGBA{
+		{{0, 0, 0, 255}, {255, 255, 255, 255}},
+		{{255, 0, 0, 255}, {0, 0, 255, 255}},
+		{{30, 200, 100, 255}, {0, 50, 0, 100}},
+		{{0, 0, 0, 0}, {127, 127, 0, 127}},
+	}
+	for _, pair := range pairs {
+		a, b := pair[0], pair[1]
+		if c := Lerp(a, b, 0); c != a {
+			t.Errorf("Lerp(%v, %v, 0): expected %v, got %v", a, b, a, c)
+		}
+		if c := Lerp(a, b, 255); c != b {
+			t.Errorf("Lerp(%v, %v, 255): expected %v, got %v", a, b, b, c)
+		}
+		for _, fraction := range []uint8{1, 32, 64, 127, 128, 200, 254} {
+			expected := lerpFloat(a, b, fraction)
+			result := Lerp(a, b, fraction)
+			if !closeColor(expected, result, 1) {
+				t.Errorf("Lerp(%v, %v, %d): expected %v, got %v", a, b, fraction, expected, result)
+			}
+		}
+	}
+}
+
+// TestBlendBuffer checks that BlendBuffer and ApplyAlphaBuffer give the same
+// results as calling Blend and ApplyAlpha for every pixel.
+func TestBlendBuffer(t *testing.T) {
+	var dst, src, expected []color.RGBA
+	for a := 0; a <= 255; a += 15 {
+		for _, c := range []uint8{0, 50, 200, 255} {
+			bottom := color.RGBA{c, 255 - c, 100, 255}
+			top := color.RGBA{uint8(a * int(c) / 255), uint8(a) / 2, 0, uint8(a)}
+			dst = append(dst, bottom)
+			src = append(src, top)
+			expected = append(expected, Blend(bottom, top))
+		}
+	}
+	BlendBuffer(dst, src)
+	for i := range dst {
+		if dst[i] != expected[i] {
+			t.Errorf("BlendBuffer at index %d: expected %v, got %v", i, expected[i], dst[i])
+		}
+	}
+
+	for _, alpha := range []uint8{0, 1, 100, 255} {
+		buf := append([]color.RGBA(nil), src...)
+		ApplyAlphaBuffer(buf, alpha)
+		for i := range buf {
+			if c := ApplyAlpha(src[i], alpha); buf[i] != c {
+				t.Errorf("ApplyAlphaBuffer(%d) at index %d: expected %v, got %v", alpha, i, c, buf[i])
+			}
+		}
+	}
+}
+
+// Benchmark blending a row of semi-transparent pixels, one pixel at a time and
+// using BlendBuffer.
+func BenchmarkBlendBuffer(b *testing.B) {
+	dst := make([]color.RGBA, 64)
+	src := make([]color.RGBA, 64)
+	for i := range src {
+		dst[i] = color.RGBA{uint8(i * 4), 100, 50, 255}
+		src[i] = color.RGBA{0, uint8(i), uint8(i * 2), uint8(i * 3)}
+	}
+	b.Run("scalar", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			for i := range dst {
+				dst[i] = Blend(dst[i], src[i])
+			}
+		}
+	})
+	b.Run("buffer", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			BlendBuffer(dst, src)
+		}
+	})
+}
+
+// closeColor returns whether the R, G, B and A channels of both colors are not
+// further apart than the given tolerance.
+func closeColor(a, b color.RGBA, tolerance int) bool {
+	for _, diff := range []int{
+		int(a.R) - int(b.R),
+		int(a.G) - int(b.G),
+		int(a.B) - int(b.B),
+		int(a.A) - int(b.A),
+	} {
+		if diff < -tolerance || diff > tolerance {
+			return false
+		}
+	}
+	return true
+}
+
 // blendFloat takes in two colors and blends them together. The bottom color
 // must have an opacity of 100% (A=255).
 //
@@ -64,6 +235,33 @@ func blendFloat(bottom, top color.RGBA) color.RGBA {
 	}
 }
 
+// overFloat is the Porter-Duff "over" operator on premultiplied colors in linear
+// color space, including the resulting alpha. Unlike blendFloat, the background
+// doesn't need to be opaque. This implementation is a reference to test
+// against.
+func overFloat(bottom, top color.RGBA) color.RGBA {
+	ta := float64(top.A) / 255
+	return color.RGBA{
+		R: encodeGammaFloat(decodeGammaFloat(bottom.R)*(1-ta) + decodeGammaFloat(top.R)),
+		G: encodeGammaFloat(decodeGammaFloat(bottom.G)*(1-ta) + decodeGammaFloat(top.G)),
+		B: encodeGammaFloat(decodeGammaFloat(bottom.B)*(1-ta) + decodeGammaFloat(top.B)),
+		A: uint8(math.Round(float64(top.A) + float64(bottom.A)*(1-ta))),
+	}
+}
+
+// lerpFloat interpolates between two colors in linear color space. This
+// implementation is a reference to test against.
+func lerpFloat(a, b color.RGBA, t uint8) color.RGBA {
+	tb := float64(t) / 255
+	ta := 1 - tb
+	return color.RGBA{
+		R: encodeGammaFloat(decodeGammaFloat(a.R)*ta + decodeGammaFloat(b.R)*tb),
+		G: encodeGammaFloat(decodeGammaFloat(a.G)*ta + decodeGammaFloat(b.G)*tb),
+		B: encodeGammaFloat(decodeGammaFloat(a.B)*ta + decodeGammaFloat(b.B)*tb),
+		A: uint8(math.Round(float64(a.A)*ta + float64(b.A)*tb)),
+	}
+}
+
 func decodeGammaFloat(component uint8) float64 {
 	return math.Pow(float64(component)/255, 2.2)
 }
@@ -71,3 +269,140 @@ func decodeGammaFloat(component uint8) float64 {
 func encodeGammaFloat(component float64) uint8 {
 	return uint8(math.Pow(component, 1/2.2) * 255)
 }
+
+// Check ConvertColor for the various color types of the image/color package,
+// including colors with straight alpha that need to be premultiplied.
+func TestConvertColor(t *testing.T) {
+	testCases := []struct {
+		c        color.Color
+		expected color.RGBA
+	}{
+		{color.RGBA{100, 0, 0, 127}, color.RGBA{100, 0, 0, 127}},
+		{color.Gray{128}, color.RGBA{128, 128, 128, 255}},
+		{color.Gray16{0x8080}, color.RGBA{128, 128, 128, 255}},
+		{color.White, color.RGBA{255, 255, 255, 255}},
+		{color.Transparent, color.RGBA{0, 0, 0, 0}},
+		{color.NRGBA{0, 255, 0, 255}, color.RGBA{0, 255, 0, 255}},
+		{color.NRGBA{255, 0, 0, 127}, color.RGBA{179, 0, 0, 127}},
+		{color.NRGBA{10, 20, 30, 0}, color.RGBA{0, 0, 0, 0}},
+		{color.NRGBA64{0xffff, 0, 0, 0x8000}, color.RGBA{180, 0, 0, 128}},
+		{color.Alpha{128}, color.RGBA{180, 180, 180, 128}},
+	}
+	for _, tc := range testCases {
+		if result := ConvertColor(tc.c); result != tc.expected {
+			t.Errorf("ConvertColor(%T%v): expected %v, got %v", tc.c, tc.c, tc.expected, result)
+		}
+	}
+
+	// A semi-transparent color with straight alpha should look like a mix of
+	// the color and the background when blended.
+	red := ConvertColor(color.NRGBA{255, 0, 0, 128})
+	white := color.RGBA{255, 255, 255, 255}
+	result := Blend(white, red)
+	expected := Lerp(white, color.RGBA{255, 0, 0, 255}, 128)
+	for i, pair := range [][2]uint8{{result.R, expected.R}, {result.G, expected.G}, {result.B, expected.B}} {
+		// Allow for a rounding error of 1.
+		if diff := int(pair[0]) - int(pair[1]); diff < -1 || diff > 1 {
+			t.Errorf("blending straight alpha red over white: expected %v, got %v (component %d)", expected, result, i)
+		}
+	}
+}
+
+// Test that a semi-transparent red with straight alpha (converted using
+// ConvertColor) looks as expected when blended over various backgrounds: the
+// same as mixing the opaque red with the background.
+func TestStraightAlpha(t *testing.T) {
+	red := color.RGBA{255, 0, 0, 255}
+	for _, background := range []color.RGBA{{255, 255, 255, 255}, {0, 0, 0, 255}, {0, 0, 255, 255}, {100, 150, 200, 255}} {
+		for _, alpha := range []uint8{0, 1, 64, 127, 200, 255} {
+			top := ConvertColor(color.NRGBA{255, 0, 0, alpha})
+			result := Blend(background, top)
+			expected := Lerp(background, red, alpha)
+			for i, pair := range [][2]uint8{{result.R, expected.R}, {result.G, expected.G}, {result.B, expected.B}} {
+				// Allow for a rounding error of 1.
+				if diff := int(pair[0]) - int(pair[1]); diff < -1 || diff > 1 {
+					t.Errorf("blending red with alpha %d over %v: expected %v, got %v (component %d)", alpha, background, expected, result, i)
+				}
+			}
+		}
+	}
+
+	// Colors that are not premultiplied don't wrap around when blended.
+	if result := Blend(color.RGBA{255, 255, 255, 255}, color.RGBA{255, 255, 255, 127}); result != (color.RGBA{255, 255, 255, 255}) {
+		t.Errorf("blending an invalid color: expected white, got %v", result)
+	}
+}
+
+// Check Premultiply against hand-computed values (using the fast gamma of 2.0:
+// sqrt(c*c*a/255), rounded down), and check that colors survive a round trip
+// through Premultiply and Unpremultiply.
+func TestPremultiply(t *testing.T) {
+	testCases := []struct {
+		straight      color.RGBA
+		premultiplied color.RGBA
+	}{
+		{color.RGBA{200, 100, 50, 255}, color.RGBA{200, 100, 50, 255}},
+		{color.RGBA{255, 0, 0, 127}, color.RGBA{179, 0, 0, 127}},
+		{color.RGBA{255, 255, 255, 64}, color.RGBA{127, 127, 127, 64}},
+		{color.RGBA{200, 100, 50, 200}, color.RGBA{177, 88, 44, 200}},
+		{color.RGBA{0, 128, 255, 1}, color.RGBA{0, 8, 15, 1}},
+		{color.RGBA{10, 20, 30, 0}, color.RGBA{0, 0, 0, 0}},
+	}
+	for _, tc := range testCases {
+		if result := Premultiply(tc.straight); result != tc.premultiplied {
+			t.Errorf("Premultiply(%v): expected %v, got %v", tc.straight, tc.premultiplied, result)
+		}
+	}
+	if result := Unpremultiply(color.RGBA{10, 20, 30, 0}); result != (color.RGBA{}) {
+		t.Errorf("Unpremultiply of a transparent color: expected transparent black, got %v", result)
+	}
+
+	// Going back to straight alpha loses some precision, more so for very
+	// transparent colors.
+	defer SetGammaMode(GammaFast)
+	for _, mode := range []GammaMode{GammaFast, GammaAccurate} {
+		SetGammaMode(mode)
+		for _, alpha := range []uint8{32, 64, 127, 200, 254, 255} {
+			for _, c := range []color.RGBA{{0, 0, 0, 0}, {255, 255, 255, 0}, {255, 0, 0, 0}, {30, 200, 100, 0}, {1, 128, 254, 0}} {
+				c.A = alpha
+				result := Unpremultiply(Premultiply(c))
+				tolerance := 2
+				if alpha < 127 {
+					tolerance = 8
+				}
+				if !closeColor(c, result, tolerance) {
+					t.Errorf("gamma mode %d: round trip of %v resulted in %v", mode, c, result)
+				}
+			}
+		}
+	}
+}
+
+// Check the palette colors and the color constructors.
+func TestPalette(t *testing.T) {
+	testCases := []struct {
+		name     string
+		c        color.RGBA
+		expected color.RGBA
+	}{
+		{"Black", Black, color.RGBA{0, 0, 0, 255}},
+		{"White", White, color.RGBA{255, 255, 255, 255}},
+		{"Gray", Gray, color.RGBA{128, 128, 128, 255}},
+		{"Red", Red, color.RGBA{255, 0, 0, 255}},
+		{"Green", Green, color.RGBA{0, 255, 0, 255}},
+		{"Blue", Blue, color.RGBA{0, 0, 255, 255}},
+		{"Yellow", Yellow, color.RGBA{255, 255, 0, 255}},
+		{"Cyan", Cyan, color.RGBA{0, 255, 255, 255}},
+		{"Magenta", Magenta, color.RGBA{255, 0, 255, 255}},
+		{"Transparent", Transparent, color.RGBA{0, 0, 0, 0}},
+		{"RGB", RGB(10, 20, 30), color.RGBA{10, 20, 30, 255}},
+		{"RGBA opaque", RGBA(10, 20, 30, 255), color.RGBA{10, 20, 30, 255}},
+		{"RGBA transparent", RGBA(255, 0, 0, 127), color.RGBA{179, 0, 0, 127}},
+		{"RGBA invisible", RGBA(10, 20, 30, 0), color.RGBA{0, 0, 0, 0}},
+	}
+	for _, tc := range testCases {
+		if tc.c != tc.expected {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.expected, tc.c)
+		}
+	}
+}
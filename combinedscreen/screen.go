@@ -0,0 +1,174 @@
+// Package combinedscreen combines multiple displays into a single larger
+// Displayer, for example two identical displays mounted side by side that
+// should show a single image.
+package combinedscreen
+
+import (
+	"errors"
+	"image/color"
+
+	"github.com/aykevl/tilegraphics"
+)
+
+var (
+	// ErrBufferSizeMismatch is returned when the size of the buffer passed to
+	// FillRectangleWithBuffer doesn't match the to-be-updated area.
+	ErrBufferSizeMismatch = errors.New("combinedscreen: buffer size did not match width*height")
+)
+
+// Part is a single display that is part of a combined screen.
+type Part struct {
+	Display tilegraphics.Displayer
+
+	// X and Y are the position of the top left corner of the display on the
+	// combined screen.
+	X, Y int16
+}
+
+// Screen sends all updates to the displays it is made of, splitting updates
+// that span more than one display. Areas of the combined screen that aren't
+// covered by any display are ignored.
+type Screen struct {
+	parts []Part
+
+	// buffer is a scratch buffer used for the part of a pixel buffer that is
+	// sent to a single display, reused for every update to avoid allocating
+	// memory.
+	buffer []color.RGBA
+}
+
+// NewScreen returns a new screen that is made of the given displays, each at
+// the given position. The displays should not overlap.
+func NewScreen(parts ...Part) *Screen {
+	return &Screen{
+		parts: parts,
+	}
+}
+
+// NewHorizontal returns a new screen with the given displays placed next to
+// each other from left to right, aligned at the top.
+func NewHorizontal(displays ...tilegraphics.Displayer) *Screen {
+	var parts []Part
+	x := int16(0)
+	for _, display := range displays {
+		parts = append(parts, Part{Display: display, X: x})
+		width, _ := display.Size()
+		x += width
+	}
+	return NewScreen(parts...)
+}
+
+// NewVertical returns a new screen with the given displays placed below each
+// other from top to bottom, aligned at the left.
+func NewVertical(displays ...tilegraphics.Displayer) *Screen {
+	var parts []Part
+	y := int16(0)
+	for _, display := range displays {
+		parts = append(parts, Part{Display: display, Y: y})
+		_, height := display.Size()
+		y += height
+	}
+	return NewScreen(parts...)
+}
+
+// Size returns the size of the smallest rectangle that contains all displays.
+func (s *Screen) Size() (int16, int16) {
+	width, height := int16(0), int16(0)
+	for _, part := range s.parts {
+		partWidth, partHeight := part.Display.Size()
+		if part.X+partWidth > width {
+			width = part.X + partWidth
+		}
+		if part.Y+partHeight > height {
+			height = part.Y + partHeight
+		}
+	}
+	return width, height
+}
+
+// Display sends the last updates to all displays. It returns the first error,
+// but always updates all displays.
+func (s *Screen) Display() error {
+	var err error
+	for _, part := range s.parts {
+		if partErr := part.Display.Display(); partErr != nil && err == nil {
+			err = partErr
+		}
+	}
+	return err
+}
+
+// FillRectangle fills the given rectangle with the given color, on all
+// displays it overlaps. It returns the first error, but always updates all
+// displays.
+func (s *Screen) FillRectangle(x, y, width, height int16, c color.RGBA) error {
+	var err error
+	for _, part := range s.parts {
+		x1, y1, x2, y2, ok := part.clip(x, y, width, height)
+		if !ok {
+			continue
+		}
+		partErr := part.Display.FillRectangle(x1-part.X, y1-part.Y, x2-x1, y2-y1, c)
+		if partErr != nil && err == nil {
+			err = partErr
+		}
+	}
+	return err
+}
+
+// FillRectangleWithBuffer fills the given rectangle with the given buffer, on
+// all displays it overlaps. When the rectangle doesn't fit entirely on a single
+// display, the part of the buffer for each display is copied to a scratch
+// buffer first. It returns the first error, but always updates all displays.
+func (s *Screen) FillRectangleWithBuffer(x, y, width, height int16, buffer []color.RGBA) error {
+	if width < 0 || height < 0 || len(buffer) != int(width)*int(height) {
+		return ErrBufferSizeMismatch
+	}
+	var err error
+	for _, part := range s.parts {
+		x1, y1, x2, y2, ok := part.clip(x, y, width, height)
+		if !ok {
+			continue
+		}
+		partBuffer := buffer
+		if x1 != x || y1 != y || x2 != x+width || y2 != y+height {
+			// Only part of the buffer falls on this display.
+			size := int(x2-x1) * int(y2-y1)
+			if cap(s.buffer) < size {
+				s.buffer = make([]color.RGBA, size)
+			}
+			partBuffer = s.buffer[:size]
+			for pixelY := y1; pixelY < y2; pixelY++ {
+				src := buffer[int(pixelY-y)*int(width)+int(x1-x):]
+				dst := partBuffer[int(pixelY-y1)*int(x2-x1):]
+				copy(dst[:x2-x1], src)
+			}
+		}
+		partErr := part.Display.FillRectangleWithBuffer(x1-part.X, y1-part.Y, x2-x1, y2-y1, partBuffer)
+		if partErr != nil && err == nil {
+			err = partErr
+		}
+	}
+	return err
+}
+
+// clip returns the part of the given rectangle that lies within this display,
+// in the coordinates of the combined screen. It returns false if the rectangle
+// doesn't overlap the display.
+func (p Part) clip(x, y, width, height int16) (x1, y1, x2, y2 int16, ok bool) {
+	partWidth, partHeight := p.Display.Size()
+	x1, y1, x2, y2 = x, y, x+width, y+height
+	if x1 < p.X {
+		x1 = p.X
+	}
+	if y1 < p.Y {
+		y1 = p.Y
+	}
+	if x2 > p.X+partWidth {
+		x2 = p.X + partWidth
+	}
+	if y2 > p.Y+partHeight {
+		y2 = p.Y + partHeight
+	}
+	return x1, y1, x2, y2, x1 < x2 && y1 < y2
+}
@@ -0,0 +1,120 @@
+package combinedscreen
+
+import (
+	"errors"
+	"image/color"
+	"testing"
+
+	"github.com/aykevl/tilegraphics"
+	"github.com/aykevl/tilegraphics/imagescreen"
+)
+
+var _ tilegraphics.Displayer = (*Screen)(nil)
+
+// Draw a scene with a rectangle across the seam of two displays combined
+// horizontally, and check that each display shows its half of the image that
+// is drawn on a single display of the combined size. The displays aren't a
+// multiple of the tile size, so that tiles straddle the seam.
+func TestHorizontal(t *testing.T) {
+	drawScene := func(display tilegraphics.Displayer) {
+		engine := tilegraphics.NewEngine(display)
+		engine.SetBackgroundColor(color.RGBA{50, 50, 50, 255})
+		engine.NewRectangle(10, 5, 30, 10, color.RGBA{255, 0, 0, 255})
+		engine.NewCircle(25, 12, 6, color.RGBA{0, 0, 255, 255})
+		engine.Display()
+	}
+
+	left := imagescreen.NewScreen(25, 20)
+	right := imagescreen.NewScreen(25, 20)
+	screen := NewHorizontal(left, right)
+	if width, height := screen.Size(); width != 50 || height != 20 {
+		t.Fatalf("unexpected combined size %dx%d", width, height)
+	}
+	drawScene(screen)
+
+	reference := imagescreen.NewScreen(50, 20)
+	drawScene(reference)
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 50; x++ {
+			var c color.RGBA
+			if x < 25 {
+				c = left.RGBAAt(x, y)
+			} else {
+				c = right.RGBAAt(x-25, y)
+			}
+			if expected := reference.RGBAAt(x, y); c != expected {
+				t.Errorf("pixel at (%d, %d): expected %v, got %v", x, y, expected, c)
+			}
+		}
+	}
+}
+
+// Check that a buffer that straddles the seam of two displays combined
+// vertically ends up on both displays, and that a buffer of the wrong size is
+// rejected.
+func TestVerticalBuffer(t *testing.T) {
+	top := imagescreen.NewScreen(4, 3)
+	bottom := imagescreen.NewScreen(4, 3)
+	screen := NewVertical(top, bottom)
+	buffer := make([]color.RGBA, 3*4)
+	for i := range buffer {
+		buffer[i] = color.RGBA{uint8(i + 1), 0, 0, 255}
+	}
+	if err := screen.FillRectangleWithBuffer(1, 1, 3, 4, buffer); err != nil {
+		t.Fatal("could not fill rectangle:", err)
+	}
+	for i, c := range buffer {
+		x, y := 1+i%3, 1+i/3
+		display, displayY := top, y
+		if y >= 3 {
+			display, displayY = bottom, y-3
+		}
+		if result := display.RGBAAt(x, displayY); result != c {
+			t.Errorf("pixel at (%d, %d): expected %v, got %v", x, y, c, result)
+		}
+	}
+	if err := screen.FillRectangleWithBuffer(0, 0, 2, 2, buffer); err != ErrBufferSizeMismatch {
+		t.Errorf("expected ErrBufferSizeMismatch, got %v", err)
+	}
+}
+
+// failingScreen is an imagescreen.Screen that fails every fill, without
+// drawing anything.
+type failingScreen struct {
+	*imagescreen.Screen
+}
+
+var errFill = errors.New("fill failed")
+
+func (s failingScreen) FillRectangle(x, y, width, height int16, c color.RGBA) error {
+	return errFill
+}
+
+func (s failingScreen) FillRectangleWithBuffer(x, y, width, height int16, buffer []color.RGBA) error {
+	return errFill
+}
+
+// A failing display must not stop the other displays from being updated, and
+// its error must be returned.
+func TestFailingPart(t *testing.T) {
+	left := failingScreen{imagescreen.NewScreen(2, 2)}
+	right := imagescreen.NewScreen(2, 2)
+	screen := NewHorizontal(left, right)
+
+	red := color.RGBA{255, 0, 0, 255}
+	if err := screen.FillRectangle(0, 0, 4, 2, red); err != errFill {
+		t.Errorf("FillRectangle: expected the error of the first display, got %v", err)
+	}
+	if c := right.RGBAAt(0, 0); c != red {
+		t.Errorf("FillRectangle: expected %v on the second display, got %v", red, c)
+	}
+
+	green := color.RGBA{0, 255, 0, 255}
+	buffer := []color.RGBA{green, green, green, green, green, green, green, green}
+	if err := screen.FillRectangleWithBuffer(0, 0, 4, 2, buffer); err != errFill {
+		t.Errorf("FillRectangleWithBuffer: expected the error of the first display, got %v", err)
+	}
+	if c := right.RGBAAt(1, 1); c != green {
+		t.Errorf("FillRectangleWithBuffer: expected %v on the second display, got %v", green, c)
+	}
+}
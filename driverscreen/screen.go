@@ -0,0 +1,112 @@
+// Package driverscreen wraps a display driver from tinygo.org/x/drivers to
+// implement the Displayer interface as required by tilegraphics.
+//
+// All drivers implement the basic drivers.Displayer interface (Size, SetPixel
+// and Display), but most of them also have faster methods to update many
+// pixels at once. These are used when available: FillRectangle for filling
+// rectangles with a single color and DrawRGBBitmap for drawing (RGB565
+// converted) buffers. Other drivers are updated one pixel at a time.
+package driverscreen
+
+import (
+	"errors"
+	"image/color"
+
+	"github.com/aykevl/tilegraphics/rgb565screen"
+)
+
+var (
+	// ErrBufferSizeMismatch is returned when the size of the buffer passed to
+	// FillRectangleWithBuffer doesn't match the to-be-updated area.
+	ErrBufferSizeMismatch = errors.New("driverscreen: buffer size did not match width*height")
+)
+
+// Device is the drivers.Displayer interface from tinygo.org/x/drivers. It is
+// redefined here to avoid a dependency on the drivers repository.
+type Device interface {
+	// Size returns the display size in pixels.
+	Size() (x, y int16)
+
+	// SetPixel changes a single pixel in the framebuffer of the display, or on
+	// the display itself.
+	SetPixel(x, y int16, c color.RGBA)
+
+	// Display sends the framebuffer to the screen, if needed.
+	Display() error
+}
+
+// rectangleFiller is implemented by drivers that can fill a rectangle with a
+// single color.
+type rectangleFiller interface {
+	FillRectangle(x, y, width, height int16, c color.RGBA) error
+}
+
+// Screen maps the tilegraphics Displayer interface to the methods supported by
+// a display driver.
+type Screen struct {
+	device Device
+
+	// filler is set when the device can fill rectangles itself.
+	filler rectangleFiller
+
+	// bitmap is set when the device can draw RGB565 bitmaps.
+	bitmap *rgb565screen.Screen
+}
+
+// NewScreen returns a new screen that wraps the given display driver.
+func NewScreen(device Device) *Screen {
+	s := &Screen{
+		device: device,
+	}
+	if filler, ok := device.(rectangleFiller); ok {
+		s.filler = filler
+	}
+	if bitmapDevice, ok := device.(rgb565screen.Device); ok {
+		s.bitmap = rgb565screen.NewScreen(bitmapDevice)
+	}
+	return s
+}
+
+// Size returns the size of the underlying display.
+func (s *Screen) Size() (int16, int16) {
+	return s.device.Size()
+}
+
+// Display sends the last updates to the underlying display.
+func (s *Screen) Display() error {
+	return s.device.Display()
+}
+
+// FillRectangle fills the given rectangle with the given color.
+func (s *Screen) FillRectangle(x, y, width, height int16, c color.RGBA) error {
+	if s.filler != nil {
+		return s.filler.FillRectangle(x, y, width, height, c)
+	}
+	if s.bitmap != nil {
+		return s.bitmap.FillRectangle(x, y, width, height, c)
+	}
+	for pixelY := y; pixelY < y+height; pixelY++ {
+		for pixelX := x; pixelX < x+width; pixelX++ {
+			s.device.SetPixel(pixelX, pixelY, c)
+		}
+	}
+	return nil
+}
+
+// FillRectangleWithBuffer draws the given buffer to the display. The buffer
+// must be in row major order.
+func (s *Screen) FillRectangleWithBuffer(x, y, width, height int16, buffer []color.RGBA) error {
+	if width < 0 || height < 0 || len(buffer) != int(width)*int(height) {
+		return ErrBufferSizeMismatch
+	}
+	if s.bitmap != nil {
+		return s.bitmap.FillRectangleWithBuffer(x, y, width, height, buffer)
+	}
+	for bufferY := int16(0); bufferY < height; bufferY++ {
+		row := buffer[int(bufferY)*int(width):]
+		for bufferX := int16(0); bufferX < width; bufferX++ {
+			s.device.SetPixel(x+bufferX, y+bufferY, row[bufferX])
+		}
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package driverscreen
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/aykevl/tilegraphics"
+	"github.com/aykevl/tilegraphics/rgb565screen"
+)
+
+// Make sure Screen can be used by tilegraphics.
+var _ tilegraphics.Displayer = (*Screen)(nil)
+
+// pixelDevice is an in-memory display that only supports the basic
+// drivers.Displayer interface.
+type pixelDevice struct {
+	width, height int16
+	pixels        []color.RGBA
+	setPixelCalls int
+}
+
+func newPixelDevice(width, height int16) *pixelDevice {
+	return &pixelDevice{
+		width:  width,
+		height: height,
+		pixels: make([]color.RGBA, int(width)*int(height)),
+	}
+}
+
+func (d *pixelDevice) Size() (int16, int16) {
+	return d.width, d.height
+}
+
+func (d *pixelDevice) SetPixel(x, y int16, c color.RGBA) {
+	d.setPixelCalls++
+	d.pixels[int(y)*int(d.width)+int(x)] = c
+}
+
+func (d *pixelDevice) Display() error {
+	return nil
+}
+
+// bitmapDevice is a display that also supports drawing RGB565 bitmaps, like
+// most SPI display drivers.
+type bitmapDevice struct {
+	*pixelDevice
+	bitmap []uint16
+}
+
+func (d *bitmapDevice) DrawRGBBitmap(x, y int16, data []uint16, width, height int16) error {
+	for bufferY := int16(0); bufferY < height; bufferY++ {
+		for bufferX := int16(0); bufferX < width; bufferX++ {
+			d.bitmap[int(y+bufferY)*int(d.width)+int(x+bufferX)] = data[int(bufferY)*int(width)+int(bufferX)]
+		}
+	}
+	return nil
+}
+
+var testBuffer = []color.RGBA{
+	{0, 255, 0, 255}, {0, 0, 255, 255}, {255, 255, 255, 255},
+	{0x12, 0x34, 0x56, 255}, {0, 0, 0, 255}, {0x80, 0x80, 0x80, 255},
+}
+
+// Check that updates are sent pixel by pixel to a basic driver.
+func TestPixelDevice(t *testing.T) {
+	device := newPixelDevice(10, 8)
+	screen := NewScreen(device)
+	if width, height := screen.Size(); width != 10 || height != 8 {
+		t.Errorf("unexpected size: %dx%d", width, height)
+	}
+	screen.FillRectangle(0, 0, 10, 8, color.RGBA{255, 0, 0, 255})
+	screen.FillRectangleWithBuffer(4, 5, 3, 2, testBuffer)
+	for y := int16(0); y < 8; y++ {
+		for x := int16(0); x < 10; x++ {
+			expected := color.RGBA{255, 0, 0, 255}
+			if x >= 4 && x < 7 && y >= 5 && y < 7 {
+				expected = testBuffer[(y-5)*3+(x-4)]
+			}
+			if c := device.pixels[int(y)*10+int(x)]; c != expected {
+				t.Errorf("pixel at X=%d Y=%d: expected %v, got %v", x, y, expected, c)
+			}
+		}
+	}
+	if device.setPixelCalls != 10*8+3*2 {
+		t.Errorf("unexpected number of SetPixel calls: %d", device.setPixelCalls)
+	}
+	if err := screen.FillRectangleWithBuffer(0, 0, 2, 2, testBuffer); err != ErrBufferSizeMismatch {
+		t.Errorf("expected ErrBufferSizeMismatch, got %v", err)
+	}
+}
+
+// Check that buffers are converted to RGB565 for drivers that support it,
+// instead of being sent pixel by pixel.
+func TestBitmapDevice(t *testing.T) {
+	device := &bitmapDevice{pixelDevice: newPixelDevice(10, 8)}
+	device.bitmap = make([]uint16, 10*8)
+	screen := NewScreen(device)
+	screen.FillRectangle(0, 0, 10, 8, color.RGBA{255, 0, 0, 255})
+	screen.FillRectangleWithBuffer(4, 5, 3, 2, testBuffer)
+	if device.setPixelCalls != 0 {
+		t.Errorf("expected no SetPixel calls, got %d", device.setPixelCalls)
+	}
+	for y := int16(0); y < 8; y++ {
+		for x := int16(0); x < 10; x++ {
+			expected := uint16(0xf800)
+			if x >= 4 && x < 7 && y >= 5 && y < 7 {
+				expected = rgb565screen.ToRGB565(testBuffer[(y-5)*3+(x-4)])
+			}
+			if c := device.bitmap[int(y)*10+int(x)]; c != expected {
+				t.Errorf("pixel at X=%d Y=%d: expected 0x%04x, got 0x%04x", x, y, expected, c)
+			}
+		}
+	}
+}
@@ -7,21 +7,46 @@
 // but not directly sent to the screen. They're only sent on the next call to
 // Display(). It is therefore recommended to only call Display() when the
 // display should really be updated, to send all the updates in a single batch
-// for improved performance.
+// for improved performance. Changes don't add up: every tile that needs to be
+// redrawn is painted and sent exactly once per call to Display, no matter how
+// many objects were changed in it.
+//
+// All colors are color.RGBA values, which (like in the standard library) have
+// the alpha premultiplied: a color like color.RGBA{255, 0, 0, 127} is not a
+// semi-transparent red and will look wrong when blended. Unlike the standard
+// library, the premultiplication happens in linear color space, so a
+// semi-transparent red is color.RGBA{180, 0, 0, 127} instead of
+// color.RGBA{127, 0, 0, 127}. Use ConvertColor to convert colors with straight
+// alpha, for example ConvertColor(color.NRGBA{255, 0, 0, 127}), or ApplyAlpha
+// to make an opaque color transparent.
 package tilegraphics
 
-import "image/color"
+import (
+	"errors"
+	"image"
+	"image/color"
+	"time"
+)
 
-// TileSize is the size (width and height) of a tile. A tile will take up
-// TileSize*TileSize*4 bytes of memory during rendering.
-const TileSize = 8
+var (
+	// ErrBufferSizeMismatch is returned when the size of the buffer passed to
+	// PaintRegion doesn't match the to-be-painted area.
+	ErrBufferSizeMismatch = errors.New("tilegraphics: buffer size did not match width*height")
 
-// engineDebug can be set to true for extra logging.
-const engineDebug = false
+	// ErrDisplaySizeMismatch is returned by SetDisplay when the new display
+	// doesn't have the same size as the current display.
+	ErrDisplaySizeMismatch = errors.New("tilegraphics: display size did not match")
+)
+
+// TileSize is the default size (width and height) of a tile, as used by
+// NewEngine. A tile will take up tileSize*tileSize*4 bytes of memory during
+// rendering.
+const TileSize = 8
 
 // Displayer is the display interface required by the rendering engine.
 type Displayer interface {
-	// Size returns the display size in pixels. It must never change.
+	// Size returns the display size in pixels. It must not change, unless
+	// Engine.Resize is called directly afterwards.
 	Size() (int16, int16)
 
 	// Display sends the last updates to the screen, if needed.
@@ -34,12 +59,96 @@ type Displayer interface {
 	FillRectangle(x, y, width, height int16, c color.RGBA) error
 
 	// FillRectangleWithBuffer fills the given rectangle with a slice of colors.
-	// The buffer is stored in row major order.
+	// The buffer is stored in row major order and has a length of exactly
+	// width*height. It is only valid during the call: the engine reuses it
+	// afterwards.
 	FillRectangleWithBuffer(x, y, width, height int16, buffer []color.RGBA) error
 }
 
-// tile encapsulates a single tile with colors in row major order.
-type tile [TileSize * TileSize]color.RGBA
+// screenFiller is an optional interface that may be implemented by a
+// Displayer. FillScreen fills the whole screen with the given color, which may
+// be faster than filling it in parts with FillRectangle. The engine uses it
+// when the whole screen needs to be redrawn with just the background color,
+// for example after Clear.
+type screenFiller interface {
+	FillScreen(c color.RGBA) error
+}
+
+// tile encapsulates a rectangular area of pixels that is painted at once, with
+// colors in row major order. The pixels slice has a length of exactly
+// width*height. Usually it is a square tile of the engine tile size, but it may
+// have any size.
+type tile struct {
+	width  int16
+	height int16
+
+	// stride is the number of pixels from the start of one row to the start of
+	// the next, which is at least width. It is larger than width when the tile
+	// is a region of a larger buffer, see subTile.
+	stride int16
+
+	// pixels are indexed as y*stride+x.
+	pixels []color.RGBA
+}
+
+// newTile allocates a new tile with the given width and height.
+func newTile(width, height int16) *tile {
+	return &tile{
+		width:  width,
+		height: height,
+		stride: width,
+		pixels: make([]color.RGBA, int(width)*int(height)),
+	}
+}
+
+// subTile returns a tile that refers to the given region of this tile, without
+// copying any pixels. Painting into the returned tile paints into this tile.
+// The region must lie entirely within this tile.
+func (t *tile) subTile(x, y, width, height int16) tile {
+	return tile{
+		width:  width,
+		height: height,
+		stride: t.stride,
+		pixels: t.pixels[int(y)*int(t.stride)+int(x) : int(y+height-1)*int(t.stride)+int(x+width)],
+	}
+}
+
+// uniformColor returns the color of the top left width*height pixels of the
+// tile and true if they all have the same color, or false otherwise.
+func (t *tile) uniformColor(width, height int16) (color.RGBA, bool) {
+	c := t.pixels[0]
+	for y := int16(0); y < height; y++ {
+		for _, pixel := range t.pixels[y*t.stride : y*t.stride+width] {
+			if pixel != c {
+				return c, false
+			}
+		}
+	}
+	return c, true
+}
+
+// clear makes all pixels of the tile transparent.
+func (t *tile) clear() {
+	for y := int16(0); y < t.height; y++ {
+		row := t.pixels[y*t.stride : y*t.stride+t.width]
+		for i := range row {
+			row[i] = color.RGBA{}
+		}
+	}
+}
+
+// blendOver blends all pixels in the top left width*height pixels of the tile
+// that aren't fully opaque over the given background color.
+func (t *tile) blendOver(width, height int16, background color.RGBA) {
+	for y := int16(0); y < height; y++ {
+		row := t.pixels[y*t.stride : y*t.stride+width]
+		for i, c := range row {
+			if c.A != 255 {
+				row[i] = Blend(background, c)
+			}
+		}
+	}
+}
 
 // Engine is the actual rendering engine. Use NewEngine to construct a new rendering engine.
 type Engine struct {
@@ -47,62 +156,288 @@ type Engine struct {
 	// the Display method is called.
 	display Displayer
 
-	// The root layer, that stores the background color and the list of objects
-	// (in order) that should be drawn on each tile.
-	root Layer
+	// tileSize is the width and height of every tile used by this engine.
+	tileSize int16
+
+	// The root layer of the active scene, that stores the background color and
+	// the list of objects (in order) that should be drawn on each tile.
+	root *Layer
+
+	// scenes contains the root layers of all scenes by name, including the
+	// active scene. See AddScene.
+	scenes map[string]*Layer
 
 	// cleanTiles stores for each tile whether it should be redrawn. True means
-	// it is up-to-date, false means it should be redrawn.
+	// it is up-to-date, false means it should be redrawn. It is indexed as
+	// cleanTiles[tileY][tileX]: the outer slice contains the rows (one per
+	// tile height), the inner slices contain the columns (one per tile width).
 	cleanTiles [][]bool
 
+	// tileHashes contains a hash of the contents of each tile as it was last
+	// sent to the display, indexed like cleanTiles, or nil when tile hashing
+	// is disabled. A hash of 0 means the contents are unknown. See
+	// SetTileHashing.
+	tileHashes [][]uint64
+
 	// tile is a tile that is re-used for all root tiles.
 	tile *tile
 
+	// async is set when tiles are sent to the display from a separate
+	// goroutine, see SetAsyncFlush.
+	async bool
+
+	// asyncTile is the second root tile when asynchronous flushing is enabled,
+	// or nil otherwise. It is allocated on first use, and isn't needed for a
+	// Displayer565.
+	asyncTile *tile
+
+	// buffer565 is the buffer that painted tiles are converted to when they
+	// are sent asynchronously to a Displayer565, so that the tile can be
+	// painted again in the meantime. It is allocated on first use. Without
+	// asynchronous flushing, tiles are converted in place instead.
+	buffer565 []uint16
+
+	// asyncRequests, asyncDone, asyncFree and asyncFree565 are the channels
+	// to communicate with the goroutine that sends tiles to the display when
+	// flushing asynchronously, see startAsyncFlush. They are nil when the
+	// goroutine isn't running. async565 is set when it was started for a
+	// Displayer565.
+	asyncRequests chan flushRequest
+	asyncDone     chan struct{}
+	asyncFree     chan *tile
+	asyncFree565  chan []uint16
+	async565      bool
+
+	// interlaced is set when tiles are sent in two passes, see SetInterlaced.
+	interlaced bool
+
+	// transparentBackground is set when the root layer isn't filled with its
+	// background color, see SetTransparentBackground.
+	transparentBackground bool
+
+	// The area of the screen (in screen coordinates) that is never sent to the
+	// display, see SetStaticRegion. It is empty when x1 >= x2 or y1 >= y2.
+	staticX1, staticY1, staticX2, staticY2 int16
+
 	// tilePool is a slice of re-usable tiles. They can be used for layer
 	// drawing, without allocating a new tile every time or allocating a big
 	// object on the stack (if it gets stack-allocated at all).
 	tilePool []*tile
+
+	// stats contains statistics about the last call to Display.
+	stats FrameStats
+
+	// onFrame is called at the end of every call to Display, see SetOnFrame.
+	onFrame func(stats FrameStats)
 }
 
-// NewEngine creates a new rendering engine based on the displayer interface.
+// FrameStats contains statistics about a single call to Engine.Display. It can
+// be used to check how much of the screen is redrawn every frame.
+type FrameStats struct {
+	// TilesDrawn is the number of tiles that were painted and sent to the
+	// display.
+	TilesDrawn int
+
+	// TilesSkipped is the number of tiles that were still up-to-date, and
+	// therefore weren't painted.
+	TilesSkipped int
+
+	// TilesUnchanged is the number of tiles that were painted, but weren't
+	// sent to the display because they were the same as what was already on
+	// the display. This is only counted when tile hashing is enabled, see
+	// SetTileHashing.
+	TilesUnchanged int
+
+	// BytesSent is the number of bytes of pixel data sent to the display,
+	// counting 4 bytes per color.RGBA (or 2 bytes per color for a
+	// Displayer565). A tile with a single color counts as a single color.RGBA,
+	// and filling the whole screen at once as a single color.
+	BytesSent int
+
+	// Duration is the time it took to paint all tiles and send them to the
+	// display, including the call to the Display method of the display.
+	Duration time.Duration
+}
+
+// NewEngine creates a new rendering engine based on the displayer interface,
+// using the default tile size (TileSize).
 func NewEngine(display Displayer) *Engine {
-	// Store which tiles are currently up-to-date and which aren't.
-	width, height := display.Size()
-	cleanTiles := make([][]bool, (height+TileSize-1)/TileSize)
-	for i := 0; i < len(cleanTiles); i++ {
-		cleanTiles[i] = make([]bool, (width+TileSize-1)/TileSize)
-	}
+	return NewEngineWithTileSize(display, TileSize)
+}
 
+// NewEngineWithTileSize creates a new rendering engine with the given tile
+// size. Larger tiles need more memory (tileSize*tileSize*4 bytes per tile) but
+// result in fewer calls to FillRectangleWithBuffer, which may be faster on some
+// displays. The tile size must be between 1 and 128, other sizes cause a panic.
+//
+// The display may have any size, including sizes smaller than a single tile (or
+// even zero). Tiles that fall partially outside of the display are clipped
+// before they are sent to the display.
+func NewEngineWithTileSize(display Displayer, tileSize int16) *Engine {
+	if tileSize < 1 || tileSize > 128 {
+		panic("tilegraphics: tile size must be between 1 and 128")
+	}
 	e := &Engine{
-		display:    display,
-		tile:       &tile{},
-		cleanTiles: cleanTiles,
+		display:  display,
+		tileSize: tileSize,
+		tile:     newTile(tileSize, tileSize),
 	}
-	e.root = Layer{
+	e.root = e.newRootLayer()
+	e.scenes = map[string]*Layer{"": e.root}
+	e.Resize()
+	return e
+}
+
+// newRootLayer returns a new empty root layer with a black background. Its
+// size is set in Resize.
+func (e *Engine) newRootLayer() *Layer {
+	root := &Layer{
 		rect: Rectangle{
-			x1:    0,
-			y1:    0,
-			x2:    width,
-			y2:    height,
 			color: color.RGBA{0, 0, 0, 255}, // black background by default
 		},
-		engine: e,
+		engine:  e,
+		opacity: 255,
+	}
+	root.rect.parent = root
+	return root
+}
+
+// AddScene creates a new scene with the given name and returns its root layer,
+// which has a black background and covers the whole display. A scene is a
+// separate tree of objects, of which only one (the active scene) is shown at a
+// time. Objects can be added to a scene while it is not shown. If a scene with
+// this name already exists, its root layer is returned instead.
+//
+// The engine starts with a single scene with the empty name. The methods of
+// the engine that add or remove objects (such as NewRectangle and Clear) all
+// operate on the active scene.
+func (e *Engine) AddScene(name string) *Layer {
+	if scene, ok := e.scenes[name]; ok {
+		return scene
+	}
+	scene := e.newRootLayer()
+	scene.rect.x2 = e.root.rect.x2
+	scene.rect.y2 = e.root.rect.y2
+	e.scenes[name] = scene
+	return scene
+}
+
+// ShowScene makes the scene with the given name the active scene, so that it
+// is shown on the next call to Display. The whole display will be repainted.
+// Nothing happens if there is no scene with this name.
+func (e *Engine) ShowScene(name string) {
+	scene, ok := e.scenes[name]
+	if !ok || scene == e.root {
+		return
+	}
+	e.root = scene
+	e.InvalidateAll()
+}
+
+// Resize reads the display size again and adjusts the engine to it. It must be
+// called when the size of the display changes, for example when a desktop
+// window is resized. All objects are kept, and the whole display will be
+// repainted on the next call to Display.
+func (e *Engine) Resize() {
+	// Store which tiles are currently up-to-date and which aren't. This is
+	// stored as rows of tiles: see the cleanTiles field.
+	width, height := e.display.Size()
+	if width < 0 {
+		width = 0
+	}
+	if height < 0 {
+		height = 0
+	}
+	tileSize := e.tileSize
+	e.cleanTiles = make([][]bool, (height+tileSize-1)/tileSize)
+	for i := 0; i < len(e.cleanTiles); i++ {
+		e.cleanTiles[i] = make([]bool, (width+tileSize-1)/tileSize)
+	}
+	if e.tileHashes != nil {
+		e.tileHashes = makeTileHashes(e.cleanTiles)
+	}
+	for _, scene := range e.scenes {
+		scene.rect.x2 = width
+		scene.rect.y2 = height
 	}
-	e.root.rect.parent = &e.root
-	return e
 }
 
-// SetBackgroundColor updates the background color of the display. Note that the
-// alpha channel should be 100% (255) and will be ignored.
+// SetDisplay replaces the display that the engine draws to, keeping all
+// objects. The new display must have the same size as the current display, or
+// ErrDisplaySizeMismatch is returned (use Resize for displays that change in
+// size). The whole new display will be painted on the next call to Display.
+func (e *Engine) SetDisplay(display Displayer) error {
+	width, height := display.Size()
+	if currentWidth, currentHeight := e.display.Size(); width != currentWidth || height != currentHeight {
+		return ErrDisplaySizeMismatch
+	}
+	e.display = display
+	e.InvalidateAll()
+	return nil
+}
+
+// SetBackgroundColor updates the background color of the display. The alpha
+// channel is ignored: the background is always fully opaque.
 func (e *Engine) SetBackgroundColor(background color.RGBA) {
 	e.root.SetBackgroundColor(background)
 }
 
+// SetBackgroundImage sets an image to be drawn as the background of the
+// display. See Layer.SetBackgroundImage for details.
+func (e *Engine) SetBackgroundImage(img image.Image, mode BackgroundMode) {
+	e.root.SetBackgroundImage(img, mode)
+}
+
+// Add adds a custom object to the display, see Layer.Add.
+func (e *Engine) Add(obj Object) *Custom {
+	return e.root.Add(obj)
+}
+
+// SetViewport moves the camera over the scene, so that the scene coordinates
+// (x, y) are shown at the top left corner of the display. This can be used to
+// pan over a scene that is larger than the display. All objects keep their
+// scene coordinates, while the background color or image stays in place. The
+// whole display is repainted after the viewport changed. Like the other
+// methods of the engine, this applies to the active scene.
+func (e *Engine) SetViewport(x, y int16) {
+	e.root.SetScrollOffset(x, y)
+}
+
 // NewRectangle adds a new rectangle to the display with the given color.
 func (e *Engine) NewRectangle(x, y, width, height int16, c color.RGBA) *Rectangle {
 	return e.root.NewRectangle(x, y, width, height, c)
 }
 
+// NewRectangleOutline adds the border of a rectangle to the display, with the
+// given thickness and color.
+func (e *Engine) NewRectangleOutline(x, y, width, height, thickness int16, c color.RGBA) *RectangleOutline {
+	return e.root.NewRectangleOutline(x, y, width, height, thickness, c)
+}
+
+// NewBeveledRectangle adds a filled rectangle with a beveled border to the
+// display, with lighter top and left edges and darker bottom and right edges.
+func (e *Engine) NewBeveledRectangle(x, y, width, height, bevel int16, fill, light, dark color.RGBA) *BeveledRectangle {
+	return e.root.NewBeveledRectangle(x, y, width, height, bevel, fill, light, dark)
+}
+
+// NewRoundedRectangle adds a new filled rectangle with rounded corners to the
+// display.
+func (e *Engine) NewRoundedRectangle(x, y, width, height, radius int16, c color.RGBA) *RoundedRectangle {
+	return e.root.NewRoundedRectangle(x, y, width, height, radius, c)
+}
+
+// NewGradientRectangle adds a new rectangle to the display filled with a
+// gradient from the start color to the end color.
+func (e *Engine) NewGradientRectangle(x, y, width, height int16, start, end color.RGBA, vertical bool) *GradientRectangle {
+	return e.root.NewGradientRectangle(x, y, width, height, start, end, vertical)
+}
+
+// NewPatternRectangle adds a new rectangle to the display filled with a
+// checkerboard pattern of the two colors.
+func (e *Engine) NewPatternRectangle(x, y, width, height int16, colorA, colorB color.RGBA, cellSize int16) *PatternRectangle {
+	return e.root.NewPatternRectangle(x, y, width, height, colorA, colorB, cellSize)
+}
+
 // NewLayer creates a new layer to the display with the given background color.
 func (e *Engine) NewLayer(x, y, width, height int16, background color.RGBA) *Layer {
 	return e.root.NewLayer(x, y, width, height, background)
@@ -114,17 +449,206 @@ func (e *Engine) NewLine(x1, y1, x2, y2 int16, stroke color.RGBA) *Line {
 	return e.root.NewLine(x1, y1, x2, y2, stroke)
 }
 
-// getTile returns a reusable tile from the tile pool, without allocating a new
-// tile. It should be returned to the tile pool after use with putTile.
-func (e *Engine) getTile() *tile {
+// NewLineF creates a new line with end points at sub-pixel positions and the
+// given stroke color.
+func (e *Engine) NewLineF(x1, y1, x2, y2 float32, stroke color.RGBA) *LineF {
+	return e.root.NewLineF(x1, y1, x2, y2, stroke)
+}
+
+// NewHRule adds a new horizontal line at the given y coordinate that spans the
+// whole width of the display.
+func (e *Engine) NewHRule(y int16, c color.RGBA) *Rule {
+	return e.root.NewHRule(y, c)
+}
+
+// NewVRule adds a new vertical line at the given x coordinate that spans the
+// whole height of the display.
+func (e *Engine) NewVRule(x int16, c color.RGBA) *Rule {
+	return e.root.NewVRule(x, c)
+}
+
+// NewDashedLine adds a new dashed line to the display, with dashes and gaps of
+// the given lengths (in pixels).
+func (e *Engine) NewDashedLine(x1, y1, x2, y2 int16, stroke color.RGBA, dash, gap int16) *Line {
+	return e.root.NewDashedLine(x1, y1, x2, y2, stroke, dash, gap)
+}
+
+// NewThickLine creates a new line with the two given coordinates, the given
+// stroke width and the given stroke color.
+func (e *Engine) NewThickLine(x1, y1, x2, y2, width int16, stroke color.RGBA) *Line {
+	return e.root.NewThickLine(x1, y1, x2, y2, width, stroke)
+}
+
+// NewQuadBezier adds a new quadratic Bézier curve to the display, see
+// Layer.NewQuadBezier.
+func (e *Engine) NewQuadBezier(x0, y0, cx, cy, x1, y1 int16, stroke color.RGBA) *QuadBezier {
+	return e.root.NewQuadBezier(x0, y0, cx, cy, x1, y1, stroke)
+}
+
+// NewPolygon adds a new filled convex polygon to the display, see
+// Layer.NewPolygon.
+func (e *Engine) NewPolygon(points []image.Point, c color.RGBA) *Polygon {
+	return e.root.NewPolygon(points, c)
+}
+
+// NewRadialLine adds a new line that points from a pivot at a given angle to
+// the display, see Layer.NewRadialLine.
+func (e *Engine) NewRadialLine(cx, cy, length int16, angle float32, c color.RGBA) *RadialLine {
+	return e.root.NewRadialLine(cx, cy, length, angle, c)
+}
+
+// NewPolyline adds a new open path of anti-aliased lines to the display,
+// connecting each point to the next.
+func (e *Engine) NewPolyline(points []image.Point, stroke color.RGBA) *Polyline {
+	return e.root.NewPolyline(points, stroke)
+}
+
+// NewPixel adds a single pixel to the display at the given coordinates.
+func (e *Engine) NewPixel(x, y int16, c color.RGBA) *Pixel {
+	return e.root.NewPixel(x, y, c)
+}
+
+// NewPoints adds a new set of single pixels to the display, all in the same
+// color.
+func (e *Engine) NewPoints(points []image.Point, c color.RGBA) *Points {
+	return e.root.NewPoints(points, c)
+}
+
+// NewCircle creates a new filled circle with the given center, radius and fill
+// color.
+func (e *Engine) NewCircle(cx, cy, radius int16, c color.RGBA) *Circle {
+	return e.root.NewCircle(cx, cy, radius, c)
+}
+
+// NewCircleOutline creates a new ring with the given center, outer radius,
+// thickness and color.
+func (e *Engine) NewCircleOutline(cx, cy, radius, thickness int16, c color.RGBA) *CircleOutline {
+	return e.root.NewCircleOutline(cx, cy, radius, thickness, c)
+}
+
+// NewSprite creates a new sprite that draws the given image with the top left
+// corner at the given coordinates.
+func (e *Engine) NewSprite(x, y int16, img image.Image) *Sprite {
+	return e.root.NewSprite(x, y, img)
+}
+
+// NewArc adds a new arc (part of a ring) to the display, drawn clockwise from
+// startAngle to endAngle in degrees.
+func (e *Engine) NewArc(cx, cy, radius, thickness int16, startAngle, endAngle float32, c color.RGBA) *Arc {
+	return e.root.NewArc(cx, cy, radius, thickness, startAngle, endAngle, c)
+}
+
+// NewEllipse creates a new filled ellipse with the given center, horizontal and
+// vertical radius and fill color.
+func (e *Engine) NewEllipse(cx, cy, rx, ry int16, c color.RGBA) *Ellipse {
+	return e.root.NewEllipse(cx, cy, rx, ry, c)
+}
+
+// NewTriangle creates a new filled triangle with the given corners and fill
+// color.
+func (e *Engine) NewTriangle(x1, y1, x2, y2, x3, y3 int16, c color.RGBA) *Triangle {
+	return e.root.NewTriangle(x1, y1, x2, y2, x3, y3, c)
+}
+
+// NewText creates a new line of text with the given font and color.
+func (e *Engine) NewText(x, y int16, s string, font *Font, c color.RGBA) *Text {
+	return e.root.NewText(x, y, s, font, c)
+}
+
+// ObjectAt returns the topmost object at the given screen coordinates, or nil if
+// there is nothing but the background at that point. This can be used for
+// touch input, for example.
+func (e *Engine) ObjectAt(x, y int16) Element {
+	return e.root.ObjectAt(x, y)
+}
+
+// BringToFront moves the given object to the top of the stacking order, see
+// Layer.BringToFront.
+func (e *Engine) BringToFront(obj Element) {
+	e.root.BringToFront(obj)
+}
+
+// SendToBack moves the given object to the bottom of the stacking order, see
+// Layer.SendToBack.
+func (e *Engine) SendToBack(obj Element) {
+	e.root.SendToBack(obj)
+}
+
+// Clear removes all objects (including layers) from the display, so that only
+// the background color remains. The whole screen will be repainted on the next
+// call to Display. Removed objects must not be used anymore afterwards.
+func (e *Engine) Clear() {
+	for i := range e.root.objects {
+		e.root.objects[i] = nil
+	}
+	e.root.objects = e.root.objects[:0]
+	e.InvalidateAll()
+}
+
+// InvalidateRegion marks all tiles that overlap the given area of the screen
+// as needing to be redrawn on the next call to Display. This is useful after
+// drawing directly to the display, bypassing the engine.
+func (e *Engine) InvalidateRegion(x, y, width, height int16) {
+	// The viewport scrolls the root layer, so convert the screen coordinates
+	// to coordinates in the root layer first.
+	x1 := x + e.root.scrollX
+	y1 := y + e.root.scrollY
+	r := Rectangle{parent: e.root}
+	r.invalidate(x1, y1, x1+width, y1+height)
+
+	// The contents of the display in this area are unknown now, so the tiles
+	// must be sent even if they didn't change.
+	if e.tileHashes == nil || width <= 0 || height <= 0 {
+		return
+	}
+	for tileY := range e.tileHashes {
+		for tileX := range e.tileHashes[tileY] {
+			x1 := int16(tileX) * e.tileSize
+			y1 := int16(tileY) * e.tileSize
+			if x1 < x+width && y1 < y+height && x1+e.tileSize > x && y1+e.tileSize > y {
+				e.tileHashes[tileY][tileX] = 0
+			}
+		}
+	}
+}
+
+// InvalidateAll marks all tiles as needing to be redrawn, so that the whole
+// screen will be repainted on the next call to Display.
+func (e *Engine) InvalidateAll() {
+	for _, row := range e.cleanTiles {
+		for i := range row {
+			row[i] = false
+		}
+	}
+	for _, row := range e.tileHashes {
+		for i := range row {
+			row[i] = 0
+		}
+	}
+}
+
+// getTile returns a reusable tile with the given size from the tile pool,
+// without allocating a new tile if possible. It should be returned to the tile
+// pool after use with putTile.
+func (e *Engine) getTile(width, height int16) *tile {
 	if len(e.tilePool) != 0 {
 		// A reusable tile was found.
 		t := e.tilePool[len(e.tilePool)-1]
 		e.tilePool = e.tilePool[:len(e.tilePool)-1]
+		if size := int(width) * int(height); cap(t.pixels) >= size {
+			t.pixels = t.pixels[:size]
+		} else {
+			// The tile is too small, for example because it was used for a
+			// smaller region before.
+			t.pixels = make([]color.RGBA, size)
+		}
+		t.width = width
+		t.height = height
+		t.stride = width
 		return t
 	}
 	// No reusable tile was found, make a new one.
-	return &tile{}
+	return newTile(width, height)
 }
 
 // putTile returns a tile back to the tile pool that isn't used anymore.
@@ -132,34 +656,630 @@ func (e *Engine) putTile(t *tile) {
 	e.tilePool = append(e.tilePool, t)
 }
 
-// Display updates the display with all the changes that have been done since
-// the last update.
-func (e *Engine) Display() {
-	tilesDrawn := 0
-	for row, cleanTilesRow := range e.cleanTiles {
-		for col, cleanTile := range cleanTilesRow {
-			if cleanTile {
-				// Already updated.
+// PaintRegion paints the given area of the screen into the buffer, instead of
+// sending it to the display. The buffer is in row major order and must have a
+// length of exactly width*height. The area may be of any size and doesn't need
+// to be aligned to tiles. This does not change which tiles need to be updated
+// on the next call to Display.
+func (e *Engine) PaintRegion(x, y, width, height int16, buffer []color.RGBA) error {
+	if width < 0 || height < 0 || len(buffer) != int(width)*int(height) {
+		return ErrBufferSizeMismatch
+	}
+	e.paintBuffer(x, y, width, height, buffer, e.root.paint)
+	return nil
+}
+
+// paintBuffer calls paint for every part of the width*height buffer (in row
+// major order) of at most one tile, as if the buffer was one big tile at the
+// given coordinates. Each part is painted in a temporary tile that is copied
+// from and back into the buffer, because the pixel indices of a tile don't fit
+// in an int16 for large buffers.
+func (e *Engine) paintBuffer(x, y, width, height int16, buffer []color.RGBA, paint func(t *tile, tileX, tileY int16)) {
+	for partY := int16(0); partY < height; partY += e.tileSize {
+		partHeight := height - partY
+		if partHeight > e.tileSize {
+			partHeight = e.tileSize
+		}
+		for partX := int16(0); partX < width; partX += e.tileSize {
+			partWidth := width - partX
+			if partWidth > e.tileSize {
+				partWidth = e.tileSize
+			}
+			t := e.getTile(partWidth, partHeight)
+			for row := int16(0); row < partHeight; row++ {
+				start := int(partY+row)*int(width) + int(partX)
+				copy(t.pixels[row*t.stride:row*t.stride+partWidth], buffer[start:])
+			}
+			paint(t, x+partX, y+partY)
+			for row := int16(0); row < partHeight; row++ {
+				start := int(partY+row)*int(width) + int(partX)
+				copy(buffer[start:start+int(partWidth)], t.pixels[row*t.stride:])
+			}
+			e.putTile(t)
+		}
+	}
+}
+
+// Snapshot paints the whole screen into a new image, as it would look on the
+// display if everything was redrawn. It paints every tile, so it is relatively
+// slow, but it doesn't touch the display and doesn't change which tiles need to
+// be updated on the next call to Display. This is useful for screenshots and
+// tests.
+//
+// With a transparent background (see SetTransparentBackground), pixels are
+// blended over the background color like Display does. Tiles where nothing is
+// drawn at all aren't sent to the display, so they are left fully transparent
+// in the image.
+func (e *Engine) Snapshot() *image.RGBA {
+	width, height := e.display.Size()
+	if width < 0 {
+		width = 0
+	}
+	if height < 0 {
+		height = 0
+	}
+	img := image.NewRGBA(image.Rect(0, 0, int(width), int(height)))
+	t := e.getTile(e.tileSize, e.tileSize)
+	for tileY := int16(0); tileY < height; tileY += e.tileSize {
+		for tileX := int16(0); tileX < width; tileX += e.tileSize {
+			tileWidth := e.tileSize
+			tileHeight := e.tileSize
+			if tileX+tileWidth > width {
+				tileWidth = width - tileX
+			}
+			if tileY+tileHeight > height {
+				tileHeight = height - tileY
+			}
+			if !e.paintRootTile(t, tileX, tileY, tileWidth, tileHeight) {
+				// Nothing is drawn here, so leave the pixels transparent.
 				continue
 			}
-			// Will be true after this loop body finishes.
-			cleanTilesRow[col] = true
-			tilesDrawn++
+			for y := int16(0); y < tileHeight; y++ {
+				for x := int16(0); x < tileWidth; x++ {
+					img.SetRGBA(int(tileX+x), int(tileY+y), t.pixels[y*t.stride+x])
+				}
+			}
+		}
+	}
+	e.putTile(t)
+	return img
+}
 
-			// Paint tile.
-			tileX := int16(col * TileSize)
-			tileY := int16(row * TileSize)
-			e.root.paint(e.tile, tileX, tileY)
+// SetAsyncFlush enables or disables asynchronous flushing. When enabled,
+// Display sends each tile to the display from a separate goroutine while the
+// next tile is being painted, using a second tile buffer. This is faster when
+// FillRectangleWithBuffer blocks while the display is busy (for example, while
+// waiting for a SPI transfer to finish) but needs another
+// tileSize*tileSize*4 bytes of memory (or tileSize*tileSize*2 bytes for a
+// Displayer565). It is disabled by default, as goroutines might not be
+// available or be relatively expensive on some systems.
+//
+// The display is never used by more than one goroutine at a time, and Display
+// still only returns after all tiles have been sent. The goroutine is started
+// on the next call to Display and keeps running until asynchronous flushing is
+// disabled again.
+func (e *Engine) SetAsyncFlush(enabled bool) {
+	e.async = enabled
+	if !enabled {
+		e.stopAsyncFlush()
+		e.asyncTile = nil
+		e.buffer565 = nil
+	}
+}
+
+// SetTileHashing enables or disables tile hashing. When enabled, the engine
+// stores a 64-bit hash of every tile it sends to the display, and doesn't send
+// a repainted tile again if its hash is the same as before. This avoids
+// redundant updates when more of the screen is invalidated than necessary, for
+// example when an object is moved away and back again before the next call to
+// Display. It needs 8 bytes of memory per tile and some time to calculate the
+// hashes, so it is disabled by default.
+//
+// InvalidateRegion and InvalidateAll make sure the affected tiles are always
+// sent, as they are used when the contents of the display are unknown.
+func (e *Engine) SetTileHashing(enabled bool) {
+	if !enabled {
+		e.tileHashes = nil
+	} else if e.tileHashes == nil {
+		e.tileHashes = makeTileHashes(e.cleanTiles)
+	}
+}
 
-			// Draw tile in screen.
-			e.display.FillRectangleWithBuffer(tileX, tileY, TileSize, TileSize, e.tile[:])
+// makeTileHashes returns a tile hash for every tile in cleanTiles, all set to
+// unknown.
+func makeTileHashes(cleanTiles [][]bool) [][]uint64 {
+	hashes := make([][]uint64, len(cleanTiles))
+	for i, row := range cleanTiles {
+		hashes[i] = make([]uint64, len(row))
+	}
+	return hashes
+}
+
+// hash returns a hash of the given area in the top left of the tile, using
+// the 64-bit FNV-1a algorithm. The lowest bit is always set, so that the hash
+// is never 0 (which is used for unknown contents).
+func (t *tile) hash(width, height int16) uint64 {
+	hash := uint64(14695981039346656037)
+	for y := int16(0); y < height; y++ {
+		for _, c := range t.pixels[y*t.stride : y*t.stride+width] {
+			for _, b := range [4]uint8{c.R, c.G, c.B, c.A} {
+				hash ^= uint64(b)
+				hash *= 1099511628211
+			}
 		}
 	}
+	return hash | 1
+}
+
+// SetInterlaced enables or disables interlaced updates. When enabled, Display
+// sends the tiles that need to be redrawn in two passes: first every other tile
+// in a checkerboard pattern (the tiles where the sum of the tile row and column
+// is even), and then the remaining tiles. Each pass is sent in screen order. On
+// very slow displays, this makes large updates appear to resolve progressively
+// over the whole screen, instead of sweeping down slowly. It doesn't change
+// which tiles are drawn, or what they look like.
+func (e *Engine) SetInterlaced(enabled bool) {
+	e.interlaced = enabled
+}
 
-	if engineDebug {
-		println("tiles drawn:", tilesDrawn)
+// SetTransparentBackground enables or disables a transparent background. When
+// enabled, the engine doesn't paint the background color and leaves the
+// existing contents of the display alone wherever nothing is drawn, so that
+// objects appear on top of whatever is already on the display (for example a
+// photo drawn directly to the display). This is disabled by default.
+//
+// Because the engine can't read back what is on the display, this has some
+// limitations:
+//   - Tiles where nothing is drawn aren't sent to the display at all. This
+//     means that moving or removing an object leaves its old pixels on the
+//     display, unless something else is drawn over them.
+//   - Tiles where something is drawn are always sent as a whole. Pixels in
+//     those tiles that aren't fully covered are blended with the background
+//     color instead of with what is on the display.
+//
+// The whole display is repainted on the next call to Display.
+func (e *Engine) SetTransparentBackground(enabled bool) {
+	e.transparentBackground = enabled
+	e.InvalidateAll()
+}
+
+// SetStaticRegion marks the given area of the screen as static: the tiles that
+// overlap it are never sent to the display, even when objects below it change.
+// This is useful for parts of the screen that are drawn directly to the
+// display, bypassing the engine, like a status bar. To avoid locking more of
+// the screen than necessary, the area should be aligned to the tile size.
+//
+// There is only one static region: setting a new one replaces the previous
+// one, and a region with a width or height of zero removes it. The tiles of
+// the previous region will be redrawn on the next call to Display.
+func (e *Engine) SetStaticRegion(x, y, width, height int16) {
+	oldX, oldY := e.staticX1, e.staticY1
+	oldWidth, oldHeight := e.staticX2-e.staticX1, e.staticY2-e.staticY1
+	e.staticX1, e.staticY1 = x, y
+	e.staticX2, e.staticY2 = x+width, y+height
+	e.InvalidateRegion(oldX, oldY, oldWidth, oldHeight)
+}
+
+// hasStaticRegion returns whether a static region has been set.
+func (e *Engine) hasStaticRegion() bool {
+	return e.staticX1 < e.staticX2 && e.staticY1 < e.staticY2
+}
+
+// isStaticTile returns whether the tile at the given tile row and column
+// overlaps the static region.
+func (e *Engine) isStaticTile(row, col int) bool {
+	if !e.hasStaticRegion() {
+		return false
+	}
+	tileX := int16(col) * e.tileSize
+	tileY := int16(row) * e.tileSize
+	return tileX < e.staticX2 && tileY < e.staticY2 && tileX+e.tileSize > e.staticX1 && tileY+e.tileSize > e.staticY1
+}
+
+// ColorAt returns the color that the pixel at the given screen coordinates has
+// after compositing all objects, as it would be sent to the display. It paints
+// the tile containing the pixel into a scratch tile, so it is relatively slow.
+//
+// With a transparent background (see SetTransparentBackground), the pixel is
+// blended over the background color like Display does. In tiles where nothing
+// is drawn at all, the display is left as it is, so for those pixels a fully
+// transparent color is returned instead.
+func (e *Engine) ColorAt(x, y int16) color.RGBA {
+	// Find the start of the tile, rounding down for negative coordinates.
+	tileX := x / e.tileSize * e.tileSize
+	if tileX > x {
+		tileX -= e.tileSize
+	}
+	tileY := y / e.tileSize * e.tileSize
+	if tileY > y {
+		tileY -= e.tileSize
+	}
+	// Only the visible part of the tile is sent, see paintScreen. Pixels
+	// outside of the screen are treated as part of a whole tile.
+	width, height := e.display.Size()
+	width -= tileX
+	height -= tileY
+	if width > e.tileSize || width <= 0 {
+		width = e.tileSize
+	}
+	if height > e.tileSize || height <= 0 {
+		height = e.tileSize
+	}
+	t := e.getTile(e.tileSize, e.tileSize)
+	c := color.RGBA{}
+	if e.paintRootTile(t, tileX, tileY, width, height) {
+		c = t.pixels[(y-tileY)*t.stride+(x-tileX)]
+	}
+	e.putTile(t)
+	return c
+}
+
+// paintRootTile paints the root layer into the given tile at the given screen
+// coordinates, of which the top left width*height pixels are visible on the
+// screen. With a transparent background, the visible pixels are blended over
+// the background color, as the display doesn't have an alpha channel. It
+// returns false if nothing was drawn in the visible part of the tile with a
+// transparent background, in which case the display should be left as it is.
+func (e *Engine) paintRootTile(t *tile, tileX, tileY, width, height int16) bool {
+	if !e.transparentBackground {
+		e.root.paint(t, tileX, tileY)
+		return true
+	}
+
+	// Start with a transparent tile, to know which pixels have been drawn.
+	t.clear()
+	e.root.paint(t, tileX, tileY)
+	if c, ok := t.uniformColor(width, height); ok && c.A == 0 {
+		return false
+	}
+	t.blendOver(width, height, e.root.rect.color)
+	return true
+}
+
+// LastStats returns statistics about the last call to Display.
+func (e *Engine) LastStats() FrameStats {
+	return e.stats
+}
+
+// SetOnFrame sets a function that is called at the end of every call to
+// Display (even when nothing changed) with the statistics of that frame, the
+// same as returned by LastStats. This can be used to calculate the frame rate,
+// or to wait for the next vertical sync of the display. Setting it to nil
+// removes the callback again.
+func (e *Engine) SetOnFrame(onFrame func(stats FrameStats)) {
+	e.onFrame = onFrame
+}
+
+// Display updates the display with all the changes that have been done since
+// the last update. Use LastStats to see how much was redrawn. It returns false
+// if nothing changed, in which case the display isn't touched at all (not even
+// its Display method is called). When the whole screen needs to be redrawn
+// with nothing but the background color and the display has a FillScreen
+// method, the screen is filled with a single call instead of tile by tile.
+//
+// Tiles are sent in screen order: row by row from top to bottom, and from left
+// to right within a row. On displays that update immediately, large changes
+// therefore sweep down the screen instead of appearing in a random order. See
+// SetInterlaced for an alternative order.
+func (e *Engine) Display() bool {
+	start := time.Now()
+	changed := e.paintScreen()
+	e.stats.Duration = time.Since(start)
+	if e.onFrame != nil {
+		e.onFrame(e.stats)
+	}
+	return changed
+}
+
+// paintScreen paints all tiles that need an update and sends them to the
+// display, see Display. It returns whether anything was drawn.
+func (e *Engine) paintScreen() bool {
+	if e.fillScreen() {
+		e.display.Display()
+		return true
+	}
+
+	// A Displayer565 gets the painted tiles converted to RGB565. When flushing
+	// asynchronously, they are converted into a separate buffer so that the
+	// tile itself can be painted again directly after the conversion. Only
+	// the converted buffer needs to wait until it has been sent.
+	_, is565 := e.display.(Displayer565)
+
+	// When flushing asynchronously, tiles are sent to the display from a
+	// separate goroutine, which puts them back in the free channel afterwards
+	// so they can be painted again.
+	var requests chan flushRequest
+	var free chan *tile
+	var free565 chan []uint16
+	if e.async {
+		e.startAsyncFlush(is565)
+		requests = e.asyncRequests
+		free = e.asyncFree
+		free565 = e.asyncFree565
+	}
+
+	screenWidth, screenHeight := e.display.Size()
+	stats := FrameStats{}
+	// The outer slice contains the rows, so this paints the tiles in screen
+	// order (see the cleanTiles field). When interlacing, only every other tile
+	// is painted in the first pass, in a checkerboard pattern.
+	passes := 1
+	if e.interlaced {
+		passes = 2
+	}
+	for pass := 0; pass < passes; pass++ {
+		for row, cleanTilesRow := range e.cleanTiles {
+			for col, cleanTile := range cleanTilesRow {
+				if passes > 1 && (row+col)%2 != pass {
+					// This tile is painted in the other pass.
+					continue
+				}
+				if cleanTile {
+					// Already updated.
+					stats.TilesSkipped++
+					continue
+				}
+				if e.isStaticTile(row, col) {
+					// Never sent to the display.
+					cleanTilesRow[col] = true
+					stats.TilesSkipped++
+					continue
+				}
+				// Will be true after this loop body finishes.
+				cleanTilesRow[col] = true
+
+				// Paint tile.
+				t := e.tile
+				if free != nil {
+					// Wait until a tile is available again.
+					t = <-free
+				}
+				tileSize := e.tileSize
+				tileX := int16(col) * tileSize
+				tileY := int16(row) * tileSize
+
+				// Tiles on the right and bottom edge may fall partially outside of
+				// the screen, if the screen size isn't a multiple of the tile
+				// size. Only send the part of the tile that is actually visible.
+				width := tileSize
+				height := tileSize
+				if tileX+width > screenWidth {
+					width = screenWidth - tileX
+				}
+				if tileY+height > screenHeight {
+					height = screenHeight - tileY
+				}
+				if !e.paintRootTile(t, tileX, tileY, width, height) {
+					// Nothing was drawn in this tile, so leave the display as
+					// it is.
+					stats.TilesSkipped++
+					if e.tileHashes != nil {
+						e.tileHashes[row][col] = 0
+					}
+					if free != nil {
+						free <- t
+					}
+					continue
+				}
+				if e.tileHashes != nil {
+					// Don't send the tile when it is exactly the same as what is
+					// already on the display.
+					hash := t.hash(width, height)
+					if e.tileHashes[row][col] == hash {
+						stats.TilesUnchanged++
+						if free != nil {
+							free <- t
+						}
+						continue
+					}
+					e.tileHashes[row][col] = hash
+				}
+				stats.TilesDrawn++
+				req := flushRequest{
+					x:      tileX,
+					y:      tileY,
+					width:  width,
+					height: height,
+					tile:   t,
+				}
+				if c, ok := t.uniformColor(width, height); ok {
+					// The whole tile has a single color. Sending just the color
+					// is usually a lot cheaper than sending all pixels.
+					req.uniform = true
+					req.color = c
+					stats.BytesSent += 4
+				} else if is565 {
+					// Convert the visible part of the tile, which makes it
+					// contiguous in memory at the same time.
+					buffer := t.pixels565()
+					if free565 != nil {
+						// Wait until the buffer is available again.
+						buffer = <-free565
+					}
+					t.convertRGB565(width, height, buffer)
+					req.buffer565 = buffer
+					stats.BytesSent += int(width) * int(height) * 2
+				} else {
+					if width != tileSize {
+						// Make the visible part of the tile contiguous in memory,
+						// as required by FillRectangleWithBuffer. This works in
+						// place because each row is moved to a lower (or the same)
+						// index.
+						pixels := t.pixels
+						for y := int16(0); y < height; y++ {
+							copy(pixels[y*width:(y+1)*width], pixels[y*t.stride:y*t.stride+width])
+						}
+					}
+					stats.BytesSent += int(width) * int(height) * 4
+				}
+
+				// Draw tile in screen.
+				if requests != nil {
+					requests <- req
+				} else {
+					e.flush(req)
+				}
+			}
+		}
+	}
+	if requests != nil {
+		// Wait until all tiles have been sent.
+		requests <- flushRequest{}
+		<-e.asyncDone
+	}
+	e.stats = stats
+	if stats.TilesDrawn == 0 {
+		// Nothing was drawn, so there is nothing to send to the screen.
+		return false
 	}
 
 	// Send the update to the screen. Not all Displayer implementations need this.
 	e.display.Display()
+	return true
+}
+
+// startAsyncFlush starts the goroutine that sends tiles to the display when
+// flushing asynchronously, together with the channels to communicate with it.
+// They are reused for every frame, and only need to be set up again when the
+// display changes between a Displayer565 and a normal Displayer.
+func (e *Engine) startAsyncFlush(is565 bool) {
+	if e.asyncRequests != nil && e.async565 == is565 {
+		// Already running.
+		return
+	}
+	e.stopAsyncFlush()
+
+	requests := make(chan flushRequest)
+	done := make(chan struct{})
+	var free chan *tile
+	var free565 chan []uint16
+	if is565 {
+		if e.buffer565 == nil {
+			e.buffer565 = make([]uint16, int(e.tileSize)*int(e.tileSize))
+		}
+		free565 = make(chan []uint16, 1)
+		free565 <- e.buffer565
+	} else {
+		if e.asyncTile == nil {
+			e.asyncTile = newTile(e.tileSize, e.tileSize)
+		}
+		free = make(chan *tile, 2)
+		free <- e.tile
+		free <- e.asyncTile
+	}
+	go func() {
+		for req := range requests {
+			if req.tile == nil {
+				// All tiles of this frame have been sent.
+				done <- struct{}{}
+				continue
+			}
+			e.flush(req)
+			if req.buffer565 != nil {
+				free565 <- req.buffer565
+			} else if free != nil {
+				free <- req.tile
+			}
+		}
+	}()
+	e.asyncRequests = requests
+	e.asyncDone = done
+	e.asyncFree = free
+	e.asyncFree565 = free565
+	e.async565 = is565
+}
+
+// stopAsyncFlush stops the goroutine started by startAsyncFlush, if it is
+// running.
+func (e *Engine) stopAsyncFlush() {
+	if e.asyncRequests == nil {
+		return
+	}
+	close(e.asyncRequests)
+	e.asyncRequests = nil
+	e.asyncDone = nil
+	e.asyncFree = nil
+	e.asyncFree565 = nil
+}
+
+// flushRequest is a painted tile that should be sent to the display. A request
+// without a tile marks the end of a frame, see startAsyncFlush.
+type flushRequest struct {
+	x, y, width, height int16
+	tile                *tile
+
+	// uniform is set when the tile has only one color, in which case only the
+	// color is sent to the display.
+	uniform bool
+	color   color.RGBA
+
+	// buffer565 is set when the tile has been converted to RGB565 for a
+	// Displayer565, in which case it is sent instead of the tile.
+	buffer565 []uint16
+}
+
+// fillScreen fills the whole screen at once with the background color of the
+// root layer, if there is nothing else to draw, all tiles need an update and
+// the display supports it. It returns whether the screen was filled, in which
+// case all tiles are up-to-date and the statistics are updated.
+func (e *Engine) fillScreen() bool {
+	filler, ok := e.display.(screenFiller)
+	if !ok || len(e.root.objects) != 0 || e.root.background != nil || e.root.clipped {
+		return false
+	}
+	if e.hasStaticRegion() || e.transparentBackground {
+		// Filling the screen would overwrite the static region, or what is
+		// already on the display.
+		return false
+	}
+	stats := FrameStats{}
+	for _, row := range e.cleanTiles {
+		for _, clean := range row {
+			if clean {
+				stats.TilesSkipped++
+			} else {
+				stats.TilesDrawn++
+			}
+		}
+	}
+	if stats.TilesSkipped != 0 {
+		// Only part of the screen changed, which is cheaper to paint tile by
+		// tile than to send the whole screen again.
+		return false
+	}
+	if err := filler.FillScreen(e.root.rect.color); err != nil {
+		// The display couldn't fill the screen, so paint it tile by tile
+		// instead.
+		return false
+	}
+	for _, row := range e.cleanTiles {
+		for i := range row {
+			row[i] = true
+		}
+	}
+	for _, row := range e.tileHashes {
+		for i := range row {
+			row[i] = 0
+		}
+	}
+	// Only a single color was sent, see FrameStats.BytesSent.
+	stats.BytesSent = 4
+	if _, ok := e.display.(Displayer565); ok {
+		stats.BytesSent = 2
+	}
+	e.stats = stats
+	return true
+}
+
+// flush sends the given painted tile to the display.
+func (e *Engine) flush(req flushRequest) {
+	if req.uniform {
+		e.display.FillRectangle(req.x, req.y, req.width, req.height, req.color)
+		return
+	}
+	if req.buffer565 != nil {
+		e.display.(Displayer565).FillRectangleWithBuffer565(req.x, req.y, req.width, req.height, req.buffer565[:req.width*req.height])
+		return
+	}
+	e.display.FillRectangleWithBuffer(req.x, req.y, req.width, req.height, req.tile.pixels[:req.width*req.height])
 }
@@ -32,6 +32,17 @@ func main() {
 	lastPrint := time.Now()
 	sumElapsed := time.Duration(0)
 	numCycles := 0
+	engine.SetOnFrame(func(stats tilegraphics.FrameStats) {
+		sumElapsed += stats.Duration
+		numCycles++
+		if now := time.Now(); lastPrint.Add(printInfoEvery).Before(now) {
+			avgDuration := sumElapsed / time.Duration(numCycles)
+			print("drawing: ", time.Second/avgDuration, "fps ", avgDuration.String(), "\r\n")
+			sumElapsed = 0
+			numCycles = 0
+			lastPrint = now
+		}
+	})
 	move := int16(1)
 	for {
 		start := time.Now()
@@ -48,20 +59,9 @@ func main() {
 		engine.Display()
 
 		// Sleep for a bit, trying to reach 60fps.
-		elapsed := time.Since(start)
-		sleepTime := time.Second/60 - elapsed
+		sleepTime := time.Second/60 - time.Since(start)
 		if sleepTime > 0 {
 			time.Sleep(sleepTime)
 		}
-
-		sumElapsed += elapsed
-		numCycles++
-		if lastPrint.Add(printInfoEvery).Before(start) {
-			avgDuration := sumElapsed / time.Duration(numCycles)
-			print("drawing: ", time.Second/avgDuration, "fps ", avgDuration.String(), "\r\n")
-			sumElapsed = 0
-			numCycles = 0
-			lastPrint = start
-		}
 	}
 }
@@ -0,0 +1,67 @@
+package tilegraphics
+
+import "image/color"
+
+// Fade is an animation that changes the alpha of the background color of a
+// layer in a fixed number of steps, see Layer.FadeTo. It doesn't use a timer or
+// goroutine: call Step once for every frame, for example right before calling
+// Engine.Display.
+type Fade struct {
+	layer *Layer
+	color color.RGBA // background color with straight alpha
+	from  uint8
+	to    uint8
+	steps int
+	step  int
+}
+
+// FadeTo returns an animation that changes the alpha of the background color
+// of this layer from the current alpha to the target alpha in the given number
+// of steps. The alpha changes linearly, and because colors are premultiplied in
+// linear color space (see Blend) the layer fades in a gamma-correct way.
+//
+// A fully transparent background has no color, so to fade in a layer first set
+// its background color to the final color with a small (non-zero) alpha. The
+// root layer is always fully opaque, so fading it has no visible effect.
+func (l *Layer) FadeTo(targetAlpha uint8, steps int) *Fade {
+	if steps < 1 {
+		steps = 1
+	}
+	return &Fade{
+		layer: l,
+		color: Unpremultiply(l.rect.color),
+		from:  l.rect.color.A,
+		to:    targetAlpha,
+		steps: steps,
+	}
+}
+
+// Step advances the animation by one step and updates the background color of
+// the layer, which invalidates it. It returns true if the step changed the
+// background color (including the step that reaches the target alpha), and
+// false once the animation was already done, so that a loop like this displays
+// every frame including the last:
+//
+//	for fade.Step() {
+//		engine.Display()
+//	}
+func (f *Fade) Step() bool {
+	if f.Done() {
+		return false
+	}
+	f.step++
+	f.layer.SetBackgroundColor(Premultiply(color.RGBA{f.color.R, f.color.G, f.color.B, f.Alpha()}))
+	return true
+}
+
+// Alpha returns the background alpha after the current step, rounded to the
+// nearest value.
+func (f *Fade) Alpha() uint8 {
+	sum := int(f.from)*(f.steps-f.step) + int(f.to)*f.step
+	return uint8((sum + f.steps/2) / f.steps)
+}
+
+// Done returns whether the animation has reached the target alpha.
+func (f *Fade) Done() bool {
+	return f.step >= f.steps
+}
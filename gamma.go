@@ -0,0 +1,159 @@
+package tilegraphics
+
+// GammaMode selects how gamma is handled when blending colors. See Blend for
+// details.
+type GammaMode uint8
+
+const (
+	// GammaFast uses a gamma of 2.0, which is close to the ideal gamma of
+	// ~2.2 but much faster to calculate. This is the default.
+	GammaFast GammaMode = iota
+
+	// GammaAccurate uses the more accurate gamma of 2.2, using a lookup
+	// table. It is slower than GammaFast, especially when encoding colors.
+	GammaAccurate
+
+	// GammaFastTable gives exactly the same results as GammaFast, but uses
+	// lookup tables (768 bytes) when encoding colors instead of a square root
+	// approximation. This makes blending faster on most microcontrollers, at
+	// the cost of some flash.
+	GammaFastTable
+)
+
+// gammaMode is the currently active gamma mode.
+var gammaMode = GammaFast
+
+// SetGammaMode changes the way colors are blended in the whole package (for
+// all engines). It should be set before creating any engine, as changing it
+// doesn't redraw any objects.
+func SetGammaMode(mode GammaMode) {
+	gammaMode = mode
+}
+
+// decodeGammaAccurate decodes a gamma-encoded value using the accurate
+// gamma-2.2 curve, to a linear value in the range 0..(2**24-1).
+func decodeGammaAccurate(component uint8) uint32 {
+	return gammaDecodeTable[component]
+}
+
+// encodeGammaAccurate is the inverse of decodeGammaAccurate. It returns the
+// highest 8-bit value that doesn't decode to a value above the given linear
+// value: a binary search over the decode table.
+func encodeGammaAccurate(x uint32) uint8 {
+	if x >= gammaDecodeTable[255] {
+		return 255
+	}
+	low, high := 0, 255 // gammaDecodeTable[low] <= x < gammaDecodeTable[high]
+	for high-low > 1 {
+		mid := (low + high) / 2
+		if gammaDecodeTable[mid] <= x {
+			low = mid
+		} else {
+			high = mid
+		}
+	}
+	return uint8(low)
+}
+
+// gammaDecodeTable contains the linear intensity of each 8-bit gamma-encoded
+// value, using a gamma of 2.2. It has been generated using:
+//
+//	round((2**24-1) * (n/255)**2.2)
+var gammaDecodeTable = [256]uint32{
+	0, 85, 391, 955, 1798, 2938, 4388, 6160,
+	8263, 10707, 13500, 16649, 20162, 24044, 28302, 32941,
+	37966, 43383, 49196, 55410, 62029, 69058, 76500, 84359,
+	92640, 101344, 110477, 120042, 130041, 140478, 151356, 162677,
+	174446, 186665, 199336, 212462, 226046, 240091, 254598, 269571,
+	285012, 300923, 317307, 334166, 351502, 369317, 387613, 406394,
+	425659, 445413, 465656, 486391, 507620, 529344, 551566, 574288,
+	597510, 621235, 645466, 670202, 695447, 721202, 747469, 774249,
+	801544, 829356, 857686, 886535, 915906, 945800, 976219, 1007163,
+	1038635, 1070636, 1103167, 1136230, 1169826, 1203957, 1238624, 1273829,
+	1309572, 1345856, 1382681, 1420049, 1457961, 1496419, 1535423, 1574976,
+	1615077, 1655730, 1696934, 1738692, 1781003, 1823870, 1867294, 1911276,
+	1955817, 2000918, 2046581, 2092806, 2139595, 2186948, 2234868, 2283355,
+	2332410, 2382034, 2432229, 2482995, 2534333, 2586246, 2638733, 2691795,
+	2745435, 2799652, 2854448, 2909824, 2965781, 3022319, 3079441, 3137146,
+	3195437, 3254313, 3313776, 3373826, 3434466, 3495695, 3557514, 3619926,
+	3682930, 3746527, 3810718, 3875505, 3940888, 4006868, 4073447, 4140624,
+	4208401, 4276778, 4345757, 4415339, 4485524, 4556313, 4627707, 4699707,
+	4772313, 4845528, 4919350, 4993782, 5068824, 5144477, 5220742, 5297619,
+	5375110, 5453215, 5531935, 5611271, 5691223, 5771792, 5852980, 5934787,
+	6017214, 6100261, 6183929, 6268220, 6353133, 6438670, 6524831, 6611617,
+	6699030, 6787068, 6875735, 6965029, 7054952, 7145505, 7236688, 7328501,
+	7420947, 7514025, 7607737, 7702082, 7797062, 7892677, 7988928, 8085816,
+	8183342, 8281505, 8380307, 8479749, 8579831, 8680554, 8781918, 8883925,
+	8986574, 9089868, 9193805, 9298387, 9403615, 9509489, 9616010, 9723178,
+	9830995, 9939460, 10048575, 10158340, 10268756, 10379823, 10491543, 10603915,
+	10716940, 10830620, 10944954, 11059943, 11175588, 11291889, 11408847, 11526464,
+	11644738, 11763671, 11883264, 12003517, 12124431, 12246006, 12368243, 12491143,
+	12614705, 12738932, 12863823, 12989378, 13115599, 13242486, 13370040, 13498261,
+	13627150, 13756707, 13886934, 14017829, 14149395, 14281632, 14414540, 14548119,
+	14682371, 14817296, 14952895, 15089167, 15226114, 15363737, 15502035, 15641009,
+	15780660, 15920989, 16061995, 16203680, 16346044, 16489087, 16632811, 16777215,
+}
+
+// encodeGammaTable returns the same value as encodeGammaFast, using lookup
+// tables. The top bits of the linear value select a starting value, which is
+// then corrected upwards using the thresholds of the following values. This
+// takes at most a few steps except for very dark colors.
+func encodeGammaTable(x uint32) uint8 {
+	if x >= 255*255 {
+		return 255
+	}
+	a := gammaEncodeStart[uint8(x>>8)]
+	for a < 255 && x >= uint32(gammaEncodeThreshold[a+1]) {
+		a++
+	}
+	return a
+}
+
+// gammaEncodeStart contains the result of encodeGammaFast for the linear
+// values n*256, as a starting point for encodeGammaTable.
+var gammaEncodeStart = [256]uint8{
+	0, 16, 22, 27, 32, 35, 39, 42, 45, 48, 50, 53, 55, 57, 59, 61,
+	64, 65, 67, 69, 71, 73, 75, 76, 78, 80, 81, 83, 84, 86, 87, 89,
+	90, 91, 93, 94, 96, 97, 98, 99, 101, 102, 103, 104, 106, 107, 108, 109,
+	110, 112, 113, 114, 115, 116, 117, 118, 119, 120, 121, 122, 123, 124, 125, 126,
+	128, 128, 129, 130, 131, 132, 133, 134, 135, 136, 137, 138, 139, 140, 141, 142,
+	143, 144, 144, 145, 146, 147, 148, 149, 150, 150, 151, 152, 153, 154, 155, 155,
+	156, 157, 158, 159, 160, 160, 161, 162, 163, 163, 164, 165, 166, 167, 167, 168,
+	169, 170, 170, 171, 172, 173, 173, 174, 175, 176, 176, 177, 178, 178, 179, 180,
+	181, 181, 182, 183, 183, 184, 185, 185, 186, 187, 187, 188, 189, 189, 190, 191,
+	192, 192, 193, 193, 194, 195, 195, 196, 197, 197, 198, 199, 199, 200, 201, 201,
+	202, 203, 203, 204, 204, 205, 206, 206, 207, 208, 208, 209, 209, 210, 211, 211,
+	212, 212, 213, 214, 214, 215, 215, 216, 217, 217, 218, 218, 219, 219, 220, 221,
+	221, 222, 222, 223, 224, 224, 225, 225, 226, 226, 227, 228, 228, 229, 229, 230,
+	230, 231, 231, 232, 233, 233, 234, 234, 235, 235, 236, 236, 237, 237, 238, 239,
+	239, 240, 240, 241, 241, 242, 242, 243, 243, 244, 244, 245, 245, 246, 246, 247,
+	247, 248, 249, 249, 250, 250, 251, 251, 252, 252, 253, 253, 254, 254, 255, 255,
+}
+
+// gammaEncodeThreshold contains the lowest linear value for which
+// encodeGammaFast returns at least n. Both tables have been generated by
+// running encodeGammaFast over all inputs in the range 0..255*255.
+var gammaEncodeThreshold = [256]uint16{
+	0, 1, 4, 9, 15, 24, 35, 48, 63, 80, 99, 120,
+	144, 169, 196, 225, 256, 289, 324, 361, 400, 441, 484, 529,
+	575, 624, 675, 728, 783, 840, 899, 960, 1024, 1088, 1155, 1224,
+	1295, 1368, 1443, 1520, 1599, 1681, 1764, 1849, 1936, 2025, 2116, 2209,
+	2304, 2401, 2500, 2601, 2704, 2809, 2916, 3025, 3136, 3249, 3364, 3481,
+	3600, 3720, 3843, 3968, 4095, 4224, 4355, 4488, 4623, 4760, 4899, 5040,
+	5183, 5328, 5475, 5624, 5775, 5928, 6083, 6240, 6399, 6560, 6723, 6888,
+	7055, 7224, 7395, 7568, 7743, 7920, 8099, 8280, 8463, 8648, 8835, 9024,
+	9215, 9408, 9603, 9800, 9999, 10200, 10404, 10609, 10816, 11025, 11236, 11449,
+	11664, 11881, 12100, 12321, 12544, 12769, 12996, 13225, 13456, 13689, 13924, 14161,
+	14400, 14641, 14884, 15129, 15376, 15625, 15876, 16129, 16384, 16641, 16900, 17161,
+	17424, 17689, 17956, 18225, 18496, 18769, 19044, 19321, 19600, 19881, 20164, 20449,
+	20736, 21025, 21316, 21609, 21904, 22201, 22500, 22801, 23104, 23409, 23716, 24025,
+	24336, 24649, 24964, 25281, 25600, 25921, 26244, 26569, 26896, 27225, 27556, 27889,
+	28224, 28561, 28900, 29241, 29584, 29929, 30276, 30625, 30976, 31329, 31683, 32040,
+	32399, 32760, 33123, 33488, 33855, 34224, 34595, 34968, 35343, 35720, 36099, 36480,
+	36863, 37248, 37635, 38024, 38415, 38808, 39200, 39597, 39996, 40397, 40800, 41205,
+	41612, 42021, 42432, 42845, 43260, 43677, 44091, 44512, 44935, 45360, 45787, 46216,
+	46647, 47080, 47515, 47952, 48391, 48825, 49268, 49713, 50160, 50609, 51060, 51513,
+	51968, 52425, 52875, 53336, 53799, 54264, 54731, 55200, 55671, 56133, 56608, 57085,
+	57564, 58045, 58528, 59013, 59500, 59976, 60467, 60960, 61455, 61952, 62451, 62937,
+	63440, 63945, 64452, 64961,
+}
@@ -0,0 +1,155 @@
+package tilegraphics
+
+import (
+	"image/color"
+	"math/rand"
+	"testing"
+
+	"github.com/aykevl/tilegraphics/imagescreen"
+)
+
+// Benchmarks for the most common operations, all painting to an imagescreen of
+// the size of a typical small SPI display. They report allocations, as the
+// engine should not allocate in the steady state (thanks to the tile pool).
+
+const (
+	benchScreenWidth  = 160
+	benchScreenHeight = 128
+)
+
+// newBenchEngine returns a new engine with an imagescreen and a dark gray
+// background.
+func newBenchEngine() *Engine {
+	engine := NewEngine(imagescreen.NewScreen(benchScreenWidth, benchScreenHeight))
+	engine.SetBackgroundColor(color.RGBA{50, 50, 50, 255})
+	return engine
+}
+
+// Benchmark repainting the whole screen with a few objects on it.
+func BenchmarkFullRepaint(b *testing.B) {
+	engine := newBenchEngine()
+	engine.NewRectangle(13, 20, 30, 21, color.RGBA{255, 0, 0, 255})
+	engine.NewCircle(100, 70, 30, color.RGBA{0, 0, 255, 255})
+	engine.NewLine(0, 127, 159, 0, color.RGBA{255, 255, 255, 255})
+	engine.Display()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		engine.InvalidateAll()
+		engine.Display()
+	}
+}
+
+// Benchmark moving a small rectangle around, which only repaints the few tiles
+// around it.
+func BenchmarkMoveRectangle(b *testing.B) {
+	engine := newBenchEngine()
+	rect := engine.NewRectangle(10, 10, 20, 20, color.RGBA{255, 0, 0, 255})
+	engine.Display()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rect.Move(10+int16(i%100), 10+int16(i%80), 20, 20)
+		engine.Display()
+	}
+}
+
+// Benchmark moving 20 overlapping rectangles in a single frame. The number of
+// redrawn tiles per frame is reported as well: overlapping changes don't cause
+// a tile to be painted more than once.
+func BenchmarkMoveManyRectangles(b *testing.B) {
+	engine := newBenchEngine()
+	var rects []*Rectangle
+	for i := int16(0); i < 20; i++ {
+		rects = append(rects, engine.NewRectangle(10+i*4, 10+i*3, 40, 30, color.RGBA{uint8(i * 12), 0, 255, 255}))
+	}
+	engine.Display()
+	b.ReportAllocs()
+	b.ResetTimer()
+	tiles := 0
+	for i := 0; i < b.N; i++ {
+		for j, rect := range rects {
+			rect.Move(10+int16(j)*4+int16(i%2)*5, 10+int16(j)*3, 40, 30)
+		}
+		engine.Display()
+		tiles += engine.LastStats().TilesDrawn
+	}
+	b.ReportMetric(float64(tiles)/float64(b.N), "tiles/op")
+}
+
+// Benchmark painting 100 random (partially transparent) lines over the whole
+// screen.
+func BenchmarkRandomLines(b *testing.B) {
+	rand := rand.New(rand.NewSource(1))
+	engine := newBenchEngine()
+	for i := 0; i < 100; i++ {
+		x1 := int16(rand.Uint32() % benchScreenWidth)
+		y1 := int16(rand.Uint32() % benchScreenHeight)
+		x2 := int16(rand.Uint32() % benchScreenWidth)
+		y2 := int16(rand.Uint32() % benchScreenHeight)
+		lineColor := color.RGBA{uint8(rand.Uint32()), uint8(rand.Uint32()), uint8(rand.Uint32()), 255}
+		if i%2 == 0 {
+			lineColor = ApplyAlpha(lineColor, uint8(rand.Uint32()))
+		}
+		engine.NewLine(x1, y1, x2, y2, lineColor)
+	}
+	engine.Display()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		engine.InvalidateAll()
+		engine.Display()
+	}
+}
+
+// Benchmark compositing nested layers with a transparent background and a
+// layer opacity.
+func BenchmarkTransparentLayers(b *testing.B) {
+	engine := newBenchEngine()
+	layer := engine.NewLayer(10, 10, 140, 108, color.RGBA{0, 0, 100, 150})
+	layer.NewRectangle(20, 20, 50, 40, color.RGBA{255, 0, 0, 255})
+	inner := layer.NewLayer(60, 30, 70, 70, color.RGBA{0, 100, 0, 100})
+	inner.NewCircle(35, 35, 30, color.RGBA{127, 127, 0, 127})
+	inner.SetOpacity(200)
+	engine.Display()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		engine.InvalidateAll()
+		engine.Display()
+	}
+}
+
+// Benchmark rendering the whole screen without a display using PaintRegion.
+func BenchmarkPaintRegion(b *testing.B) {
+	engine := newBenchEngine()
+	engine.NewRectangle(13, 20, 30, 21, color.RGBA{255, 0, 0, 255})
+	engine.NewCircle(100, 70, 30, color.RGBA{0, 0, 255, 127})
+	buffer := make([]color.RGBA, benchScreenWidth*benchScreenHeight)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		engine.PaintRegion(0, 0, benchScreenWidth, benchScreenHeight, buffer)
+	}
+}
+
+// Benchmark blending a single pixel.
+func BenchmarkBlend(b *testing.B) {
+	bottom := color.RGBA{50, 100, 150, 255}
+	top := color.RGBA{100, 0, 50, 127}
+	for i := 0; i < b.N; i++ {
+		bottom = Blend(bottom, top)
+	}
+}
+
+// Benchmark blending a single pixel, using the lookup tables of GammaFastTable
+// to encode the result.
+func BenchmarkBlendTable(b *testing.B) {
+	SetGammaMode(GammaFastTable)
+	defer SetGammaMode(GammaFast)
+	bottom := color.RGBA{50, 100, 150, 255}
+	top := color.RGBA{100, 0, 50, 127}
+	for i := 0; i < b.N; i++ {
+		bottom = Blend(bottom, top)
+	}
+}
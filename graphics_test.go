@@ -1,16 +1,23 @@
 package tilegraphics
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"image"
 	"image/color"
 	"image/png"
+	"math"
 	"math/rand"
 	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/aykevl/tilegraphics/imagescreen"
+	"github.com/aykevl/tilegraphics/recordscreen"
 )
 
 var flagUpdate = flag.Bool("update", false, "Update images based on test output.")
@@ -97,182 +104,3354 @@ func TestRectUpdate(t *testing.T) {
 	}
 }
 
+// Change the color of a rectangle a few times and check whether the result is
+// the same as creating a new rectangle with that color.
+func TestRectSetColor(t *testing.T) {
+	colors := []color.RGBA{
+		{255, 0, 0, 255},
+		{0, 100, 0, 100},
+		{0, 0, 0, 0},
+		{200, 200, 0, 200},
+		{0, 0, 255, 255},
+	}
+
+	screen := imagescreen.NewScreen(100, 100)
+	engine := NewEngine(screen)
+	engine.SetBackgroundColor(color.RGBA{50, 50, 50, 255})
+	engine.NewRectangle(5, 5, 40, 40, color.RGBA{0, 255, 255, 255})
+	rect := engine.NewRectangle(13, 21, 60, 50, color.RGBA{255, 255, 255, 255})
+	engine.Display()
+
+	for i, c := range colors {
+		rect.SetColor(c)
+		engine.Display()
+
+		reference := imagescreen.NewScreen(100, 100)
+		referenceEngine := NewEngine(reference)
+		referenceEngine.SetBackgroundColor(color.RGBA{50, 50, 50, 255})
+		referenceEngine.NewRectangle(5, 5, 40, 40, color.RGBA{0, 255, 255, 255})
+		referenceEngine.NewRectangle(13, 21, 60, 50, c)
+		referenceEngine.Display()
+		if err := sameImage(screen, reference); err != nil {
+			t.Errorf("changing the rectangle color to %v resulted in a different image: %v", c, err)
+			saveTemporaryImages(t, "RectSetColor", i, screen, reference)
+		}
+	}
+}
+
+// Remove a rectangle that overlaps another and check whether the result is the
+// same as never having drawn it at all.
+func TestRectRemove(t *testing.T) {
+	screen := imagescreen.NewScreen(100, 100)
+	engine := NewEngine(screen)
+	engine.SetBackgroundColor(color.RGBA{50, 50, 50, 255})
+	engine.NewRectangle(10, 10, 50, 50, color.RGBA{255, 0, 0, 255})
+	top := engine.NewRectangle(33, 29, 50, 60, color.RGBA{0, 0, 255, 255})
+	engine.Display()
+
+	top.Remove()
+	top.Remove() // no-op: not in the layer anymore
+	engine.Display()
+
+	reference := imagescreen.NewScreen(100, 100)
+	referenceEngine := NewEngine(reference)
+	referenceEngine.SetBackgroundColor(color.RGBA{50, 50, 50, 255})
+	referenceEngine.NewRectangle(10, 10, 50, 50, color.RGBA{255, 0, 0, 255})
+	referenceEngine.Display()
+	if err := sameImage(screen, reference); err != nil {
+		t.Errorf("removing a rectangle resulted in a different image: %v", err)
+		saveTemporaryImages(t, "RectRemove", 0, screen, reference)
+	}
+}
+
+// Change the stacking order of overlapping rectangles, and compare against
+// rectangles created in the resulting order.
+func TestRectOrder(t *testing.T) {
+	colors := []color.RGBA{
+		{255, 0, 0, 255},
+		{0, 255, 0, 255},
+		{0, 0, 127, 127},
+	}
+	positions := [][2]int16{{10, 10}, {25, 30}, {40, 20}}
+
+	screen := imagescreen.NewScreen(100, 100)
+	engine := NewEngine(screen)
+	rects := make([]*Rectangle, len(colors))
+	for i, c := range colors {
+		rects[i] = engine.NewRectangle(positions[i][0], positions[i][1], 45, 45, c)
+	}
+	engine.Display()
+
+	checkOrder := func(name string, order []int) {
+		engine.Display()
+		reference := imagescreen.NewScreen(100, 100)
+		referenceEngine := NewEngine(reference)
+		for _, i := range order {
+			referenceEngine.NewRectangle(positions[i][0], positions[i][1], 45, 45, colors[i])
+		}
+		referenceEngine.Display()
+		if err := sameImage(screen, reference); err != nil {
+			t.Errorf("%s: stacking order differs from %v: %v", name, order, err)
+			saveTemporaryImages(t, "RectOrder-"+name, 0, screen, reference)
+		}
+	}
+
+	engine.BringToFront(rects[0])
+	checkOrder("BringToFront", []int{1, 2, 0})
+	engine.SendToBack(rects[2])
+	checkOrder("SendToBack", []int{2, 1, 0})
+	engine.BringToFront(rects[0]) // already at the front
+	checkOrder("BringToFront2", []int{2, 1, 0})
+	layer := engine.NewLayer(0, 0, 10, 10, color.RGBA{})
+	layer.BringToFront(rects[1]) // not in this layer
+	checkOrder("OtherLayer", []int{2, 1, 0})
+}
+
+// Move a rectangle and a layer around using MoveBy, and compare the result
+// against the same movements done with Move.
+func TestMoveBy(t *testing.T) {
+	screen := imagescreen.NewScreen(100, 100)
+	engine := NewEngine(screen)
+	rect := engine.NewRectangle(10, 20, 15, 10, color.RGBA{255, 0, 0, 255})
+	layer := engine.NewLayer(50, 50, 30, 20, color.RGBA{0, 0, 255, 255})
+	layer.NewCircle(15, 10, 8, color.RGBA{255, 255, 0, 255})
+	engine.Display()
+
+	reference := imagescreen.NewScreen(100, 100)
+	referenceEngine := NewEngine(reference)
+	referenceRect := referenceEngine.NewRectangle(10, 20, 15, 10, color.RGBA{255, 0, 0, 255})
+	referenceLayer := referenceEngine.NewLayer(50, 50, 30, 20, color.RGBA{0, 0, 255, 255})
+	referenceLayer.NewCircle(15, 10, 8, color.RGBA{255, 255, 0, 255})
+	referenceEngine.Display()
+
+	rectX, rectY := int16(10), int16(20)
+	layerX, layerY := int16(50), int16(50)
+	for i, delta := range [][2]int16{{3, 0}, {0, 5}, {-7, -2}, {20, 11}, {-30, -40}, {1, 1}} {
+		rect.MoveBy(delta[0], delta[1])
+		layer.MoveBy(-delta[1], delta[0])
+		engine.Display()
+
+		rectX += delta[0]
+		rectY += delta[1]
+		layerX -= delta[1]
+		layerY += delta[0]
+		referenceRect.Move(rectX, rectY, 15, 10)
+		referenceLayer.Move(layerX, layerY, 30, 20)
+		referenceEngine.Display()
+
+		if x1, y1, x2, y2 := rect.boundingBox(); x1 != rectX || y1 != rectY || x2-x1 != 15 || y2-y1 != 10 {
+			t.Errorf("step %d: unexpected rectangle bounds %d, %d, %d, %d", i, x1, y1, x2, y2)
+		}
+		if err := sameImage(screen, reference); err != nil {
+			t.Errorf("step %d: %v", i, err)
+			saveTemporaryImages(t, "MoveBy", i, screen, reference)
+		}
+	}
+}
+
+// Test resizing a rectangle and a layer, checking that only the changed edges
+// are redrawn and that the result is the same as a fresh render.
+func TestResizeObject(t *testing.T) {
+	screen := imagescreen.NewScreen(100, 100)
+	engine := NewEngine(screen)
+	rect := engine.NewRectangle(10, 20, 15, 10, color.RGBA{255, 0, 0, 255})
+	layer := engine.NewLayer(50, 50, 30, 20, color.RGBA{0, 0, 255, 255})
+	layer.NewCircle(15, 10, 8, color.RGBA{255, 255, 0, 255})
+	engine.Display()
+
+	// Grow the rectangle to the right, within the same column of tiles.
+	rect.Resize(20, 10)
+	engine.Display()
+	if stats := engine.LastStats(); stats.TilesDrawn != 2 {
+		t.Errorf("resized rectangle: expected 2 tiles to be redrawn, got %+v", stats)
+	}
+	if x1, y1, x2, y2 := rect.boundingBox(); x1 != 10 || y1 != 20 || x2 != 30 || y2 != 30 {
+		t.Errorf("resized rectangle: unexpected bounding box (%d, %d, %d, %d)", x1, y1, x2, y2)
+	}
+
+	// Shrink the layer at the bottom.
+	layer.Resize(30, 12)
+	engine.Display()
+	if stats := engine.LastStats(); stats.TilesDrawn != 8 {
+		t.Errorf("resized layer: expected 8 tiles to be redrawn, got %+v", stats)
+	}
+
+	reference := imagescreen.NewScreen(100, 100)
+	referenceEngine := NewEngine(reference)
+	referenceEngine.NewRectangle(10, 20, 20, 10, color.RGBA{255, 0, 0, 255})
+	referenceLayer := referenceEngine.NewLayer(50, 50, 30, 12, color.RGBA{0, 0, 255, 255})
+	referenceLayer.NewCircle(15, 10, 8, color.RGBA{255, 255, 0, 255})
+	referenceEngine.Display()
+	if err := sameImage(screen, reference); err != nil {
+		t.Error(err)
+	}
+}
+
+// Scale a rectangle up and down around its center like in a "pop" animation,
+// and compare each frame against a centered rectangle created from scratch.
+func TestScaleAboutCenter(t *testing.T) {
+	screen := imagescreen.NewScreen(100, 100)
+	engine := NewEngine(screen)
+	engine.SetBackgroundColor(color.RGBA{50, 50, 50, 255})
+	rect := engine.NewRectangle(45, 40, 11, 20, color.RGBA{255, 0, 0, 255})
+	engine.Display()
+
+	// The center pixel of the rectangle above.
+	const cx, cy = 50, 50
+	for i, size := range [][2]int16{{12, 20}, {13, 21}, {20, 30}, {41, 2}, {0, 0}, {1, 1}, {2, 3}, {70, 64}, {11, 20}} {
+		rect.ScaleAboutCenter(size[0], size[1])
+		engine.Display()
+
+		x, y := int16(cx)-size[0]/2, int16(cy)-size[1]/2
+		if x1, y1, x2, y2 := rect.boundingBox(); x1 != x || y1 != y || x2-x1 != size[0] || y2-y1 != size[1] {
+			t.Errorf("step %d: unexpected rectangle bounds %d, %d, %d, %d", i, x1, y1, x2, y2)
+		}
+		reference := imagescreen.NewScreen(100, 100)
+		referenceEngine := NewEngine(reference)
+		referenceEngine.SetBackgroundColor(color.RGBA{50, 50, 50, 255})
+		referenceEngine.NewRectangle(x, y, size[0], size[1], color.RGBA{255, 0, 0, 255})
+		referenceEngine.Display()
+		if err := sameImage(screen, reference); err != nil {
+			t.Errorf("step %d: scaling to %dx%d: %v", i, size[0], size[1], err)
+			saveTemporaryImages(t, "ScaleAboutCenter", i, screen, reference)
+		}
+	}
+}
+
+// Move a rectangle around near the far edges of clearly non-square screens, to
+// check that tile rows and columns aren't mixed up.
+func TestRectUpdateNonSquare(t *testing.T) {
+	for _, size := range [][2]int16{{160, 80}, {80, 160}} {
+		screenWidth, screenHeight := size[0], size[1]
+		screen := imagescreen.NewScreen(screenWidth, screenHeight)
+		engine := NewEngine(screen)
+		rect := engine.NewRectangle(0, 0, 10, 10, color.RGBA{255, 255, 0, 255})
+		engine.Display()
+
+		positions := [][2]int16{
+			{screenWidth - 12, 0},
+			{screenWidth - 5, screenHeight - 12},
+			{0, screenHeight - 5},
+			{screenWidth - 9, screenHeight - 9},
+		}
+		for i, pos := range positions {
+			rect.Move(pos[0], pos[1], 10, 10)
+			engine.Display()
+
+			reference := imagescreen.NewScreen(screenWidth, screenHeight)
+			referenceEngine := NewEngine(reference)
+			referenceEngine.NewRectangle(pos[0], pos[1], 10, 10, color.RGBA{255, 255, 0, 255})
+			referenceEngine.Display()
+			if err := sameImage(screen, reference); err != nil {
+				t.Errorf("screen %dx%d: moving rectangle to x=%d y=%d resulted in a different image: %v", screenWidth, screenHeight, pos[0], pos[1], err)
+				saveTemporaryImages(t, "RectUpdateNonSquare", i, screen, reference)
+			}
+		}
+	}
+}
+
 // Move a layer with an enclosed rectangle around in various ways, testing for
 // inconsistent update behavior.
 func TestLayerUpdate(t *testing.T) {
 	// Get a deterministic randomness source.
 	rand := rand.New(rand.NewSource(1))
 
-	// Test different display sizes/configurations.
-	const screenCycleMax = 20
-	for screenCycle := 0; screenCycle < screenCycleMax; screenCycle++ {
-		screenWidth := int16(rand.Uint32()%20 + 100)
-		screenHeight := int16(rand.Uint32()%20 + 100)
-		screen := imagescreen.NewScreen(screenWidth,
-			screenHeight)
-		engine := NewEngine(screen)
-		engine.SetBackgroundColor(color.RGBA{50, 50, 50, 255})
-		layerColor := color.RGBA{100, 0, 0, uint8(rand.Uint32()%1)*55 + 100}
-		layer := engine.NewLayer(0, 0, 10, 10, layerColor)
-		rectX := int16(rand.Int31()%200 - 50)
-		rectY := int16(rand.Int31()%200 - 50)
-		rectWidth := int16(rand.Int31() % 50)
-		rectHeight := int16(rand.Int31() % 50)
-		rectColor := color.RGBA{0, 100, 0, uint8(rand.Uint32()%1)*55 + 100}
-		layer.NewRectangle(rectX, rectY, rectWidth, rectHeight, rectColor)
+	// Test different display sizes/configurations.
+	const screenCycleMax = 20
+	for screenCycle := 0; screenCycle < screenCycleMax; screenCycle++ {
+		screenWidth := int16(rand.Uint32()%20 + 100)
+		screenHeight := int16(rand.Uint32()%20 + 100)
+		screen := imagescreen.NewScreen(screenWidth,
+			screenHeight)
+		engine := NewEngine(screen)
+		engine.SetBackgroundColor(color.RGBA{50, 50, 50, 255})
+		layerColor := color.RGBA{100, 0, 0, uint8(rand.Uint32()%1)*55 + 100}
+		layer := engine.NewLayer(0, 0, 10, 10, layerColor)
+		rectX := int16(rand.Int31()%200 - 50)
+		rectY := int16(rand.Int31()%200 - 50)
+		rectWidth := int16(rand.Int31() % 50)
+		rectHeight := int16(rand.Int31() % 50)
+		rectColor := color.RGBA{0, 100, 0, uint8(rand.Uint32()%1)*55 + 100}
+		layer.NewRectangle(rectX, rectY, rectWidth, rectHeight, rectColor)
+
+		// Move the layer around a few times, checking with a reference each
+		// time that's built from scratch.
+		const layerCycleMax = 8
+		for layerCycle := 0; layerCycle < layerCycleMax; layerCycle++ {
+			layerX := int16(rand.Int31()%200 - 50)
+			layerY := int16(rand.Int31()%200 - 50)
+			layerWidth := int16(rand.Int31() % 50)
+			layerHeight := int16(rand.Int31() % 50)
+			layer.Move(layerX, layerY, layerWidth, layerHeight)
+			engine.Display()
+
+			reference := imagescreen.NewScreen(screenWidth, screenHeight)
+			referenceEngine := NewEngine(reference)
+			referenceEngine.SetBackgroundColor(color.RGBA{50, 50, 50, 255})
+			referenceLayer := referenceEngine.NewLayer(layerX, layerY, layerWidth, layerHeight, layerColor)
+			referenceLayer.NewRectangle(rectX, rectY, rectWidth, rectHeight, rectColor)
+			referenceEngine.Display()
+
+			if err := sameImage(screen, reference); err != nil {
+				t.Errorf("moving a layer didn't invalidate the correct area")
+				saveTemporaryImages(t, "LayerUpdate", screenCycle*screenCycleMax+layerCycle, screen, reference)
+			}
+		}
+	}
+}
+
+// Test that layers don't let their objects escape outside of the layer.
+func TestLayerBounds(t *testing.T) {
+	screen := imagescreen.NewScreen(100, 100)
+	engine := NewEngine(screen)
+	engine.SetBackgroundColor(color.RGBA{50, 50, 50, 255})
+	layer := engine.NewLayer(11, 7, 71, 83, color.RGBA{255, 255, 255, 255})
+	layer.NewRectangle(-5, -5, 30, 30, color.RGBA{255, 0, 0, 255})
+	layer.NewRectangle(60, 60, 30, 30, color.RGBA{0, 255, 0, 255})
+	engine.Display()
+
+	matchImage(t, screen, "testdata/layer1.png")
+}
+
+// Draw a semi-transparent layer partially over a rectangle, and check that the
+// layer background is blended with everything below it.
+func TestLayerTransparent(t *testing.T) {
+	background := color.RGBA{0, 0, 100, 255}
+	rectColor := color.RGBA{255, 255, 0, 255}
+	layerColor := color.RGBA{100, 0, 0, 127}
+	innerColor := color.RGBA{0, 255, 0, 255}
+
+	screen := imagescreen.NewScreen(64, 64)
+	engine := NewEngine(screen)
+	engine.SetBackgroundColor(color.RGBA{0, 0, 100, 0}) // alpha is ignored
+	engine.NewRectangle(10, 10, 30, 30, rectColor)
+	layer := engine.NewLayer(25, 5, 30, 50, layerColor)
+	layer.NewRectangle(5, 20, 10, 10, innerColor)
+	engine.Display()
+
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 64; x++ {
+			expected := background
+			if x >= 10 && x < 40 && y >= 10 && y < 40 {
+				expected = rectColor
+			}
+			if x >= 25 && x < 55 && y >= 5 && y < 55 {
+				expected = Blend(expected, layerColor)
+				if x >= 30 && x < 40 && y >= 25 && y < 35 {
+					expected = innerColor
+				}
+			}
+			if c := screen.RGBAAt(x, y); c != expected {
+				t.Fatalf("pixel mismatch at X=%d Y=%d: expected %v, got %v", x, y, expected, c)
+			}
+		}
+	}
+}
+
+// Draw three nested semi-transparent layers (one of them with a layer opacity)
+// containing semi-transparent objects, and compare the result against a
+// floating point reference that composites each layer as a group.
+func TestLayerNestedTransparent(t *testing.T) {
+	SetGammaMode(GammaAccurate)
+	defer SetGammaMode(GammaFast)
+
+	background := color.RGBA{30, 30, 30, 255}
+	colorA := RGBA(255, 0, 0, 128)
+	colorB := RGBA(0, 0, 255, 100)
+	colorC := RGBA(0, 255, 0, 150)
+	rectColor := RGBA(255, 255, 0, 120)
+
+	screen := imagescreen.NewScreen(64, 64)
+	engine := NewEngine(screen)
+	engine.SetBackgroundColor(background)
+	layerA := engine.NewLayer(5, 5, 50, 50, colorA)
+	layerB := layerA.NewLayer(10, 10, 35, 35, colorB)
+	layerB.SetOpacity(200)
+	layerB.NewRectangle(0, 20, 15, 10, White)
+	layerC := layerB.NewLayer(8, 8, 20, 20, colorC)
+	layerC.NewRectangle(5, 5, 10, 10, rectColor)
+	engine.Display()
+
+	// Colors in linear color space, premultiplied.
+	type linear [4]float64
+	toLinear := func(c color.RGBA) linear {
+		return linear{decodeGammaFloat(c.R), decodeGammaFloat(c.G), decodeGammaFloat(c.B), float64(c.A) / 255}
+	}
+	over := func(bottom, top linear) linear {
+		var result linear
+		for i := range result {
+			result[i] = top[i] + bottom[i]*(1-top[3])
+		}
+		return result
+	}
+	inside := func(x, y, x1, y1, x2, y2 int) bool {
+		return x >= x1 && y >= y1 && x < x2 && y < y2
+	}
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 64; x++ {
+			pixel := toLinear(background)
+			if inside(x, y, 5, 5, 55, 55) {
+				groupA := toLinear(colorA)
+				if inside(x, y, 15, 15, 50, 50) {
+					groupB := toLinear(colorB)
+					if inside(x, y, 15, 35, 30, 45) {
+						groupB = over(groupB, toLinear(White))
+					}
+					if inside(x, y, 23, 23, 43, 43) {
+						groupC := toLinear(colorC)
+						if inside(x, y, 28, 28, 38, 38) {
+							groupC = over(groupC, toLinear(rectColor))
+						}
+						groupB = over(groupB, groupC)
+					}
+					for i := range groupB {
+						groupB[i] *= 200.0 / 255
+					}
+					groupA = over(groupA, groupB)
+				}
+				pixel = over(pixel, groupA)
+			}
+			expected := color.RGBA{encodeGammaFloat(pixel[0]), encodeGammaFloat(pixel[1]), encodeGammaFloat(pixel[2]), 255}
+			if c := screen.RGBAAt(x, y); !closeColor(c, expected, 2) {
+				t.Fatalf("pixel mismatch at X=%d Y=%d: expected %v, got %v", x, y, expected, c)
+			}
+		}
+	}
+}
+
+// Test that a child object that is bigger than its layer only invalidates the
+// tiles under the layer.
+func TestLayerInvalidateClip(t *testing.T) {
+	screen := imagescreen.NewScreen(100, 100)
+	engine := NewEngine(screen)
+	layer := engine.NewLayer(20, 20, 30, 30, color.RGBA{0, 0, 255, 255})
+	inner := layer.NewLayer(-5, 10, 10, 10, color.RGBA{0, 255, 0, 255})
+	rect := layer.NewRectangle(-10, -10, 60, 60, color.RGBA{255, 0, 0, 255})
+	innerRect := inner.NewRectangle(-20, -20, 100, 100, color.RGBA{255, 255, 0, 255})
+	engine.Display()
+
+	checkDirty := func(name string, x1, y1, x2, y2 int) {
+		for tileY, row := range engine.cleanTiles {
+			for tileX, clean := range row {
+				// The tile should be dirty when it overlaps with the given
+				// area (in pixels).
+				dirty := tileX*TileSize < x2 && (tileX+1)*TileSize > x1 && tileY*TileSize < y2 && (tileY+1)*TileSize > y1
+				if clean == dirty {
+					t.Errorf("%s: tile X=%d Y=%d: expected dirty=%v", name, tileX, tileY, dirty)
+				}
+			}
+		}
+	}
+
+	rect.SetColor(color.RGBA{255, 0, 255, 255})
+	checkDirty("rect", 20, 20, 50, 50)
+	engine.Display()
+
+	// The inner layer partially falls outside the outer layer, so the visible
+	// part of the inner layer is from (20, 30) to (25, 40).
+	innerRect.SetColor(color.RGBA{0, 255, 255, 255})
+	checkDirty("inner rect", 20, 30, 25, 40)
+	engine.Display()
+}
+
+// Test that a clip rectangle hides everything of the layer outside of it, and
+// that changes outside of the clip rectangle don't redraw anything.
+func TestLayerClipRect(t *testing.T) {
+	screen := imagescreen.NewScreen(100, 100)
+	engine := NewEngine(screen)
+	engine.SetBackgroundColor(color.RGBA{50, 50, 50, 255})
+	layer := engine.NewLayer(20, 20, 60, 60, color.RGBA{0, 0, 255, 255})
+	layer.NewRectangle(-5, 5, 30, 20, color.RGBA{255, 0, 0, 255})
+	layer.NewCircle(35, 35, 10, color.RGBA{0, 255, 0, 127})
+	layer.NewLine(0, 0, 60, 50, color.RGBA{255, 255, 255, 255})
+	outside := layer.NewRectangle(45, 2, 10, 10, color.RGBA{255, 255, 0, 255})
+	layer.SetClipRect(10, 10, 30, 30)
+	engine.Display()
+
+	// The same layer, but only the area inside the clip rectangle.
+	reference := imagescreen.NewScreen(100, 100)
+	referenceEngine := NewEngine(reference)
+	referenceEngine.SetBackgroundColor(color.RGBA{50, 50, 50, 255})
+	referenceLayer := referenceEngine.NewLayer(30, 30, 30, 30, color.RGBA{0, 0, 255, 255})
+	referenceLayer.NewRectangle(-15, -5, 30, 20, color.RGBA{255, 0, 0, 255})
+	referenceLayer.NewCircle(25, 25, 10, color.RGBA{0, 255, 0, 127})
+	referenceLayer.NewLine(-10, -10, 50, 40, color.RGBA{255, 255, 255, 255})
+	referenceEngine.Display()
+	if err := sameImage(screen, reference); err != nil {
+		t.Error("clipped:", err)
+		saveTemporaryImages(t, "LayerClipRect", 0, screen, reference)
+	}
+	if engine.ObjectAt(70, 25) != nil {
+		t.Error("clipped: found an object outside of the clip rectangle")
+	}
+
+	// The rectangle is entirely outside of the clip rectangle.
+	outside.Move(50, 0, 10, 8)
+	engine.Display()
+	if stats := engine.LastStats(); stats.TilesDrawn != 0 {
+		t.Errorf("moved hidden rectangle: expected nothing to be redrawn, got %+v", stats)
+	}
+
+	// Without clip rectangle, the whole layer is drawn again.
+	layer.ClearClipRect()
+	engine.Display()
+	if c, expected := engine.ColorAt(75, 25), (color.RGBA{255, 255, 0, 255}); c != expected || screen.RGBAAt(75, 25) != expected {
+		t.Errorf("unclipped: expected %v at the moved rectangle, got %v", expected, c)
+	}
+}
+
+// Pan the viewport over a scene that is bigger than the screen, and compare it
+// against a reference where the objects were moved in the opposite direction.
+func TestViewport(t *testing.T) {
+	type rect struct{ x, y, width, height int16 }
+	rects := []rect{{0, 0, 30, 20}, {50, 10, 40, 40}, {120, 60, 30, 50}, {-20, 90, 60, 15}, {170, 150, 20, 20}}
+	colors := []color.RGBA{{255, 0, 0, 255}, {0, 255, 0, 255}, {0, 0, 255, 127}, {255, 255, 0, 255}, {255, 255, 255, 255}}
+
+	screen := imagescreen.NewScreen(64, 48)
+	engine := NewEngine(screen)
+	engine.SetBackgroundColor(color.RGBA{50, 50, 50, 255})
+	for i, r := range rects {
+		engine.NewRectangle(r.x, r.y, r.width, r.height, colors[i])
+	}
+	layer := engine.NewLayer(100, 20, 50, 30, color.RGBA{0, 0, 100, 255})
+	layer.NewCircle(25, 15, 10, color.RGBA{255, 0, 255, 255})
+	engine.Display()
+
+	for i, viewport := range [][2]int16{{10, 0}, {40, 20}, {100, 60}, {-30, 80}, {150, 140}, {0, 0}} {
+		engine.SetViewport(viewport[0], viewport[1])
+		engine.Display()
+
+		reference := imagescreen.NewScreen(64, 48)
+		referenceEngine := NewEngine(reference)
+		referenceEngine.SetBackgroundColor(color.RGBA{50, 50, 50, 255})
+		for j, r := range rects {
+			referenceEngine.NewRectangle(r.x-viewport[0], r.y-viewport[1], r.width, r.height, colors[j])
+		}
+		referenceLayer := referenceEngine.NewLayer(100-viewport[0], 20-viewport[1], 50, 30, color.RGBA{0, 0, 100, 255})
+		referenceLayer.NewCircle(25, 15, 10, color.RGBA{255, 0, 255, 255})
+		referenceEngine.Display()
+		if err := sameImage(screen, reference); err != nil {
+			t.Errorf("viewport at %v: %v", viewport, err)
+			saveTemporaryImages(t, "Viewport", i, screen, reference)
+		}
+	}
+
+	// Hit testing uses screen coordinates.
+	engine.SetViewport(100, 20)
+	if obj := engine.ObjectAt(5, 5); obj != layer {
+		t.Errorf("expected to find the layer at the top left of the viewport, got %v", obj)
+	}
+
+	// Invalidating a region uses screen coordinates too.
+	engine.Display()
+	reference := engine.Snapshot()
+	screen.FillRectangle(0, 0, 64, 48, color.RGBA{255, 255, 255, 255})
+	engine.InvalidateRegion(0, 0, 8, 8)
+	engine.Display()
+	if stats := engine.LastStats(); stats.TilesDrawn != 1 {
+		t.Errorf("invalidated region: expected 1 tile to be drawn, got %+v", stats)
+	}
+	if c1, c2 := screen.RGBAAt(0, 0), reference.RGBAAt(0, 0); c1 != c2 {
+		t.Errorf("invalidated region: expected %v at the top left, got %v", c2, c1)
+	}
+}
+
+// Move a rectangle and a nested layer between two layers at different offsets,
+// and compare the result against a screen where they were created in the new
+// layer from the start.
+func TestLayerAdopt(t *testing.T) {
+	screen := imagescreen.NewScreen(100, 80)
+	engine := NewEngine(screen)
+	engine.SetBackgroundColor(color.RGBA{50, 50, 50, 255})
+	left := engine.NewLayer(5, 10, 40, 60, color.RGBA{0, 0, 100, 255})
+	right := engine.NewLayer(55, 20, 40, 50, color.RGBA{0, 100, 0, 255})
+	rect := left.NewRectangle(5, 5, 20, 10, color.RGBA{255, 0, 0, 255})
+	inner := left.NewLayer(10, 25, 25, 25, color.RGBA{100, 100, 0, 255})
+	inner.NewCircle(12, 12, 8, color.RGBA{255, 255, 255, 255})
+	right.NewRectangle(0, 0, 30, 30, color.RGBA{0, 0, 255, 255})
+	engine.Display()
+
+	right.Adopt(rect)
+	right.Adopt(inner)
+	left.Adopt(left)                 // can't adopt itself
+	inner.Adopt(right)               // can't adopt a parent
+	right.Adopt(engine.AddScene("")) // can't adopt a root layer
+	engine.Display()
+
+	reference := imagescreen.NewScreen(100, 80)
+	referenceEngine := NewEngine(reference)
+	referenceEngine.SetBackgroundColor(color.RGBA{50, 50, 50, 255})
+	referenceEngine.NewLayer(5, 10, 40, 60, color.RGBA{0, 0, 100, 255})
+	referenceRight := referenceEngine.NewLayer(55, 20, 40, 50, color.RGBA{0, 100, 0, 255})
+	referenceRight.NewRectangle(0, 0, 30, 30, color.RGBA{0, 0, 255, 255})
+	referenceRight.NewRectangle(5, 5, 20, 10, color.RGBA{255, 0, 0, 255})
+	referenceInner := referenceRight.NewLayer(10, 25, 25, 25, color.RGBA{100, 100, 0, 255})
+	referenceInner.NewCircle(12, 12, 8, color.RGBA{255, 255, 255, 255})
+	referenceEngine.Display()
+	if err := sameImage(screen, reference); err != nil {
+		t.Error(err)
+		saveTemporaryImages(t, "LayerAdopt", 0, screen, reference)
+	}
+
+	// The rectangle now moves within its new layer.
+	rect.Move(0, 40, 10, 10)
+	engine.Display()
+	if c, expected := screen.RGBAAt(60, 65), (color.RGBA{255, 0, 0, 255}); c != expected {
+		t.Errorf("moved adopted rectangle: expected %v, got %v", expected, c)
+	}
+	if len(left.objects) != 0 {
+		t.Errorf("expected the old layer to be empty, got %d objects", len(left.objects))
+	}
+}
+
+// Scroll a column of rectangles in a layer, and compare it against a reference
+// where every rectangle was moved individually.
+func TestLayerScroll(t *testing.T) {
+	colors := []color.RGBA{
+		{255, 0, 0, 255}, {0, 255, 0, 255}, {0, 0, 255, 255}, {255, 255, 0, 255},
+		{255, 0, 255, 255}, {0, 255, 255, 255}, {127, 127, 127, 255}, {0, 0, 0, 127},
+	}
+	screen := imagescreen.NewScreen(100, 100)
+	engine := NewEngine(screen)
+	layer := engine.NewLayer(20, 10, 50, 70, color.RGBA{255, 255, 255, 255})
+	inner := layer.NewLayer(10, 100, 30, 30, color.RGBA{0, 100, 0, 255})
+	inner.NewCircle(15, 15, 10, color.RGBA{255, 255, 255, 255})
+	for i, c := range colors {
+		layer.NewRectangle(5, int16(i)*20+3, 40, 15, c)
+	}
+	rule := layer.NewHRule(65, color.RGBA{0, 0, 0, 255})
+	engine.Display()
+
+	reference := imagescreen.NewScreen(100, 100)
+	referenceEngine := NewEngine(reference)
+	referenceLayer := referenceEngine.NewLayer(20, 10, 50, 70, color.RGBA{255, 255, 255, 255})
+	referenceInner := referenceLayer.NewLayer(10, 100, 30, 30, color.RGBA{0, 100, 0, 255})
+	referenceInner.NewCircle(15, 15, 10, color.RGBA{255, 255, 255, 255})
+	var referenceRects []*Rectangle
+	for i, c := range colors {
+		referenceRects = append(referenceRects, referenceLayer.NewRectangle(5, int16(i)*20+3, 40, 15, c))
+	}
+	referenceRule := referenceLayer.NewHRule(65, color.RGBA{0, 0, 0, 255})
+	referenceEngine.Display()
+
+	for _, offset := range [][2]int16{{0, 25}, {0, 90}, {3, 7}, {-10, -10}, {0, 0}} {
+		layer.SetScrollOffset(offset[0], offset[1])
+		rule.Move(offset[1] + 20)
+		engine.Display()
+		for i, rect := range referenceRects {
+			rect.Move(5-offset[0], int16(i)*20+3-offset[1], 40, 15)
+		}
+		referenceInner.Move(10-offset[0], 100-offset[1], 30, 30)
+		referenceRule.Move(20)
+		referenceEngine.Display()
+		if err := sameImage(screen, reference); err != nil {
+			t.Errorf("scroll offset %v: %v", offset, err)
+			saveTemporaryImages(t, "LayerScroll", int(offset[1]), screen, reference)
+		}
+	}
+
+	layer.SetScrollOffset(0, 90)
+	if obj := engine.ObjectAt(45, 41); obj != inner.objects[0] {
+		t.Errorf("scrolled: expected the circle in the inner layer at (45, 41), got %v", obj)
+	}
+}
+
+// Test that layers with an opaque background look the same in tiles without
+// objects (which are filled directly) as in tiles with objects, by comparing
+// against the same layer flattened.
+func TestLayerEmptyTiles(t *testing.T) {
+	draw := func(engine *Engine) *Layer {
+		engine.SetBackgroundColor(color.RGBA{50, 50, 50, 255})
+		layer := engine.NewLayer(13, 7, 70, 85, color.RGBA{0, 0, 200, 255})
+		layer.NewRectangle(30, 20, 10, 10, color.RGBA{255, 0, 0, 255})
+		layer.NewCircle(50, 70, 8, color.RGBA{0, 255, 0, 127})
+		inner := layer.NewLayer(5, 40, 20, 20, color.RGBA{255, 255, 0, 255})
+		inner.NewLine(0, 0, 19, 19, color.RGBA{0, 0, 0, 255})
+		engine.NewRectangle(50, 0, 40, 30, color.RGBA{255, 255, 255, 127})
+		return layer
+	}
+	screen := imagescreen.NewScreen(100, 100)
+	engine := NewEngine(screen)
+	layer := draw(engine)
+	reference := imagescreen.NewScreen(100, 100)
+	referenceEngine := NewEngine(reference)
+	referenceLayer := draw(referenceEngine)
+	referenceLayer.Flatten()
+
+	for _, offset := range [][2]int16{{0, 0}, {3, 5}, {-20, 10}} {
+		layer.MoveBy(offset[0], offset[1])
+		referenceLayer.MoveBy(offset[0], offset[1])
+		engine.Display()
+		referenceEngine.Display()
+		if err := sameImage(screen, reference); err != nil {
+			t.Errorf("offset %v: %v", offset, err)
+			saveTemporaryImages(t, "LayerEmptyTiles", int(offset[0]), screen, reference)
+		}
+	}
+}
+
+// Test that horizontal and vertical rules keep spanning their layer when it is
+// resized.
+func TestRule(t *testing.T) {
+	screen := imagescreen.NewScreen(100, 100)
+	engine := NewEngine(screen)
+	layer := engine.NewLayer(10, 10, 40, 30, color.RGBA{0, 0, 255, 255})
+	layer.NewHRule(10, color.RGBA{255, 255, 255, 255})
+	vrule := layer.NewVRule(0, color.RGBA{0, 255, 0, 255})
+	vrule.Move(20)
+	engine.NewHRule(95, color.RGBA{255, 0, 0, 255})
+	engine.Display()
+
+	for _, size := range []image.Point{{80, 70}, {25, 15}, {60, 60}} {
+		layer.Move(10, 10, int16(size.X), int16(size.Y))
+		engine.Display()
+
+		reference := imagescreen.NewScreen(100, 100)
+		referenceEngine := NewEngine(reference)
+		referenceLayer := referenceEngine.NewLayer(10, 10, int16(size.X), int16(size.Y), color.RGBA{0, 0, 255, 255})
+		referenceLayer.NewRectangle(0, 10, int16(size.X), 1, color.RGBA{255, 255, 255, 255})
+		referenceLayer.NewRectangle(20, 0, 1, int16(size.Y), color.RGBA{0, 255, 0, 255})
+		referenceEngine.NewRectangle(0, 95, 100, 1, color.RGBA{255, 0, 0, 255})
+		referenceEngine.Display()
+		if err := sameImage(screen, reference); err != nil {
+			t.Errorf("layer size %dx%d: %v", size.X, size.Y, err)
+			saveTemporaryImages(t, "Rule", size.X, screen, reference)
+		}
+	}
+}
+
+// Test that a flattened layer looks the same as a normal layer, and that
+// changing objects in the layer updates it.
+func TestLayerFlatten(t *testing.T) {
+	draw := func(engine *Engine) (*Layer, *Rectangle, *Circle) {
+		engine.SetBackgroundColor(color.RGBA{50, 50, 50, 255})
+		layer := engine.NewLayer(13, 7, 70, 60, color.RGBA{0, 0, 100, 150})
+		rect := layer.NewRectangle(-5, 10, 30, 30, color.RGBA{255, 0, 0, 255})
+		layer.NewLine(0, 0, 70, 60, color.RGBA{255, 255, 255, 255})
+		inner := layer.NewLayer(40, 30, 20, 20, color.RGBA{0, 127, 0, 255})
+		circle := inner.NewCircle(10, 10, 8, color.RGBA{127, 0, 127, 127})
+		engine.NewRectangle(60, 60, 30, 30, color.RGBA{0, 0, 127, 127})
+		return layer, rect, circle
+	}
+	screen := imagescreen.NewScreen(100, 100)
+	engine := NewEngine(screen)
+	layer, rect, circle := draw(engine)
+	layer.Flatten()
+	reference := imagescreen.NewScreen(100, 100)
+	referenceEngine := NewEngine(reference)
+	_, referenceRect, referenceCircle := draw(referenceEngine)
+
+	check := func(name string) {
+		engine.Display()
+		referenceEngine.Display()
+		if !layer.cacheValid {
+			t.Errorf("%s: cache was not updated", name)
+		}
+		if err := sameImage(screen, reference); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+	check("flattened")
+
+	rect.Move(10, 20, 20, 20)
+	referenceRect.Move(10, 20, 20, 20)
+	if layer.cacheValid {
+		t.Error("cache was not invalidated after moving a rectangle")
+	}
+	check("move rect")
+
+	circle.Move(5, 5, 4)
+	referenceCircle.Move(5, 5, 4)
+	if layer.cacheValid {
+		t.Error("cache was not invalidated after changing an object in a nested layer")
+	}
+	check("move circle")
+
+	layer.Move(20, 20, 70, 60)
+	if !layer.cacheValid {
+		t.Error("cache was invalidated after moving the layer")
+	}
+	layer.Move(13, 7, 70, 60)
+	check("move layer")
+}
+
+// Test that a flattened layer covering a whole 240x200 screen (which is more
+// than 32767 pixels) looks the same as a normal layer.
+func TestLayerFlattenLarge(t *testing.T) {
+	draw := func(engine *Engine) *Layer {
+		layer := engine.NewLayer(0, 0, 240, 200, color.RGBA{0, 0, 100, 255})
+		layer.NewRectangle(10, 150, 220, 40, color.RGBA{255, 0, 0, 255})
+		layer.NewCircle(200, 180, 30, color.RGBA{0, 127, 0, 127})
+		return layer
+	}
+	screen := imagescreen.NewScreen(240, 200)
+	engine := NewEngine(screen)
+	draw(engine).Flatten()
+	engine.Display()
+	reference := imagescreen.NewScreen(240, 200)
+	referenceEngine := NewEngine(reference)
+	draw(referenceEngine)
+	referenceEngine.Display()
+	if err := sameImage(screen, reference); err != nil {
+		t.Error(err)
+	}
+}
+
+// Test hiding and showing a layer, comparing against a reference that has never
+// drawn the layer or has drawn it from the start.
+func TestLayerVisible(t *testing.T) {
+	drawBackground := func(engine *Engine) {
+		engine.SetBackgroundColor(color.RGBA{50, 50, 50, 255})
+		engine.NewRectangle(5, 5, 40, 40, color.RGBA{255, 0, 0, 255})
+	}
+
+	screen := imagescreen.NewScreen(100, 100)
+	engine := NewEngine(screen)
+	drawBackground(engine)
+	layer := engine.NewLayer(21, 17, 61, 53, color.RGBA{255, 255, 255, 255})
+	layer.NewRectangle(-5, -5, 30, 30, color.RGBA{0, 0, 255, 255})
+	engine.Display()
+
+	background := imagescreen.NewScreen(100, 100)
+	backgroundEngine := NewEngine(background)
+	drawBackground(backgroundEngine)
+	backgroundEngine.Display()
+
+	withLayer := imagescreen.NewScreen(100, 100)
+	withLayerEngine := NewEngine(withLayer)
+	drawBackground(withLayerEngine)
+	referenceLayer := withLayerEngine.NewLayer(21, 17, 61, 53, color.RGBA{255, 255, 255, 255})
+	referenceLayer.NewRectangle(-5, -5, 30, 30, color.RGBA{0, 0, 255, 255})
+	withLayerEngine.Display()
+
+	for i := 0; i < 3; i++ {
+		layer.SetVisible(false)
+		engine.Display()
+		if err := sameImage(screen, background); err != nil {
+			t.Errorf("hiding a layer resulted in a different image: %v", err)
+			saveTemporaryImages(t, "LayerVisible", i*2, screen, background)
+		}
+
+		layer.SetVisible(true)
+		engine.Display()
+		if err := sameImage(screen, withLayer); err != nil {
+			t.Errorf("showing a layer resulted in a different image: %v", err)
+			saveTemporaryImages(t, "LayerVisible", i*2+1, screen, withLayer)
+		}
+	}
+}
+
+// Draw a number of nested rectangle outlines, including transparent ones and
+// outlines that are partially outside of the screen.
+func TestRectOutline(t *testing.T) {
+	screen := imagescreen.NewScreen(100, 100)
+	engine := NewEngine(screen)
+	engine.SetBackgroundColor(color.RGBA{50, 50, 50, 255})
+	for i := int16(0); i < 5; i++ {
+		engine.NewRectangleOutline(5+i*9, 5+i*9, 90-i*18, 90-i*18, i+1, color.RGBA{255, uint8(i * 60), 0, 255})
+	}
+	engine.NewRectangleOutline(20, 40, 60, 20, 4, color.RGBA{0, 0, 127, 127})
+	outline := engine.NewRectangleOutline(0, 0, 1, 1, 3, color.RGBA{255, 255, 255, 255})
+	outline.Move(-10, 70, 30, 40)
+	engine.Display()
+
+	matchImage(t, screen, "testdata/outline1.png")
+}
+
+// Draw a few beveled buttons, both raised and pressed, including one with a
+// transparent fill and one that is moved partially off the screen.
+func TestBeveledRect(t *testing.T) {
+	screen := imagescreen.NewScreen(100, 100)
+	engine := NewEngine(screen)
+	engine.SetBackgroundColor(color.RGBA{50, 50, 50, 255})
+	fill := color.RGBA{160, 160, 160, 255}
+	light := color.RGBA{230, 230, 230, 255}
+	dark := color.RGBA{80, 80, 80, 255}
+	engine.NewBeveledRectangle(5, 5, 40, 20, 3, fill, light, dark)
+	engine.NewBeveledRectangle(55, 5, 40, 20, 3, fill, dark, light) // pressed
+	engine.NewBeveledRectangle(10, 35, 80, 30, 6, color.RGBA{0, 0, 127, 127}, light, dark)
+	engine.NewBeveledRectangle(30, 45, 8, 6, 5, fill, light, dark) // bevel larger than the rectangle
+	button := engine.NewBeveledRectangle(0, 0, 1, 1, 2, color.RGBA{200, 0, 0, 255}, color.RGBA{255, 120, 120, 255}, color.RGBA{100, 0, 0, 255})
+	button.Move(-10, 75, 50, 18)
+	engine.Display()
+
+	matchImage(t, screen, "testdata/beveled1.png")
+}
+
+// Draw rounded rectangles with a few different radii.
+func TestRoundedRect(t *testing.T) {
+	screen := imagescreen.NewScreen(100, 100)
+	engine := NewEngine(screen)
+	engine.SetBackgroundColor(color.RGBA{255, 255, 255, 255})
+	engine.NewRoundedRectangle(5, 5, 40, 25, 3, color.RGBA{255, 0, 0, 255})
+	engine.NewRoundedRectangle(55, 5, 40, 25, 10, color.RGBA{0, 150, 0, 255})
+	engine.NewRoundedRectangle(5, 40, 40, 25, 100, color.RGBA{0, 0, 255, 255}) // clamped
+	engine.NewRoundedRectangle(30, 50, 60, 45, 15, color.RGBA{0, 0, 127, 127})
+	rect := engine.NewRoundedRectangle(0, 0, 1, 1, 6, color.RGBA{0, 0, 0, 255})
+	rect.Move(-6, 75, 30, 20)
+	engine.Display()
+
+	matchImage(t, screen, "testdata/roundedrect1.png")
+}
+
+// Test gradient rectangles in both directions, including a transparent one.
+func TestGradient(t *testing.T) {
+	screen := imagescreen.NewScreen(100, 100)
+	engine := NewEngine(screen)
+	engine.SetBackgroundColor(color.RGBA{50, 50, 50, 255})
+	engine.NewGradientRectangle(10, 10, 30, 80, color.RGBA{255, 0, 0, 255}, color.RGBA{0, 0, 255, 255}, true)
+	gradient := engine.NewGradientRectangle(0, 0, 10, 10, color.RGBA{127, 127, 0, 127}, color.RGBA{0, 127, 127, 127}, false)
+	gradient.Move(30, 35, 75, 30)
+	engine.NewGradientRectangle(30, 70, 60, 20, color.RGBA{0, 0, 0, 0}, color.RGBA{0, 255, 0, 255}, false)
+	engine.Display()
+
+	matchImage(t, screen, "testdata/gradient1.png")
+}
+
+// Test checkerboard patterns, including a transparent one. The pattern must be
+// continuous across tiles, so it is placed at an offset that isn't a multiple
+// of the tile size.
+func TestPattern(t *testing.T) {
+	screen := imagescreen.NewScreen(100, 100)
+	engine := NewEngine(screen)
+	engine.SetBackgroundColor(color.RGBA{50, 50, 50, 255})
+	engine.NewGradientRectangle(0, 0, 100, 100, color.RGBA{255, 0, 0, 255}, color.RGBA{0, 0, 255, 255}, true)
+	engine.NewPatternRectangle(5, 5, 50, 45, color.RGBA{255, 255, 255, 255}, color.RGBA{200, 200, 200, 255}, 8)
+	engine.NewPatternRectangle(35, 40, 60, 55, color.RGBA{0, 0, 0, 0}, color.RGBA{0, 100, 0, 100}, 8)
+	engine.NewPatternRectangle(10, 70, 20, 20, color.RGBA{0, 0, 0, 255}, color.RGBA{255, 255, 0, 255}, 3)
+	engine.Display()
+
+	matchImage(t, screen, "testdata/pattern1.png")
+}
+
+// Fade a rectangle, line and layer in using SetOpacity, and check that each
+// frame looks the same as when drawing it with ApplyAlpha applied to the colors.
+func TestOpacity(t *testing.T) {
+	rectColor := color.RGBA{255, 0, 0, 255}
+	lineColor := color.RGBA{0, 0, 200, 200}
+	layerColor := color.RGBA{0, 255, 0, 255}
+	screen := imagescreen.NewScreen(100, 100)
+	engine := NewEngine(screen)
+	engine.SetBackgroundColor(color.RGBA{255, 255, 255, 255})
+	rect := engine.NewRectangle(10, 10, 50, 40, rectColor)
+	line := engine.NewThickLine(5, 90, 95, 20, 3, lineColor)
+	layer := engine.NewLayer(70, 60, 20, 20, layerColor)
+	for _, opacity := range []uint8{0, 1, 64, 128, 200, 255} {
+		rect.SetOpacity(opacity)
+		line.SetOpacity(opacity)
+		layer.SetOpacity(opacity)
+		engine.Display()
+
+		// An opacity of 255 must result in the original colors.
+		applyAlpha := ApplyAlpha
+		if opacity == 255 {
+			applyAlpha = func(c color.RGBA, alpha uint8) color.RGBA { return c }
+		}
+		reference := imagescreen.NewScreen(100, 100)
+		referenceEngine := NewEngine(reference)
+		referenceEngine.SetBackgroundColor(color.RGBA{255, 255, 255, 255})
+		referenceEngine.NewRectangle(10, 10, 50, 40, applyAlpha(rectColor, opacity))
+		referenceEngine.NewThickLine(5, 90, 95, 20, 3, applyAlpha(lineColor, opacity))
+		referenceEngine.NewLayer(70, 60, 20, 20, applyAlpha(layerColor, opacity))
+		referenceEngine.Display()
+		if err := sameImage(screen, reference); err != nil {
+			t.Errorf("opacity %d: %v", opacity, err)
+			saveTemporaryImages(t, "Opacity", int(opacity), screen, reference)
+		}
+	}
+}
+
+// Check ChildCount and Children after adding and removing objects and nested
+// layers, and that changing the returned slice doesn't change the layer.
+func TestLayerChildren(t *testing.T) {
+	screen := imagescreen.NewScreen(100, 100)
+	engine := NewEngine(screen)
+	layer := engine.NewLayer(10, 10, 80, 80, color.RGBA{255, 255, 255, 255})
+	if n := layer.ChildCount(); n != 0 {
+		t.Errorf("expected an empty layer, got %d children", n)
+	}
+	rect1 := layer.NewRectangle(5, 5, 10, 10, color.RGBA{255, 0, 0, 255})
+	inner := layer.NewLayer(20, 20, 30, 30, color.RGBA{0, 0, 255, 255})
+	inner.NewRectangle(0, 0, 5, 5, color.RGBA{0, 255, 0, 255})
+	inner.NewRectangle(10, 10, 5, 5, color.RGBA{0, 255, 0, 255})
+	rect2 := layer.NewRectangle(60, 5, 10, 20, color.RGBA{255, 0, 0, 255})
+	if n := layer.ChildCount(); n != 3 {
+		t.Errorf("expected 3 children, got %d", n)
+	}
+	if n := inner.ChildCount(); n != 2 {
+		t.Errorf("expected 2 children in the nested layer, got %d", n)
+	}
+	if n := engine.root.ChildCount(); n != 1 {
+		t.Errorf("expected 1 child in the root layer, got %d", n)
+	}
+
+	children := layer.Children()
+	if len(children) != 3 || children[0] != rect1 || children[1] != inner || children[2] != rect2 {
+		t.Errorf("unexpected children: %v", children)
+	}
+	if x1, y1, x2, y2 := BoundingBox(children[2]); x1 != 60 || y1 != 5 || x2 != 70 || y2 != 25 {
+		t.Errorf("unexpected bounding box of the second rectangle: %d, %d, %d, %d", x1, y1, x2, y2)
+	}
+	if x1, y1, x2, y2 := BoundingBox(children[1]); x1 != 20 || y1 != 20 || x2 != 50 || y2 != 50 {
+		t.Errorf("unexpected bounding box of the nested layer: %d, %d, %d, %d", x1, y1, x2, y2)
+	}
+	children[0] = nil
+	if layer.Children()[0] != rect1 {
+		t.Error("changing the returned slice changed the layer")
+	}
+
+	layer.Remove(rect1)
+	layer.Remove(inner)
+	if n := layer.ChildCount(); n != 1 {
+		t.Errorf("expected 1 child after removing 2, got %d", n)
+	}
+	if children := layer.Children(); len(children) != 1 || children[0] != rect2 {
+		t.Errorf("unexpected children after removing: %v", children)
+	}
+}
+
+// Test hit testing with overlapping objects and (transparent) layers.
+func TestObjectAt(t *testing.T) {
+	screen := imagescreen.NewScreen(100, 100)
+	engine := NewEngine(screen)
+	rect := engine.NewRectangle(10, 10, 40, 40, color.RGBA{255, 0, 0, 255})
+	circle := engine.NewCircle(50, 50, 10, color.RGBA{0, 255, 0, 255})
+	line := engine.NewLine(0, 99, 99, 0, color.RGBA{255, 255, 255, 255})
+	layer := engine.NewLayer(60, 60, 30, 30, color.RGBA{0, 0, 255, 255})
+	layerRect := layer.NewRectangle(5, 5, 10, 10, color.RGBA{255, 255, 0, 255})
+	transparent := engine.NewLayer(0, 60, 40, 40, color.RGBA{})
+	transparentRect := transparent.NewRectangle(20, 20, 10, 10, color.RGBA{255, 0, 255, 255})
+	nested := layer.NewLayer(20, 20, 10, 10, color.RGBA{})
+	nestedCircle := nested.NewCircle(5, 5, 2, color.RGBA{0, 255, 255, 255})
+
+	for _, tc := range []struct {
+		x, y     int16
+		expected Element
+	}{
+		{5, 5, nil},
+		{15, 15, rect},
+		{45, 45, circle},          // circle is on top of the rectangle
+		{42, 42, rect},            // inside the circle bounding box, but not in the circle
+		{30, 69, line},            // on the line
+		{30, 72, nil},             // near the line, but not on it
+		{10, 70, nil},             // transparent layer background
+		{25, 85, transparentRect}, // in a transparent layer
+		{61, 61, layer},           // layer background
+		{70, 70, layerRect},       // rectangle inside a layer
+		{85, 85, nestedCircle},    // nested layers
+		{89, 81, layer},           // transparent nested layer
+		{95, 95, nil},             // outside every object
+	} {
+		if obj := engine.ObjectAt(tc.x, tc.y); obj != tc.expected {
+			t.Errorf("ObjectAt(%d, %d): expected %T %p, got %T %p", tc.x, tc.y, tc.expected, tc.expected, obj, obj)
+		}
+	}
+
+	// Screen coordinates are converted to layer coordinates.
+	if obj := layer.ObjectAt(70, 70); obj != layerRect {
+		t.Errorf("layer.ObjectAt(70, 70): expected layerRect, got %T %p", obj, obj)
+	}
+	if obj := nested.ObjectAt(85, 85); obj != nestedCircle {
+		t.Errorf("nested.ObjectAt(85, 85): expected nestedCircle, got %T %p", obj, obj)
+	}
+
+	// Hidden layers can't be hit.
+	layer.SetVisible(false)
+	if obj := engine.ObjectAt(70, 70); obj != nil {
+		t.Errorf("ObjectAt(70, 70) in hidden layer: expected nil, got %T %p", obj, obj)
+	}
+}
+
+// Test drawing a few transparent rectangles partially over each other, and
+// check whether the image output matches the expected output.
+func TestRectTransparent(t *testing.T) {
+	screen := imagescreen.NewScreen(100, 100)
+	engine := NewEngine(screen)
+	engine.NewRectangle(10, 10, 50, 50, color.RGBA{127, 0, 0, 127})
+	engine.NewRectangle(25, 25, 50, 50, color.RGBA{0, 127, 0, 127})
+	engine.NewRectangle(40, 40, 50, 50, color.RGBA{0, 0, 127, 127})
+	engine.Display()
+
+	matchImage(t, screen, "testdata/rect2.png")
+}
+
+// Test basic line rendering in all directions.
+func TestLine1(t *testing.T) {
+	screen := imagescreen.NewScreen(100, 100)
+	engine := NewEngine(screen)
+	engine.SetBackgroundColor(color.RGBA{255, 255, 255, 255})
+	engine.NewRectangle(8, 8, 85, 85, color.RGBA{0, 0, 0, 255})
+	for x := int16(10); x <= 90; x += 10 {
+		engine.NewLine(x, 10, 50, 50, color.RGBA{255, 255, 255, 255})
+		engine.NewLine(x, 90, 50, 50, color.RGBA{255, 255, 255, 255})
+	}
+	for y := int16(20); y <= 80; y += 10 {
+		engine.NewLine(10, y, 50, 50, color.RGBA{255, 255, 255, 255})
+		engine.NewLine(90, y, 50, 50, color.RGBA{255, 255, 255, 255})
+	}
+	engine.Display()
+
+	matchImage(t, screen, "testdata/line1.png")
+}
+
+// Test thick line rendering in all directions, with a few different widths.
+func TestLineWidth(t *testing.T) {
+	for _, width := range []int16{1, 3, 5} {
+		screen := imagescreen.NewScreen(100, 100)
+		engine := NewEngine(screen)
+		engine.SetBackgroundColor(color.RGBA{255, 255, 255, 255})
+		engine.NewRectangle(8, 8, 85, 85, color.RGBA{0, 0, 0, 255})
+		for x := int16(10); x <= 90; x += 20 {
+			engine.NewThickLine(x, 10, 50, 50, width, color.RGBA{255, 255, 255, 255})
+			engine.NewThickLine(x, 90, 50, 50, width, color.RGBA{255, 255, 255, 255})
+		}
+		for y := int16(30); y <= 70; y += 20 {
+			engine.NewThickLine(10, y, 50, 50, width, color.RGBA{255, 255, 255, 255})
+			engine.NewThickLine(90, y, 50, 50, width, color.RGBA{255, 255, 255, 255})
+		}
+		engine.NewThickLine(-10, 95, 110, 80, width, color.RGBA{200, 0, 0, 200})
+		engine.Display()
+
+		matchImage(t, screen, fmt.Sprintf("testdata/linewidth%d.png", width))
+	}
+}
+
+// Test thick lines with butt caps next to the same lines with round caps,
+// including a short line and a single point. Two lines meet at an angle, to
+// check that round caps close the gap at the joint.
+func TestLineCap(t *testing.T) {
+	screen := imagescreen.NewScreen(100, 100)
+	engine := NewEngine(screen)
+	engine.SetBackgroundColor(color.RGBA{255, 255, 255, 255})
+	for i, style := range []LineCap{CapButt, CapRound} {
+		offset := int16(i) * 50
+		lines := []*Line{
+			engine.NewThickLine(offset+8, 8, offset+40, 40, 9, color.RGBA{0, 0, 0, 255}),
+			engine.NewThickLine(offset+10, 60, offset+25, 85, 6, color.RGBA{0, 0, 200, 255}),
+			engine.NewThickLine(offset+25, 85, offset+40, 60, 6, color.RGBA{0, 0, 200, 255}),
+			engine.NewThickLine(offset+10, 48, offset+14, 47, 5, color.RGBA{200, 0, 0, 127}),
+			engine.NewThickLine(offset+35, 50, offset+35, 50, 7, color.RGBA{0, 127, 0, 255}),
+		}
+		for _, line := range lines {
+			line.SetCap(style)
+		}
+	}
+	engine.Display()
+
+	matchImage(t, screen, "testdata/linecap1.png")
+}
+
+// Test dashed lines. The dash pattern must not depend on the order in which
+// tiles are painted, so also check that the tile size doesn't matter.
+func TestLineDashed(t *testing.T) {
+	draw := func(engine *Engine) {
+		engine.SetBackgroundColor(color.RGBA{255, 255, 255, 255})
+		engine.NewDashedLine(5, 5, 95, 60, color.RGBA{0, 0, 0, 255}, 6, 3)
+		engine.NewDashedLine(95, 10, 20, 95, color.RGBA{0, 0, 200, 255}, 2, 2)
+		engine.NewDashedLine(5, 70, 95, 70, color.RGBA{0, 127, 0, 127}, 8, 4)
+		engine.NewDashedLine(10, 20, 10, 95, color.RGBA{200, 0, 0, 255}, 1, 1)
+		line := engine.NewThickLine(30, 90, 90, 80, 3, color.RGBA{100, 0, 100, 255})
+		line.SetDashPattern([]int16{10, 4, 2})
+		engine.Display()
+	}
+	screen := imagescreen.NewScreen(100, 100)
+	draw(NewEngine(screen))
+	matchImage(t, screen, "testdata/dashed1.png")
+
+	for _, tileSize := range []int16{5, 16} {
+		other := imagescreen.NewScreen(100, 100)
+		draw(NewEngineWithTileSize(other, tileSize))
+		if err := sameImage(other, screen); err != nil {
+			t.Errorf("tile size %d resulted in a different dash pattern: %v", tileSize, err)
+			saveTemporaryImages(t, "LineDashed", int(tileSize), other, screen)
+		}
+	}
+}
+
+// Test lines with sub-pixel end points, at x=10, x=10.5 and x=11. The lines at
+// whole pixels must look the same as a regular Line.
+func TestLineF(t *testing.T) {
+	screen := imagescreen.NewScreen(100, 100)
+	engine := NewEngine(screen)
+	engine.SetBackgroundColor(color.RGBA{255, 255, 255, 255})
+	for i, x := range []float32{10, 10.5, 11} {
+		y := float32(i) * 30
+		engine.NewLineF(x, y+5, x+70, y+20, color.RGBA{0, 0, 0, 255})
+		engine.NewLineF(x+75, y+2, x+80, y+28, color.RGBA{200, 0, 0, 255})
+		engine.NewLineF(x, y+25, x+60, y+25, color.RGBA{0, 0, 127, 127})
+	}
+	line := engine.NewLineF(0, 0, 1, 1, color.RGBA{0, 127, 0, 255})
+	line.Move(95.25, 95.75, 5.5, 92.25)
+	engine.Display()
+	matchImage(t, screen, "testdata/linef1.png")
+
+	// Compare with regular lines.
+	for _, tileSize := range []int16{8, 5} {
+		lines := imagescreen.NewScreen(100, 100)
+		engine := NewEngineWithTileSize(lines, tileSize)
+		engine.SetBackgroundColor(color.RGBA{255, 255, 255, 255})
+		engine.NewLineF(10, 5, 80, 20, color.RGBA{0, 0, 0, 255})
+		engine.NewLineF(85, 2, 90, 28, color.RGBA{200, 0, 0, 255})
+		engine.NewLineF(90, 40, 20, 60, color.RGBA{0, 0, 127, 127})
+		engine.Display()
+		reference := imagescreen.NewScreen(100, 100)
+		referenceEngine := NewEngine(reference)
+		referenceEngine.SetBackgroundColor(color.RGBA{255, 255, 255, 255})
+		referenceEngine.NewLine(10, 5, 80, 20, color.RGBA{0, 0, 0, 255})
+		referenceEngine.NewLine(85, 2, 90, 28, color.RGBA{200, 0, 0, 255})
+		referenceEngine.NewLine(90, 40, 20, 60, color.RGBA{0, 0, 127, 127})
+		referenceEngine.Display()
+		if err := sameImage(lines, reference); err != nil {
+			t.Errorf("tile size %d: line at whole pixels doesn't match Line: %v", tileSize, err)
+			saveTemporaryImages(t, "LineF", int(tileSize), lines, reference)
+		}
+	}
+}
+
+// Test lines without anti-aliasing next to the same lines with anti-aliasing.
+func TestLineAliased(t *testing.T) {
+	screen := imagescreen.NewScreen(100, 100)
+	engine := NewEngine(screen)
+	engine.SetBackgroundColor(color.RGBA{255, 255, 255, 255})
+	for i, antialiased := range []bool{true, false} {
+		offset := int16(i) * 50
+		lines := []*Line{
+			engine.NewLine(offset+5, 5, offset+45, 20, color.RGBA{0, 0, 0, 255}),
+			engine.NewLine(offset+5, 40, offset+20, 10, color.RGBA{200, 0, 0, 255}),
+			engine.NewLine(offset+45, 30, offset+5, 45, color.RGBA{0, 0, 127, 127}),
+			engine.NewThickLine(offset+5, 55, offset+45, 75, 4, color.RGBA{0, 127, 0, 255}),
+			engine.NewThickLine(offset+10, 95, offset+40, 80, 3, color.RGBA{127, 0, 127, 127}),
+		}
+		for _, line := range lines {
+			line.SetAntialiased(antialiased)
+		}
+	}
+	engine.Display()
+
+	matchImage(t, screen, "testdata/aliased1.png")
+}
+
+// Benchmark painting lines with and without anti-aliasing.
+func BenchmarkLine(b *testing.B) {
+	for _, antialiased := range []bool{true, false} {
+		name := "antialiased"
+		if !antialiased {
+			name = "aliased"
+		}
+		b.Run(name, func(b *testing.B) {
+			screen := imagescreen.NewScreen(100, 100)
+			engine := NewEngine(screen)
+			engine.SetBackgroundColor(color.RGBA{0, 0, 0, 255})
+			line := engine.NewLine(0, 0, 99, 60, color.RGBA{255, 255, 255, 255})
+			line.SetAntialiased(antialiased)
+			engine.Display()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				line.Move(0, int16(i%40), 99, 99-int16(i%40))
+				engine.Display()
+			}
+		})
+	}
+}
+
+// Test random lines in all directions, with colors and transparency.
+func TestLineBlend(t *testing.T) {
+	// Get a deterministic randomness source.
+	rand := rand.New(rand.NewSource(1))
+
+	// Create a screen to draw on.
+	const screenWidth = 100
+	const screenHeight = 100
+	screen := imagescreen.NewScreen(screenWidth, screenHeight)
+	engine := NewEngine(screen)
+
+	// Draw some colors in the background.
+	engine.NewRectangle(0, 0, screenWidth/2, screenHeight/2, color.RGBA{255, 0, 0, 255})
+	engine.NewRectangle(screenWidth/2, 0, screenWidth, screenHeight/2, color.RGBA{0, 255, 0, 255})
+	engine.NewRectangle(0, screenHeight/2, screenWidth/2, screenHeight, color.RGBA{0, 0, 255, 255})
+	engine.NewRectangle(screenWidth/2, screenHeight/2, screenWidth, screenHeight, color.RGBA{255, 255, 0, 255})
+
+	// Draw a lot of random lines.
+	for i := 0; i < 100; i++ {
+		x1 := int16(rand.Uint32()%(screenWidth+50) - 25)
+		y1 := int16(rand.Uint32()%(screenHeight+50) - 25)
+		x2 := int16(rand.Uint32()%(screenWidth+50) - 25)
+		y2 := int16(rand.Uint32()%(screenHeight+50) - 25)
+		lineColor := color.RGBA{uint8(rand.Uint32()), uint8(rand.Uint32()), uint8(rand.Uint32()), 255}
+		if rand.Uint32()%2 == 0 {
+			lineColor = ApplyAlpha(lineColor, uint8(rand.Uint32()))
+		}
+		engine.NewLine(x1, y1, x2, y2, lineColor)
+	}
+	engine.Display()
+
+	matchImage(t, screen, "testdata/line2.png")
+}
+
+// Test invalidation logic of a line. When a line is created, it should
+// invalidate at least all the tiles it touches, so that the next call to
+// Display() will correctly re-paint those tiles.
+func TestLineInvalidate(t *testing.T) {
+	// Get a deterministic randomness source.
+	rand := rand.New(rand.NewSource(1))
+
+	// Test different display sizes/configurations.
+	for i := 0; i < 100; i++ {
+		screenWidth := int16(rand.Uint32()%20 + 100)
+		screenHeight := int16(rand.Uint32()%20 + 100)
+		screen := imagescreen.NewScreen(screenWidth,
+			screenHeight)
+		engine := NewEngine(screen)
+
+		// Draw some colors in the background.
+		engine.NewRectangle(0, 0, screenWidth/2, screenHeight/2, color.RGBA{255, 0, 0, 255})
+		engine.NewRectangle(screenWidth/2, 0, screenWidth, screenHeight/2, color.RGBA{0, 255, 0, 255})
+		engine.NewRectangle(0, screenHeight/2, screenWidth/2, screenHeight, color.RGBA{0, 0, 255, 255})
+		engine.NewRectangle(screenWidth/2, screenHeight/2, screenWidth, screenHeight, color.RGBA{255, 255, 0, 255})
+		engine.Display()
+
+		// Draw a line with a random color.
+		x1 := int16(rand.Int31())%(screenWidth+50) - 25
+		y1 := int16(rand.Int31())%(screenHeight+50) - 25
+		x2 := int16(rand.Int31())%(screenWidth+50) - 25
+		y2 := int16(rand.Int31())%(screenHeight+50) - 25
+		lineColor := color.RGBA{uint8(rand.Uint32()), uint8(rand.Uint32()), uint8(rand.Uint32()), 255}
+		if rand.Uint32()%2 == 0 {
+			lineColor = ApplyAlpha(lineColor, uint8(rand.Uint32()))
+		}
+		engine.NewLine(x1, y1, x2, y2, lineColor)
+		engine.Display()
+
+		// Draw the background with the line at once.
+		reference := imagescreen.NewScreen(screenWidth, screenHeight)
+		referenceEngine := NewEngine(reference)
+		referenceEngine.NewRectangle(0, 0, screenWidth/2, screenHeight/2, color.RGBA{255, 0, 0, 255})
+		referenceEngine.NewRectangle(screenWidth/2, 0, screenWidth, screenHeight/2, color.RGBA{0, 255, 0, 255})
+		referenceEngine.NewRectangle(0, screenHeight/2, screenWidth/2, screenHeight, color.RGBA{0, 0, 255, 255})
+		referenceEngine.NewRectangle(screenWidth/2, screenHeight/2, screenWidth, screenHeight, color.RGBA{255, 255, 0, 255})
+		referenceEngine.NewLine(x1, y1, x2, y2, lineColor)
+		referenceEngine.Display()
+
+		if err := sameImage(screen, reference); err != nil {
+			t.Errorf("adding a line didn't invalidate the correct area")
+			saveTemporaryImages(t, "LineInvalidate", i, screen, reference)
+		}
+	}
+}
+
+// Check that a diagonal line only invalidates the tiles around the line instead
+// of its whole bounding box, and that moving thin and thick lines in an offset
+// layer still redraws all the tiles they cover.
+func TestLineInvalidateTiles(t *testing.T) {
+	screen := imagescreen.NewScreen(160, 160)
+	engine := NewEngine(screen)
+	engine.Display()
+	engine.NewLine(0, 0, 159, 159, color.RGBA{255, 255, 255, 255})
+	engine.Display()
+	boundingBoxTiles := (160 / TileSize) * (160 / TileSize)
+	if drawn := engine.LastStats().TilesDrawn; drawn > boundingBoxTiles/4 {
+		t.Errorf("diagonal line: expected at most %d tiles to be redrawn, got %d", boundingBoxTiles/4, drawn)
+	}
+
+	// Move some lines in a layer that is not aligned to the tile grid.
+	rand := rand.New(rand.NewSource(1))
+	engine.Clear()
+	layer := engine.NewLayer(5, 3, 150, 150, color.RGBA{50, 50, 50, 255})
+	layer.SetScrollOffset(-2, 7)
+	thin := layer.NewLine(0, 0, 0, 0, color.RGBA{255, 255, 255, 255})
+	thick := layer.NewThickLine(0, 0, 0, 0, 5, color.RGBA{255, 0, 0, 200})
+	for i := 0; i < 50; i++ {
+		var coordinates [8]int16
+		for j := range coordinates {
+			coordinates[j] = int16(rand.Uint32()%200) - 25
+		}
+		thin.Move(coordinates[0], coordinates[1], coordinates[2], coordinates[3])
+		thick.Move(coordinates[4], coordinates[5], coordinates[6], coordinates[7])
+		engine.Display()
+
+		reference := imagescreen.NewScreen(160, 160)
+		referenceEngine := NewEngine(reference)
+		referenceLayer := referenceEngine.NewLayer(5, 3, 150, 150, color.RGBA{50, 50, 50, 255})
+		referenceLayer.SetScrollOffset(-2, 7)
+		referenceLayer.NewLine(coordinates[0], coordinates[1], coordinates[2], coordinates[3], color.RGBA{255, 255, 255, 255})
+		referenceLayer.NewThickLine(coordinates[4], coordinates[5], coordinates[6], coordinates[7], 5, color.RGBA{255, 0, 0, 200})
+		referenceEngine.Display()
+		if err := sameImage(screen, reference); err != nil {
+			t.Errorf("moving lines to %v resulted in a different image from creating them from scratch: %v", coordinates, err)
+			saveTemporaryImages(t, "LineInvalidateTiles", i, screen, reference)
+		}
+	}
+}
+
+// Check the length and angle of horizontal, vertical and diagonal lines, in both
+// directions and after moving them.
+func TestLineLengthAngle(t *testing.T) {
+	engine := NewEngine(imagescreen.NewScreen(100, 100))
+	line := engine.NewLine(0, 0, 1, 1, color.RGBA{255, 255, 255, 255})
+	for _, tc := range []struct {
+		x1, y1, x2, y2 int16
+		length, angle  float64
+	}{
+		{10, 20, 50, 20, 40, 0},
+		{50, 20, 10, 20, 40, math.Pi},
+		{30, 10, 30, 70, 60, math.Pi / 2},
+		{30, 70, 30, 10, 60, -math.Pi / 2},
+		{10, 10, 40, 40, 30 * math.Sqrt2, math.Pi / 4},
+		{40, 40, 10, 10, 30 * math.Sqrt2, -3 * math.Pi / 4},
+		{10, 90, 90, 10, 80 * math.Sqrt2, -math.Pi / 4},
+		{20, 20, 20, 20, 0, 0},
+	} {
+		line.Move(tc.x1, tc.y1, tc.x2, tc.y2)
+		if length := line.Length(); math.Abs(float64(length)-tc.length) > 1e-4 {
+			t.Errorf("line from (%d, %d) to (%d, %d): expected length %f, got %f", tc.x1, tc.y1, tc.x2, tc.y2, tc.length, length)
+		}
+		if angle := line.Angle(); math.Abs(float64(angle)-tc.angle) > 1e-6 {
+			t.Errorf("line from (%d, %d) to (%d, %d): expected angle %f, got %f", tc.x1, tc.y1, tc.x2, tc.y2, tc.angle, angle)
+		}
+	}
+
+	// The direction of a new line is kept, like with Move.
+	if angle := engine.NewLine(50, 50, 20, 50, color.RGBA{255, 255, 255, 255}).Angle(); math.Abs(float64(angle)-math.Pi) > 1e-6 {
+		t.Errorf("new line pointing left: expected angle %f, got %f", math.Pi, angle)
+	}
+}
+
+// Sweep a clock hand through a full circle, and compare each frame against a
+// line with the same end points created from scratch.
+func TestRadialLine(t *testing.T) {
+	screen := imagescreen.NewScreen(64, 64)
+	engine := NewEngine(screen)
+	hand := engine.NewRadialLine(32, 32, 25, -math.Pi/2, color.RGBA{255, 255, 255, 255})
+	hand.SetWidth(3)
+	engine.Display()
+
+	for i := 0; i <= 60; i++ {
+		angle := float32(i) * 2 * math.Pi / 60
+		hand.SetAngle(angle)
+		engine.Display()
+		if hand.Angle() != angle {
+			t.Errorf("step %d: expected angle %f, got %f", i, angle, hand.Angle())
+		}
+
+		x := 32 + int16(math.Round(25*math.Cos(float64(angle))))
+		y := 32 + int16(math.Round(25*math.Sin(float64(angle))))
+		reference := imagescreen.NewScreen(64, 64)
+		referenceEngine := NewEngine(reference)
+		referenceEngine.NewThickLine(32, 32, x, y, 3, color.RGBA{255, 255, 255, 255})
+		referenceEngine.Display()
+		if err := sameImage(screen, reference); err != nil {
+			t.Errorf("step %d: hand at angle %f differs from a line from (32, 32) to (%d, %d): %v", i, angle, x, y, err)
+			saveTemporaryImages(t, "RadialLine", i, screen, reference)
+		}
+	}
+}
+
+// Test a hexagon and a pentagon (with the corners in different orders), and
+// changing the corners afterwards.
+func TestPolygon(t *testing.T) {
+	screen := imagescreen.NewScreen(100, 100)
+	engine := NewEngine(screen)
+	engine.SetBackgroundColor(color.RGBA{50, 50, 50, 255})
+	engine.NewPolygon([]image.Point{{30, 5}, {52, 17}, {52, 42}, {30, 55}, {8, 42}, {8, 17}}, color.RGBA{255, 200, 0, 255})
+	pentagon := engine.NewPolygon(nil, color.RGBA{0, 0, 255, 255})
+	pentagon.SetPoints([]image.Point{{70, 40}, {51, 54}, {58, 77}, {82, 77}, {89, 54}})
+	pentagon.SetColor(color.RGBA{0, 0, 127, 127})
+	engine.NewPolygon([]image.Point{{10, 70}, {40, 75}, {25, 95}}, color.RGBA{0, 255, 0, 255})
+	engine.Display()
+
+	matchImage(t, screen, "testdata/polygon1.png")
+}
+
+// Draw a zig-zag trace, like a chart, with a polyline.
+func TestPolyline(t *testing.T) {
+	screen := imagescreen.NewScreen(100, 100)
+	engine := NewEngine(screen)
+	engine.SetBackgroundColor(color.RGBA{255, 255, 255, 255})
+	var points []image.Point
+	for i := 0; i <= 10; i++ {
+		y := 20
+		if i%2 == 1 {
+			y = 40 + i*4
+		}
+		points = append(points, image.Point{5 + i*9, y})
+	}
+	engine.NewPolyline(points, color.RGBA{0, 0, 255, 255})
+	line := engine.NewPolyline(nil, color.RGBA{200, 0, 0, 255})
+	line.SetPoints([]image.Point{{5, 95}, {30, 70}, {30, 90}, {60, 90}, {95, 60}})
+	engine.Display()
+
+	matchImage(t, screen, "testdata/polyline1.png")
+}
+
+// Draw an S-curve made of two quadratic Bézier curves, and a flatter curve with
+// points that are only set after creating it.
+func TestQuadBezier(t *testing.T) {
+	screen := imagescreen.NewScreen(100, 100)
+	engine := NewEngine(screen)
+	engine.SetBackgroundColor(color.RGBA{255, 255, 255, 255})
+	engine.NewQuadBezier(5, 40, 25, 0, 50, 40, color.RGBA{0, 0, 255, 255})
+	engine.NewQuadBezier(50, 40, 75, 80, 95, 40, color.RGBA{0, 0, 255, 255})
+	curve := engine.NewQuadBezier(0, 0, 0, 0, 0, 0, color.RGBA{200, 0, 0, 255})
+	curve.SetPoints(5, 95, 60, 90, 95, 55)
+	engine.Display()
+
+	matchImage(t, screen, "testdata/quadbezier1.png")
+}
+
+// Plot a handful of points, some added after the first frame, and compare
+// against 1x1 rectangles.
+func TestPoints(t *testing.T) {
+	points := []image.Point{{1, 1}, {10, 20}, {50, 50}, {51, 50}, {98, 3}, {0, 99}}
+	extra := []image.Point{{70, 80}, {10, 21}, {33, 66}}
+	display := imagescreen.NewScreen(100, 100)
+	screen := recordscreen.NewScreen(display)
+	engine := NewEngine(screen)
+	engine.SetBackgroundColor(color.RGBA{255, 255, 255, 255})
+	set := engine.NewPoints(append([]image.Point(nil), points...), color.RGBA{0, 0, 255, 255})
+	engine.Display()
+	screen.Reset()
+	for _, point := range extra {
+		set.AddPoint(int16(point.X), int16(point.Y))
+	}
+	engine.Display()
+	if len(screen.Calls) > len(extra) {
+		t.Errorf("expected at most %d tiles to be redrawn, got %d", len(extra), len(screen.Calls))
+	}
+
+	reference := imagescreen.NewScreen(100, 100)
+	referenceEngine := NewEngine(reference)
+	referenceEngine.SetBackgroundColor(color.RGBA{255, 255, 255, 255})
+	for _, point := range append(points, extra...) {
+		referenceEngine.NewRectangle(int16(point.X), int16(point.Y), 1, 1, color.RGBA{0, 0, 255, 255})
+	}
+	referenceEngine.Display()
+	if err := sameImage(display, reference); err != nil {
+		t.Error(err)
+	}
+}
+
+// Place pixels on both sides of tile boundaries (including a transparent one
+// and one outside the screen) and move one of them, and compare the result to
+// the same pixels drawn as 1x1 rectangles. Moving a pixel must only redraw the
+// tiles under the old and the new position.
+func TestPixel(t *testing.T) {
+	positions := []image.Point{{0, 0}, {7, 7}, {8, 7}, {7, 8}, {8, 8}, {15, 0}, {16, 31}, {99, 99}, {-1, 5}}
+	display := imagescreen.NewScreen(100, 100)
+	screen := recordscreen.NewScreen(display)
+	engine := NewEngine(screen)
+	engine.SetBackgroundColor(color.RGBA{255, 255, 255, 255})
+	for _, pos := range positions {
+		engine.NewPixel(int16(pos.X), int16(pos.Y), color.RGBA{0, 0, 255, 255})
+	}
+	engine.NewPixel(40, 40, color.RGBA{127, 0, 0, 127})
+	pixel := engine.NewPixel(50, 50, color.RGBA{0, 0, 0, 255})
+	engine.Display()
+	screen.Reset()
+	pixel.Move(63, 64)
+	engine.Display()
+	if len(screen.Calls) != 2 {
+		t.Errorf("expected 2 tiles to be redrawn, got %d", len(screen.Calls))
+	}
+
+	reference := imagescreen.NewScreen(100, 100)
+	referenceEngine := NewEngine(reference)
+	referenceEngine.SetBackgroundColor(color.RGBA{255, 255, 255, 255})
+	for _, pos := range positions {
+		referenceEngine.NewRectangle(int16(pos.X), int16(pos.Y), 1, 1, color.RGBA{0, 0, 255, 255})
+	}
+	referenceEngine.NewRectangle(40, 40, 1, 1, color.RGBA{127, 0, 0, 127})
+	referenceEngine.NewRectangle(63, 64, 1, 1, color.RGBA{0, 0, 0, 255})
+	referenceEngine.Display()
+	if err := sameImage(display, reference); err != nil {
+		t.Error(err)
+	}
+}
+
+// Draw a few circles, some of them transparent or partially outside the screen,
+// and check whether the anti-aliased edges look as expected.
+func TestCircleBasic(t *testing.T) {
+	screen := imagescreen.NewScreen(100, 100)
+	engine := NewEngine(screen)
+	engine.NewCircle(30, 30, 20, color.RGBA{255, 255, 0, 255})
+	engine.NewCircle(50, 50, 25, color.RGBA{0, 0, 127, 127})
+	engine.NewCircle(95, 10, 15, color.RGBA{0, 150, 0, 255})
+	engine.NewCircle(70, 80, 3, color.RGBA{255, 0, 0, 255})
+	engine.Display()
+
+	matchImage(t, screen, "testdata/circle1.png")
+}
+
+// Draw a few rings of different thicknesses, some of them transparent or
+// partially outside the screen, and check that a ring that is as thick as its
+// radius is the same as a filled circle.
+func TestCircleOutline(t *testing.T) {
+	screen := imagescreen.NewScreen(100, 100)
+	engine := NewEngine(screen)
+	engine.SetBackgroundColor(color.RGBA{50, 50, 50, 255})
+	engine.NewCircleOutline(30, 30, 20, 3, color.RGBA{255, 255, 0, 255})
+	engine.NewCircleOutline(50, 50, 25, 10, color.RGBA{0, 0, 127, 127})
+	engine.NewCircleOutline(95, 10, 15, 1, color.RGBA{255, 255, 255, 255})
+	spinner := engine.NewCircleOutline(0, 0, 5, 2, color.RGBA{255, 0, 0, 255})
+	spinner.Move(75, 80, 12)
+	engine.Display()
+	matchImage(t, screen, "testdata/circleoutline1.png")
+
+	filled := imagescreen.NewScreen(40, 40)
+	filledEngine := NewEngine(filled)
+	filledEngine.NewCircleOutline(20, 20, 15, 15, color.RGBA{0, 200, 0, 255})
+	filledEngine.NewCircleOutline(10, 30, 6, 20, color.RGBA{200, 0, 0, 127})
+	filledEngine.Display()
+	reference := imagescreen.NewScreen(40, 40)
+	referenceEngine := NewEngine(reference)
+	referenceEngine.NewCircle(20, 20, 15, color.RGBA{0, 200, 0, 255})
+	referenceEngine.NewCircle(10, 30, 6, color.RGBA{200, 0, 0, 127})
+	referenceEngine.Display()
+	if err := sameImage(filled, reference); err != nil {
+		t.Error("thick ring is not the same as a circle:", err)
+		saveTemporaryImages(t, "CircleOutline", 0, filled, reference)
+	}
+
+	if engine.ObjectAt(75, 80) != nil {
+		t.Error("found an object in the hole of the ring")
+	}
+	if engine.ObjectAt(63, 80) != spinner {
+		t.Error("did not find the ring at its edge")
+	}
+}
+
+// sineWave is a custom object, which only uses the public API as an external
+// package would.
+type sineWave struct {
+	x, y, width, amplitude int16
+	period, phase          float64
+	color                  color.RGBA
+}
+
+func (w *sineWave) BoundingBox() (x1, y1, x2, y2 int16) {
+	return w.x, w.y - w.amplitude, w.x + w.width, w.y + w.amplitude + 1
+}
+
+// Paint draws each column of the wave as a vertical line from the previous
+// sample to the current sample, so that there are no gaps.
+func (w *sineWave) Paint(t Tile, tileX, tileY int16) {
+	width, _ := t.Size()
+	for x := int16(0); x < width; x++ {
+		column := x + tileX - w.x
+		if column < 0 || column >= w.width {
+			continue
+		}
+		y1 := w.sample(column - 1)
+		y2 := w.sample(column)
+		if y1 > y2 {
+			y1, y2 = y2, y1
+		}
+		for y := y1; y <= y2; y++ {
+			t.Blend(x, y-tileY, w.color)
+		}
+	}
+}
+
+func (w *sineWave) sample(column int16) int16 {
+	angle := (float64(column)/w.period + w.phase) * 2 * math.Pi
+	return w.y + int16(math.Round(math.Sin(angle)*float64(w.amplitude)))
+}
+
+// Draw a custom object in a layer, and check that it is redrawn correctly after
+// an update.
+func TestCustomObject(t *testing.T) {
+	screen := imagescreen.NewScreen(100, 60)
+	engine := NewEngine(screen)
+	engine.SetBackgroundColor(color.RGBA{50, 50, 50, 255})
+	layer := engine.NewLayer(5, 5, 90, 50, color.RGBA{0, 0, 80, 255})
+	wave := &sineWave{x: 5, y: 25, width: 80, amplitude: 20, period: 40, color: color.RGBA{255, 255, 0, 255}}
+	custom := layer.Add(wave)
+	engine.Add(&sineWave{x: 0, y: 30, width: 100, amplitude: 10, period: 25, color: color.RGBA{0, 127, 0, 127}})
+	engine.Display()
+	matchImage(t, screen, "testdata/custom1.png")
+
+	if obj := engine.ObjectAt(50, 1); obj != nil {
+		t.Errorf("expected no object outside of the bounding boxes, got %v", obj)
+	}
+	if obj, ok := layer.ObjectAt(15, 12).(*Custom); !ok || obj.Object() != wave {
+		t.Error("ObjectAt did not return the custom object")
+	}
+
+	// Change the wave, and compare it against a wave drawn from scratch.
+	wave.phase = 0.25
+	wave.amplitude = 10
+	custom.Update()
+	engine.Display()
+	reference := imagescreen.NewScreen(100, 60)
+	referenceEngine := NewEngine(reference)
+	referenceEngine.SetBackgroundColor(color.RGBA{50, 50, 50, 255})
+	referenceLayer := referenceEngine.NewLayer(5, 5, 90, 50, color.RGBA{0, 0, 80, 255})
+	referenceLayer.Add(&sineWave{x: 5, y: 25, width: 80, amplitude: 10, period: 40, phase: 0.25, color: color.RGBA{255, 255, 0, 255}})
+	referenceEngine.Add(&sineWave{x: 0, y: 30, width: 100, amplitude: 10, period: 25, color: color.RGBA{0, 127, 0, 127}})
+	referenceEngine.Display()
+	if err := sameImage(screen, reference); err != nil {
+		t.Error("updated custom object:", err)
+		saveTemporaryImages(t, "CustomObject", 0, screen, reference)
+	}
+}
+
+// Test that clearing the display results in just the background color.
+func TestClear(t *testing.T) {
+	screen := imagescreen.NewScreen(100, 100)
+	engine := NewEngine(screen)
+	background := color.RGBA{50, 50, 50, 255}
+	engine.SetBackgroundColor(background)
+	engine.NewRectangle(10, 10, 50, 50, color.RGBA{255, 0, 0, 255})
+	engine.NewLine(0, 0, 99, 80, color.RGBA{255, 255, 255, 255})
+	layer := engine.NewLayer(30, 30, 50, 50, color.RGBA{0, 0, 255, 255})
+	layer.NewCircle(10, 10, 8, color.RGBA{0, 255, 0, 255})
+	engine.Display()
+
+	engine.Clear()
+	engine.Display()
+	for y := 0; y < 100; y++ {
+		for x := 0; x < 100; x++ {
+			if c := screen.RGBAAt(x, y); c != background {
+				t.Fatalf("pixel at X=%d Y=%d was not cleared: %v", x, y, c)
+			}
+		}
+	}
+
+	// New objects can be added after clearing.
+	engine.NewRectangle(10, 10, 50, 50, color.RGBA{255, 0, 0, 255})
+	engine.Display()
+	reference := imagescreen.NewScreen(100, 100)
+	referenceEngine := NewEngine(reference)
+	referenceEngine.SetBackgroundColor(background)
+	referenceEngine.NewRectangle(10, 10, 50, 50, color.RGBA{255, 0, 0, 255})
+	referenceEngine.Display()
+	if err := sameImage(screen, reference); err != nil {
+		t.Errorf("drawing after clearing resulted in a different image: %v", err)
+	}
+}
+
+// Test that screens with a size that isn't a multiple of TileSize are painted
+// entirely, without drawing outside of the screen.
+func TestScreenEdges(t *testing.T) {
+	sizes := [][2]int16{{129, 161}, {100, 100}, {3, 5}, {17, 8}, {8, 31}}
+	for _, size := range sizes {
+		width, height := size[0], size[1]
+		screen := &boundsCheckScreen{Screen: imagescreen.NewScreen(width, height)}
+		engine := NewEngine(screen)
+		background := color.RGBA{50, 50, 50, 255}
+		engine.SetBackgroundColor(background)
+		engine.Display()
+
+		if screen.err != nil {
+			t.Errorf("screen %dx%d: %v", width, height, screen.err)
+		}
+		for y := 0; y < int(height); y++ {
+			for x := 0; x < int(width); x++ {
+				if c := screen.RGBAAt(x, y); c != background {
+					t.Fatalf("screen %dx%d: pixel at X=%d Y=%d was not painted: %v", width, height, x, y, c)
+				}
+			}
+		}
+	}
+}
+
+// Test that objects are still drawn correctly after the display has been made
+// larger or smaller.
+func TestResize(t *testing.T) {
+	screen := &boundsCheckScreen{Screen: imagescreen.NewScreen(60, 40)}
+	engine := NewEngine(screen)
+	drawTileSizeScene(engine)
+	for _, size := range []image.Point{{100, 90}, {37, 21}, {100, 100}} {
+		screen.Screen = imagescreen.NewScreen(int16(size.X), int16(size.Y))
+		engine.Resize()
+		engine.Display()
+		if screen.err != nil {
+			t.Fatalf("screen %dx%d: %v", size.X, size.Y, screen.err)
+		}
+
+		reference := imagescreen.NewScreen(int16(size.X), int16(size.Y))
+		drawTileSizeScene(NewEngine(reference))
+		if err := sameImage(screen.Screen, reference); err != nil {
+			t.Errorf("screen %dx%d: resized display is different: %v", size.X, size.Y, err)
+			saveTemporaryImages(t, "Resize", size.X, screen.Screen, reference)
+		}
+	}
+}
+
+// Test that asynchronous flushing results in the same image and the same
+// updates (in the same order) as synchronous flushing.
+func TestAsyncFlush(t *testing.T) {
+	reference := imagescreen.NewScreen(100, 100)
+	referenceScreen := recordscreen.NewScreen(reference)
+	drawTileSizeScene(NewEngine(referenceScreen))
+
+	for _, tileSize := range []int16{1, 8, 30} {
+		display := imagescreen.NewScreen(100, 100)
+		screen := recordscreen.NewScreen(display)
+		engine := NewEngineWithTileSize(screen, tileSize)
+		engine.SetAsyncFlush(true)
+		drawTileSizeScene(engine)
+		if err := sameImage(display, reference); err != nil {
+			t.Errorf("tile size %d: async flush resulted in a different image: %v", tileSize, err)
+			saveTemporaryImages(t, "AsyncFlush", int(tileSize), display, reference)
+		}
+		if tileSize == TileSize && !reflect.DeepEqual(screen.Calls, referenceScreen.Calls) {
+			t.Error("async flush resulted in different updates")
+		}
+	}
+}
+
+// Test that asynchronous flushing doesn't allocate anything per frame: the
+// goroutine and its channels are set up once, on the first call to Display.
+func TestAsyncFlushAllocs(t *testing.T) {
+	engine := NewEngine(imagescreen.NewScreen(100, 100))
+	engine.SetAsyncFlush(true)
+	rect := engine.NewRectangle(10, 10, 20, 20, color.RGBA{255, 0, 0, 255})
+	engine.Display()
+	x := int16(10)
+	allocs := testing.AllocsPerRun(10, func() {
+		x++
+		rect.Move(x, 10, 20, 20)
+		engine.Display()
+	})
+	if allocs != 0 {
+		t.Errorf("expected no allocations per frame, got %.1f", allocs)
+	}
+	engine.SetAsyncFlush(false)
+}
+
+// Test that the tile size doesn't influence the rendered output, by drawing the
+// same scene with a few different tile sizes.
+func TestTileSize(t *testing.T) {
+	reference := imagescreen.NewScreen(100, 100)
+	drawTileSizeScene(NewEngine(reference))
+	for _, tileSize := range []int16{1, 5, 16, 32, 128} {
+		screen := imagescreen.NewScreen(100, 100)
+		drawTileSizeScene(NewEngineWithTileSize(screen, tileSize))
+		if err := sameImage(screen, reference); err != nil {
+			t.Errorf("tile size %d resulted in a different image: %v", tileSize, err)
+			saveTemporaryImages(t, "TileSize", int(tileSize), screen, reference)
+		}
+	}
+}
+
+// Test that tile sizes outside of the supported range are rejected, instead of
+// resulting in a division by zero or in overflowing tile indices later on.
+func TestTileSizeInvalid(t *testing.T) {
+	for _, tileSize := range []int16{-1, 0, 129, 1000} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("tile size %d: expected a panic", tileSize)
+				}
+			}()
+			NewEngineWithTileSize(imagescreen.NewScreen(100, 100), tileSize)
+		}()
+	}
+}
+
+// Benchmark drawing and moving a few objects with different tile sizes.
+func BenchmarkTileSize(b *testing.B) {
+	for _, tileSize := range []int16{8, 16, 32} {
+		b.Run(fmt.Sprintf("%d", tileSize), func(b *testing.B) {
+			screen := imagescreen.NewScreen(160, 128)
+			for i := 0; i < b.N; i++ {
+				drawTileSizeScene(NewEngineWithTileSize(screen, tileSize))
+			}
+		})
+	}
+}
+
+// drawTileSizeScene draws a number of objects on the given engine, moves them
+// around a bit and updates the screen after every change.
+func drawTileSizeScene(engine *Engine) {
+	engine.SetBackgroundColor(color.RGBA{50, 50, 50, 255})
+	layer := engine.NewLayer(20, 15, 60, 70, color.RGBA{0, 0, 100, 150})
+	rect := layer.NewRectangle(-5, 10, 30, 30, color.RGBA{255, 0, 0, 255})
+	circle := engine.NewCircle(60, 60, 20, color.RGBA{0, 127, 0, 127})
+	engine.NewLine(3, 90, 97, 4, color.RGBA{255, 255, 255, 255})
+	engine.Display()
+	for i := int16(0); i < 10; i++ {
+		rect.Move(-5+i*3, 10+i, 30, 30)
+		circle.Move(60-i, 60, 20-i)
+		engine.Display()
+	}
+}
+
+// Test that moving a small rectangle only redraws a few tiles, as reported by
+// LastStats.
+func TestFrameStats(t *testing.T) {
+	screen := imagescreen.NewScreen(100, 100)
+	engine := NewEngine(screen)
+	rect := engine.NewRectangle(20, 20, 10, 10, color.RGBA{255, 0, 0, 255})
+	engine.Display()
+	const numTiles = 13 * 13
+	if stats := engine.LastStats(); stats.TilesDrawn != numTiles || stats.TilesSkipped != 0 {
+		t.Errorf("first frame: expected all %d tiles to be drawn, got %+v", numTiles, stats)
+	}
+
+	rect.Move(23, 21, 10, 10)
+	engine.Display()
+	stats := engine.LastStats()
+	if stats.TilesDrawn == 0 || stats.TilesDrawn > 9 {
+		t.Errorf("moved rectangle: expected a handful of tiles to be drawn, got %+v", stats)
+	}
+	if stats.TilesDrawn+stats.TilesSkipped != numTiles {
+		t.Errorf("moved rectangle: expected %d tiles in total, got %+v", numTiles, stats)
+	}
+	if stats.BytesSent == 0 || stats.BytesSent > stats.TilesDrawn*TileSize*TileSize*4 {
+		t.Errorf("moved rectangle: unexpected number of bytes sent: %+v", stats)
+	}
+
+	engine.Display()
+	if stats := engine.LastStats(); stats.TilesDrawn != 0 || stats.BytesSent != 0 {
+		t.Errorf("unchanged frame: expected nothing to be drawn, got %+v", stats)
+	}
+}
+
+// Test that the frame callback is called once for every call to Display, with
+// the same statistics as LastStats.
+func TestOnFrame(t *testing.T) {
+	screen := imagescreen.NewScreen(100, 100)
+	engine := NewEngine(screen)
+	var frames []FrameStats
+	engine.SetOnFrame(func(stats FrameStats) {
+		frames = append(frames, stats)
+	})
+	rect := engine.NewRectangle(20, 20, 10, 10, color.RGBA{255, 0, 0, 255})
+	engine.Display()
+	rect.Move(23, 21, 10, 10)
+	engine.Display()
+	engine.Display() // nothing changed
+	if len(frames) != 3 {
+		t.Fatalf("expected the callback to be called 3 times, got %d", len(frames))
+	}
+	for i, stats := range frames[:2] {
+		if stats.TilesDrawn == 0 {
+			t.Errorf("frame %d: expected tiles to be drawn, got %+v", i, stats)
+		}
+	}
+	if frames[2].TilesDrawn != 0 {
+		t.Errorf("unchanged frame: expected nothing to be drawn, got %+v", frames[2])
+	}
+	if frames[2] != engine.LastStats() {
+		t.Errorf("expected the same statistics as LastStats, got %+v and %+v", frames[2], engine.LastStats())
+	}
+
+	engine.SetOnFrame(nil)
+	rect.Move(50, 50, 10, 10)
+	engine.Display()
+	if len(frames) != 3 {
+		t.Errorf("expected the callback to be removed, got %d calls", len(frames))
+	}
+}
+
+// Test that InvalidateRegion only causes the overlapping tiles to be repainted,
+// and InvalidateAll the whole screen.
+func TestInvalidateRegion(t *testing.T) {
+	display := imagescreen.NewScreen(100, 100)
+	screen := recordscreen.NewScreen(display)
+	engine := NewEngine(screen)
+	engine.SetBackgroundColor(color.RGBA{50, 50, 50, 255})
+	engine.NewRectangle(13, 20, 30, 21, color.RGBA{255, 0, 0, 255})
+	engine.NewCircle(60, 60, 20, color.RGBA{0, 127, 0, 127})
+	engine.Display()
+	reference := *display.RGBA
+	reference.Pix = append([]uint8(nil), display.Pix...)
+
+	// Draw over the screen behind the back of the engine.
+	display.FillRectangle(0, 0, 100, 100, color.RGBA{255, 255, 255, 255})
+
+	screen.Reset()
+	engine.InvalidateRegion(20, 30, 10, 5)
+	engine.Display()
+	if len(screen.Calls) == 0 {
+		t.Fatal("invalidated region: nothing was drawn")
+	}
+	for _, call := range screen.Calls {
+		if call.X < 16 || call.Y < 24 || call.X+call.Width > 32 || call.Y+call.Height > 40 {
+			t.Errorf("invalidated region: unexpected update outside of the region: %+v", call)
+		}
+	}
+	if stats := engine.LastStats(); stats.TilesDrawn != 4 {
+		t.Errorf("invalidated region: expected 4 tiles to be drawn, got %+v", stats)
+	}
+
+	screen.Reset()
+	engine.InvalidateAll()
+	engine.Display()
+	if stats := engine.LastStats(); stats.TilesDrawn != 13*13 {
+		t.Errorf("invalidated all: expected all tiles to be drawn, got %+v", stats)
+	}
+	if err := sameImage(display, &reference); err != nil {
+		t.Error("invalidated all:", err)
+	}
+}
+
+// Draw to one screen, then switch to another screen of the same size and check
+// that it is painted completely.
+func TestSetDisplay(t *testing.T) {
+	first := imagescreen.NewScreen(50, 40)
+	engine := NewEngine(first)
+	engine.SetBackgroundColor(color.RGBA{50, 50, 50, 255})
+	engine.NewRectangle(5, 5, 20, 10, color.RGBA{255, 0, 0, 255})
+	engine.NewCircle(30, 25, 10, color.RGBA{0, 0, 255, 127})
+	engine.Display()
+
+	second := imagescreen.NewScreen(50, 40)
+	if err := engine.SetDisplay(second); err != nil {
+		t.Fatal("could not switch display:", err)
+	}
+	if !engine.Display() {
+		t.Error("nothing was drawn after switching displays")
+	}
+	if stats := engine.LastStats(); stats.TilesSkipped != 0 {
+		t.Errorf("expected the whole display to be redrawn, got %+v", stats)
+	}
+	if err := sameImage(second, first); err != nil {
+		t.Error(err)
+		saveTemporaryImages(t, "SetDisplay", 0, second, first)
+	}
+
+	// Screens of a different size are rejected, and the engine keeps using
+	// the current screen.
+	if err := engine.SetDisplay(imagescreen.NewScreen(40, 50)); err != ErrDisplaySizeMismatch {
+		t.Errorf("expected ErrDisplaySizeMismatch, got %v", err)
+	}
+	engine.NewRectangle(0, 0, 10, 10, color.RGBA{0, 255, 0, 255})
+	engine.Display()
+	if c := second.RGBAAt(5, 5); c != (color.RGBA{0, 255, 0, 255}) {
+		t.Errorf("expected the current display to be updated, got %v", c)
+	}
+}
+
+// Switch between two scenes, and check that only the active scene is drawn and
+// that changes to a hidden scene don't cause any redraws.
+func TestScenes(t *testing.T) {
+	// Draw the first scene, using the default scene that the engine starts
+	// with.
+	screen := imagescreen.NewScreen(64, 48)
+	engine := NewEngine(screen)
+	engine.SetBackgroundColor(color.RGBA{50, 50, 50, 255})
+	engine.NewRectangle(5, 5, 20, 10, color.RGBA{255, 0, 0, 255})
+	engine.NewCircle(40, 30, 10, color.RGBA{0, 0, 255, 255})
+
+	// Draw the second scene.
+	menu := engine.AddScene("menu")
+	if engine.AddScene("menu") != menu {
+		t.Error("adding an existing scene returned a new layer")
+	}
+	menu.SetBackgroundColor(color.RGBA{0, 100, 0, 255})
+	menu.NewRectangle(10, 20, 44, 8, color.RGBA{255, 255, 255, 255})
+	engine.Display()
+
+	// The reference images, each drawn by a separate engine.
+	mainReference := imagescreen.NewScreen(64, 48)
+	mainEngine := NewEngine(mainReference)
+	mainEngine.SetBackgroundColor(color.RGBA{50, 50, 50, 255})
+	mainEngine.NewRectangle(5, 5, 20, 10, color.RGBA{255, 0, 0, 255})
+	mainEngine.NewCircle(40, 30, 10, color.RGBA{0, 0, 255, 255})
+	mainEngine.Display()
+	menuReference := imagescreen.NewScreen(64, 48)
+	menuEngine := NewEngine(menuReference)
+	menuEngine.SetBackgroundColor(color.RGBA{0, 100, 0, 255})
+	menuEngine.NewRectangle(10, 20, 44, 8, color.RGBA{255, 255, 255, 255})
+	menuEngine.Display()
+
+	if err := sameImage(screen, mainReference); err != nil {
+		t.Error("default scene:", err)
+		saveTemporaryImages(t, "Scenes", 0, screen, mainReference)
+	}
+
+	// Changes to a hidden scene don't need a redraw.
+	menu.NewRectangle(0, 0, 64, 10, color.RGBA{0, 0, 0, 255})
+	menuEngine.NewRectangle(0, 0, 64, 10, color.RGBA{0, 0, 0, 255})
+	menuEngine.Display()
+	if engine.Display() {
+		t.Error("changing a hidden scene caused a redraw")
+	}
+
+	// Switch to the menu, which repaints the whole screen.
+	engine.ShowScene("menu")
+	engine.Display()
+	if stats := engine.LastStats(); stats.TilesSkipped != 0 {
+		t.Errorf("switched scene: expected all tiles to be redrawn, got %+v", stats)
+	}
+	if err := sameImage(screen, menuReference); err != nil {
+		t.Error("menu scene:", err)
+		saveTemporaryImages(t, "Scenes", 1, screen, menuReference)
+	}
+	if obj := engine.ObjectAt(5, 5); obj == nil || obj == engine.ObjectAt(30, 22) {
+		t.Error("ObjectAt did not use the active scene")
+	}
+
+	// Switch back to the default scene.
+	engine.ShowScene("unknown")
+	engine.ShowScene("")
+	engine.Display()
+	if err := sameImage(screen, mainReference); err != nil {
+		t.Error("back to default scene:", err)
+		saveTemporaryImages(t, "Scenes", 2, screen, mainReference)
+	}
+}
+
+// Test that ColorAt returns the same colors as a rendered image, for
+// overlapping transparent objects.
+func TestColorAt(t *testing.T) {
+	screen := imagescreen.NewScreen(100, 100)
+	engine := NewEngine(screen)
+	engine.SetBackgroundColor(color.RGBA{50, 50, 50, 255})
+	engine.NewRectangle(10, 10, 50, 40, color.RGBA{127, 0, 0, 127})
+	engine.NewRectangle(30, 25, 50, 40, color.RGBA{0, 100, 0, 100})
+	layer := engine.NewLayer(40, 45, 40, 40, color.RGBA{0, 0, 80, 80})
+	layer.NewCircle(20, 20, 15, color.RGBA{200, 200, 0, 200})
+	engine.Display()
+
+	for y := int16(0); y < 100; y++ {
+		for x := int16(0); x < 100; x++ {
+			if c := engine.ColorAt(x, y); c != screen.RGBAAt(int(x), int(y)) {
+				t.Fatalf("pixel mismatch at X=%d Y=%d: expected %v, got %v", x, y, screen.RGBAAt(int(x), int(y)), c)
+			}
+		}
+	}
+
+	// Pixels outside of the screen have the background color.
+	if c := engine.ColorAt(-3, 105); c != (color.RGBA{50, 50, 50, 255}) {
+		t.Errorf("unexpected color outside the screen: %v", c)
+	}
+
+	// With a transparent background, pixels are blended over the background
+	// color as well, except in tiles that aren't sent at all.
+	photo := color.RGBA{0, 200, 255, 255}
+	screen.FillRectangle(0, 0, 100, 100, photo)
+	engine.SetTransparentBackground(true)
+	engine.Display()
+	for y := int16(0); y < 100; y++ {
+		for x := int16(0); x < 100; x++ {
+			c := engine.ColorAt(x, y)
+			if c.A == 0 {
+				c = photo
+			}
+			if c != screen.RGBAAt(int(x), int(y)) {
+				t.Fatalf("transparent background: pixel mismatch at X=%d Y=%d: expected %v, got %v", x, y, screen.RGBAAt(int(x), int(y)), c)
+			}
+		}
+	}
+}
+
+// Test that screens smaller than a single tile (or even empty screens) work,
+// drawing only the visible part of the tile.
+func TestSmallScreen(t *testing.T) {
+	for _, size := range [][2]int16{{4, 4}, {1, 1}, {11, 3}, {0, 0}, {0, 5}} {
+		display := imagescreen.NewScreen(size[0], size[1])
+		screen := &boundsCheckScreen{Screen: display}
+		engine := NewEngine(screen)
+		engine.SetBackgroundColor(color.RGBA{0, 0, 255, 255})
+		engine.NewRectangle(1, 1, 20, 20, color.RGBA{255, 0, 0, 255})
+		engine.NewLine(0, 0, 30, 2, color.RGBA{0, 255, 0, 255})
+		engine.Display()
+		engine.InvalidateAll()
+		engine.Display()
+		if screen.err != nil {
+			t.Errorf("%dx%d: %v", size[0], size[1], screen.err)
+		}
+		for y := 0; y < int(size[1]); y++ {
+			for x := 0; x < int(size[0]); x++ {
+				if c := engine.ColorAt(int16(x), int16(y)); c != display.RGBAAt(x, y) {
+					t.Errorf("%dx%d: pixel mismatch at X=%d Y=%d: expected %v, got %v", size[0], size[1], x, y, c, display.RGBAAt(x, y))
+				}
+			}
+		}
+	}
+}
+
+// Test that an unchanged frame doesn't send anything to the screen, and
+// doesn't even call Display on it.
+func TestUnchangedFrame(t *testing.T) {
+	screen := recordscreen.NewScreen(imagescreen.NewScreen(100, 100))
+	engine := NewEngine(screen)
+	engine.SetBackgroundColor(color.RGBA{50, 50, 50, 255})
+	engine.NewRectangle(13, 20, 30, 21, color.RGBA{255, 0, 0, 255})
+	engine.NewCircle(60, 60, 20, color.RGBA{0, 127, 0, 127})
+	engine.Display()
+	if len(screen.Calls) == 0 {
+		t.Fatal("first frame: nothing was drawn")
+	}
+
+	screen.Reset()
+	if engine.Display() {
+		t.Error("unchanged frame: Display reported that something was drawn")
+	}
+	if len(screen.Calls) != 0 {
+		t.Errorf("unchanged frame: expected no updates, got %d: %v", len(screen.Calls), screen.Calls)
+	}
+	if screen.Displays != 0 {
+		t.Errorf("unchanged frame: expected Display not to be called, got %d calls", screen.Displays)
+	}
+
+	// Changing something must update the screen again.
+	engine.SetBackgroundColor(color.RGBA{0, 0, 0, 255})
+	if !engine.Display() {
+		t.Error("changed frame: Display reported that nothing was drawn")
+	}
+	if screen.Displays != 1 {
+		t.Errorf("changed frame: expected Display to be called once, got %d", screen.Displays)
+	}
+}
+
+// Check that with tile hashing, over-broad invalidation doesn't result in
+// redundant updates, while real changes are still sent.
+func TestTileHashing(t *testing.T) {
+	for _, async := range []bool{false, true} {
+		screen := recordscreen.NewScreen(imagescreen.NewScreen(100, 60))
+		engine := NewEngine(screen)
+		engine.SetTileHashing(true)
+		engine.SetAsyncFlush(async)
+		engine.SetBackgroundColor(color.RGBA{50, 50, 50, 255})
+		rect := engine.NewRectangle(10, 5, 30, 20, color.RGBA{255, 0, 0, 255})
+		engine.NewCircle(60, 30, 20, color.RGBA{0, 0, 255, 127})
+		engine.Display()
+
+		// Move the rectangle away and back, and set the same background color
+		// again. This invalidates most of the screen, but nothing changed.
+		screen.Reset()
+		rect.Move(50, 30, 30, 20)
+		rect.Move(10, 5, 30, 20)
+		engine.SetBackgroundColor(color.RGBA{50, 50, 50, 255})
+		if engine.Display() {
+			t.Errorf("async=%v: Display reported that something was drawn", async)
+		}
+		if len(screen.Calls) != 0 || screen.Displays != 0 {
+			t.Errorf("async=%v: expected no updates, got %d calls: %v", async, len(screen.Calls), screen.Calls)
+		}
+		if stats := engine.LastStats(); stats.TilesUnchanged == 0 || stats.TilesDrawn != 0 {
+			t.Errorf("async=%v: unexpected stats for an unchanged frame: %+v", async, stats)
+		}
+
+		// A real change is sent, but only the tiles that actually changed.
+		screen.Reset()
+		rect.Move(10, 5, 31, 20)
+		engine.Display()
+		if stats := engine.LastStats(); stats.TilesDrawn != 4 {
+			t.Errorf("async=%v: expected 4 tiles to be sent, got %+v", async, stats)
+		}
+
+		// Invalidating a region explicitly always sends the tiles.
+		screen.Reset()
+		engine.InvalidateRegion(0, 0, 16, 8)
+		engine.Display()
+		if len(screen.Calls) != 2 {
+			t.Errorf("async=%v: expected 2 updates after InvalidateRegion, got %d: %v", async, len(screen.Calls), screen.Calls)
+		}
+	}
+}
+
+// Move many overlapping objects before a single call to Display, and check
+// that every tile is sent only once and that exactly the same tiles are sent as
+// when displaying after every move.
+func TestBatchedChanges(t *testing.T) {
+	newScene := func() (*recordscreen.Screen, *Engine, []*Rectangle) {
+		screen := recordscreen.NewScreen(imagescreen.NewScreen(100, 60))
+		engine := NewEngine(screen)
+		var rects []*Rectangle
+		for i := int16(0); i < 10; i++ {
+			rects = append(rects, engine.NewRectangle(5+i*3, 5+i*2, 30, 20, color.RGBA{uint8(i * 25), 0, 255, 255}))
+		}
+		engine.Display()
+		screen.Reset()
+		return screen, engine, rects
+	}
+
+	// Move all rectangles, displaying after every move.
+	sequential, sequentialEngine, rects := newScene()
+	sequentialTiles := make(map[[2]int16]bool)
+	for i, rect := range rects {
+		rect.Move(40-int16(i)*2, 30-int16(i), 30, 20)
+		sequential.Reset()
+		sequentialEngine.Display()
+		for _, call := range sequential.Calls {
+			sequentialTiles[[2]int16{call.X, call.Y}] = true
+		}
+	}
+
+	// Move all rectangles in a single batch.
+	batched, batchedEngine, rects := newScene()
+	for i, rect := range rects {
+		rect.Move(40-int16(i)*2, 30-int16(i), 30, 20)
+	}
+	batchedEngine.Display()
+	batchedTiles := make(map[[2]int16]bool)
+	for _, call := range batched.Calls {
+		tile := [2]int16{call.X, call.Y}
+		if batchedTiles[tile] {
+			t.Errorf("tile at X=%d Y=%d was sent more than once", call.X, call.Y)
+		}
+		batchedTiles[tile] = true
+	}
+	if !reflect.DeepEqual(sequentialTiles, batchedTiles) {
+		t.Errorf("batched and sequential moves sent different tiles: %d versus %d tiles", len(batchedTiles), len(sequentialTiles))
+	}
+}
+
+// Check that tiles are sent in screen order (top to bottom, then left to
+// right), both when flushing synchronously and asynchronously.
+func TestFlushOrder(t *testing.T) {
+	for _, async := range []bool{false, true} {
+		screen := recordscreen.NewScreen(imagescreen.NewScreen(100, 60))
+		engine := NewEngine(screen)
+		engine.SetAsyncFlush(async)
+		engine.NewRectangle(10, 5, 80, 50, color.RGBA{255, 0, 0, 255})
+		engine.NewCircle(50, 30, 20, color.RGBA{0, 0, 255, 255})
+		for frame := 0; frame < 2; frame++ {
+			screen.Reset()
+			engine.Display()
+			if len(screen.Calls) == 0 {
+				t.Errorf("async=%v frame %d: nothing was drawn", async, frame)
+			}
+			for i := 1; i < len(screen.Calls); i++ {
+				prev, call := screen.Calls[i-1], screen.Calls[i]
+				if call.Y < prev.Y || (call.Y == prev.Y && call.X <= prev.X) {
+					t.Errorf("async=%v frame %d: tile at X=%d Y=%d was sent after X=%d Y=%d", async, frame, call.X, call.Y, prev.X, prev.Y)
+				}
+			}
+
+			// Only redraw part of the screen on the next frame.
+			engine.InvalidateRegion(30, 10, 40, 40)
+		}
+	}
+}
+
+// Check that in interlaced mode, first all even tiles and then all odd tiles are
+// sent (each in screen order), and that all of them are sent.
+func TestInterlaced(t *testing.T) {
+	display := imagescreen.NewScreen(100, 60)
+	screen := recordscreen.NewScreen(display)
+	engine := NewEngine(screen)
+	engine.SetInterlaced(true)
+	engine.NewRectangle(10, 5, 80, 50, color.RGBA{255, 0, 0, 255})
+	engine.NewCircle(50, 30, 20, color.RGBA{0, 0, 255, 255})
+	engine.Display()
+
+	// The screen is 13x8 tiles, the last column is partially visible.
+	if len(screen.Calls) != 13*8 {
+		t.Errorf("expected %d tiles to be sent, got %d", 13*8, len(screen.Calls))
+	}
+	seen := make(map[[2]int16]bool)
+	pass := 0
+	for i, call := range screen.Calls {
+		if seen[[2]int16{call.X, call.Y}] {
+			t.Errorf("tile at X=%d Y=%d was sent twice", call.X, call.Y)
+		}
+		seen[[2]int16{call.X, call.Y}] = true
+		tilePass := int((call.X/TileSize + call.Y/TileSize) % 2)
+		if tilePass < pass {
+			t.Errorf("even tile at X=%d Y=%d was sent after the first odd tile", call.X, call.Y)
+		}
+		pass = tilePass
+		if i > 0 && tilePass == int((screen.Calls[i-1].X/TileSize+screen.Calls[i-1].Y/TileSize)%2) {
+			prev := screen.Calls[i-1]
+			if call.Y < prev.Y || (call.Y == prev.Y && call.X <= prev.X) {
+				t.Errorf("tile at X=%d Y=%d was sent after X=%d Y=%d in the same pass", call.X, call.Y, prev.X, prev.Y)
+			}
+		}
+	}
+
+	// The result is the same as without interlacing.
+	reference := imagescreen.NewScreen(100, 60)
+	referenceEngine := NewEngine(reference)
+	referenceEngine.NewRectangle(10, 5, 80, 50, color.RGBA{255, 0, 0, 255})
+	referenceEngine.NewCircle(50, 30, 20, color.RGBA{0, 0, 255, 255})
+	referenceEngine.Display()
+	if err := sameImage(display, reference); err != nil {
+		t.Error(err)
+	}
+}
+
+// Move a rectangle under a static region, and check that the tiles of that
+// region are never sent to the display while the rest of the screen is still
+// updated. After removing the static region, those tiles are sent again.
+func TestStaticRegion(t *testing.T) {
+	screen := recordscreen.NewScreen(imagescreen.NewScreen(64, 64))
+	engine := NewEngine(screen)
+	engine.SetStaticRegion(0, 0, 64, 16) // the top two rows of tiles
+	rect := engine.NewRectangle(0, 0, 20, 20, color.RGBA{255, 0, 0, 255})
+	checkCalls := func(step string) {
+		for _, call := range screen.Calls {
+			if call.Y < 16 {
+				t.Errorf("%s: static region was updated: %+v", step, call)
+			}
+		}
+		if len(screen.Calls) == 0 {
+			t.Errorf("%s: nothing was sent to the display", step)
+		}
+	}
+	engine.Display()
+	checkCalls("first frame")
+	for i := int16(1); i <= 5; i++ {
+		screen.Reset()
+		rect.Move(i*10, i*4, 20, 20)
+		engine.Display()
+		checkCalls(fmt.Sprintf("move %d", i))
+	}
+	screen.Reset()
+	engine.InvalidateAll()
+	engine.Display()
+	checkCalls("InvalidateAll")
+
+	// Only the tiles of the static region need to be sent after removing it.
+	screen.Reset()
+	engine.SetStaticRegion(0, 0, 0, 0)
+	engine.Display()
+	if stats := engine.LastStats(); stats.TilesDrawn != 16 {
+		t.Errorf("expected the 16 tiles of the static region to be drawn after removing it, got %d", stats.TilesDrawn)
+	}
+	for _, call := range screen.Calls {
+		if call.Y >= 16 {
+			t.Errorf("unexpected update outside of the removed static region: %+v", call)
+		}
+	}
+}
+
+// Draw a rectangle with a transparent background over a "photo" that is
+// already on the display, and check that only the tiles under the rectangle
+// are sent to the display.
+func TestTransparentBackground(t *testing.T) {
+	photo := color.RGBA{0, 200, 100, 255}
+	display := imagescreen.NewScreen(32, 32)
+	display.FillRectangle(0, 0, 32, 32, photo)
+	screen := recordscreen.NewScreen(display)
+	engine := NewEngine(screen)
+	engine.SetBackgroundColor(color.RGBA{0, 0, 255, 255})
+	engine.SetTransparentBackground(true)
+	engine.NewRectangle(10, 8, 10, 8, color.RGBA{255, 0, 0, 255})
+	engine.Display()
+
+	// Only the two tiles in the second row of tiles are covered by the
+	// rectangle.
+	if len(screen.Calls) != 2 {
+		t.Errorf("expected 2 updates, got %d: %+v", len(screen.Calls), screen.Calls)
+	}
+	for _, call := range screen.Calls {
+		if call.Y != 8 || (call.X != 8 && call.X != 16) {
+			t.Errorf("unexpected update outside of the rectangle: %+v", call)
+		}
+	}
+	if stats := engine.LastStats(); stats.TilesDrawn != 2 || stats.TilesSkipped != 14 {
+		t.Errorf("expected 2 tiles to be drawn and 14 to be skipped, got %+v", stats)
+	}
+	for _, tc := range []struct {
+		x, y     int
+		expected color.RGBA
+	}{
+		{0, 0, photo},
+		{31, 31, photo},
+		{12, 10, color.RGBA{255, 0, 0, 255}},
+		{8, 8, color.RGBA{0, 0, 255, 255}}, // uncovered pixel in a sent tile
+	} {
+		if c := display.RGBAAt(tc.x, tc.y); c != tc.expected {
+			t.Errorf("pixel at (%d, %d): expected %v, got %v", tc.x, tc.y, tc.expected, c)
+		}
+	}
+
+	// Without a transparent background, the whole display is painted again.
+	screen.Reset()
+	engine.SetTransparentBackground(false)
+	engine.Display()
+	if stats := engine.LastStats(); stats.TilesDrawn != 16 {
+		t.Errorf("expected all 16 tiles to be drawn, got %+v", stats)
+	}
+	if c := display.RGBAAt(0, 0); c != (color.RGBA{0, 0, 255, 255}) {
+		t.Errorf("expected the background color after disabling the transparent background, got %v", c)
+	}
+}
+
+// Check that Snapshot returns the same image as what is sent to a display, and
+// that it doesn't change which tiles are sent on the next call to Display. The
+// screen size isn't a multiple of the tile size.
+func TestSnapshot(t *testing.T) {
+	display := imagescreen.NewScreen(50, 30)
+	screen := recordscreen.NewScreen(display)
+	engine := NewEngine(screen)
+	engine.SetBackgroundColor(color.RGBA{50, 50, 50, 255})
+	engine.NewRectangle(5, 5, 20, 10, color.RGBA{255, 0, 0, 255})
+	layer := engine.NewLayer(20, 10, 28, 18, color.RGBA{0, 0, 100, 150})
+	layer.NewCircle(14, 9, 8, color.RGBA{0, 255, 0, 200})
+	snapshot := engine.Snapshot()
+	if len(screen.Calls) != 0 {
+		t.Errorf("expected Snapshot to not touch the display, got %d calls", len(screen.Calls))
+	}
+
+	engine.Display()
+	if err := sameImage(display, snapshot); err != nil {
+		t.Error("snapshot differs from the display:", err)
+	}
+	if stats := engine.LastStats(); stats.TilesDrawn != 28 {
+		t.Errorf("expected all 28 tiles to be drawn after Snapshot, got %d", stats.TilesDrawn)
+	}
+
+	// With a transparent background, the snapshot is blended over the
+	// background color like the display. Tiles where nothing is drawn are
+	// left untouched on the display, which starts out transparent.
+	display = imagescreen.NewScreen(50, 30)
+	engine = NewEngine(display)
+	engine.SetBackgroundColor(color.RGBA{0, 0, 200, 255})
+	engine.SetTransparentBackground(true)
+	engine.NewRectangle(5, 5, 20, 10, color.RGBA{180, 0, 0, 128})
+	snapshot = engine.Snapshot()
+	engine.Display()
+	if err := sameImage(display, snapshot); err != nil {
+		t.Error("transparent background: snapshot differs from the display:", err)
+	}
+}
+
+// Place 100 rectangles and a long line entirely off-screen, and check that only
+// the background is sent to the display. Moving them around off-screen must not
+// cause any tiles to be redrawn.
+func TestOffscreenObjects(t *testing.T) {
+	background := color.RGBA{50, 50, 50, 255}
+	screen := recordscreen.NewScreen(imagescreen.NewScreen(64, 48))
+	engine := NewEngine(screen)
+	engine.SetBackgroundColor(background)
+	var rects []*Rectangle
+	for i := int16(0); i < 100; i++ {
+		var x, y int16
+		switch i % 4 {
+		case 0:
+			x, y = -20-i, i // left of the screen
+		case 1:
+			x, y = 64+i, i // right of the screen
+		case 2:
+			x, y = i, -20-i // above the screen
+		case 3:
+			x, y = i, 48+i // below the screen
+		}
+		rects = append(rects, engine.NewRectangle(x, y, 10, 10, color.RGBA{255, 0, 0, 255}))
+	}
+	line := engine.NewLine(-30000, -100, -100, -5000, color.RGBA{255, 255, 255, 255})
+	layer := engine.NewLayer(10, 10, 20, 20, background)
+	hidden := layer.NewRectangle(25, 0, 10, 10, color.RGBA{0, 255, 0, 255}) // outside of the layer
+	engine.Display()
+	for _, call := range screen.Calls {
+		if call.Buffer || call.Color != background {
+			t.Errorf("expected only the background to be drawn, got %+v", call)
+		}
+	}
+
+	screen.Reset()
+	for _, rect := range rects {
+		rect.MoveBy(0, -200)
+	}
+	line.Move(-20000, 200, 30000, 5000)
+	hidden.MoveBy(10, 5)
+	if engine.Display() || len(screen.Calls) != 0 {
+		t.Errorf("expected nothing to be drawn after moving off-screen objects, got %d calls (%+v)", len(screen.Calls), engine.LastStats())
+	}
+}
+
+// Check that a screen with only the background color is filled with a single
+// FillScreen call (if supported), and that a screen with objects is still
+// painted tile by tile.
+func TestFillScreen(t *testing.T) {
+	screen := recordscreen.NewScreen(imagescreen.NewScreen(100, 100))
+	engine := NewEngine(screen)
+	engine.SetBackgroundColor(color.RGBA{50, 50, 50, 255})
+	engine.NewRectangle(13, 20, 30, 21, color.RGBA{255, 0, 0, 255})
+	engine.Display()
+	for _, call := range screen.Calls {
+		if call.Screen {
+			t.Fatalf("first frame: unexpected FillScreen call while there are objects on the screen")
+		}
+	}
+
+	screen.Reset()
+	engine.Clear()
+	engine.Display()
+	expected := recordscreen.Call{Width: 100, Height: 100, Screen: true, Color: color.RGBA{50, 50, 50, 255}}
+	if len(screen.Calls) != 1 || screen.Calls[0] != expected {
+		t.Errorf("cleared screen: expected a single FillScreen call, got %d calls: %v", len(screen.Calls), screen.Calls)
+	}
+	if screen.Displays != 1 {
+		t.Errorf("cleared screen: expected Display to be called once, got %d", screen.Displays)
+	}
+	if stats := engine.LastStats(); stats.TilesDrawn == 0 || stats.BytesSent != 4 {
+		t.Errorf("cleared screen: unexpected stats %+v", stats)
+	}
+
+	// Nothing changed, so nothing is sent.
+	screen.Reset()
+	if engine.Display() || len(screen.Calls) != 0 {
+		t.Errorf("unchanged frame: expected no updates, got %v", screen.Calls)
+	}
+
+	// Removing a single object only repaints the tiles it covered.
+	rect := engine.NewRectangle(13, 20, 30, 21, color.RGBA{255, 0, 0, 255})
+	engine.Display()
+	screen.Reset()
+	rect.Remove()
+	engine.Display()
+	for _, call := range screen.Calls {
+		if call.Screen {
+			t.Fatalf("removed object: unexpected FillScreen call")
+		}
+	}
+	if stats := engine.LastStats(); stats.TilesDrawn == 0 || stats.TilesSkipped == 0 {
+		t.Errorf("removed object: unexpected stats %+v", stats)
+	}
+
+	// A display that fails to fill the screen is painted tile by tile instead.
+	failing := imagescreen.NewScreen(100, 100)
+	engine = NewEngine(failingFillScreen{failing})
+	engine.SetBackgroundColor(color.RGBA{50, 50, 50, 255})
+	engine.Display()
+	if stats := engine.LastStats(); stats.TilesDrawn != 13*13 || stats.BytesSent != 13*13*4 {
+		t.Errorf("failing FillScreen: unexpected stats %+v", stats)
+	}
+	if c := failing.RGBAAt(99, 99); c != (color.RGBA{50, 50, 50, 255}) {
+		t.Errorf("failing FillScreen: unexpected color %v", c)
+	}
+
+	// A Displayer565 is sent a single color of 2 bytes.
+	engine = NewEngine(&fillScreen565{&screen565{quantizingScreen: quantizingScreen{Screen: imagescreen.NewScreen(100, 100)}}})
+	engine.Display()
+	if stats := engine.LastStats(); stats.BytesSent != 2 {
+		t.Errorf("Displayer565: expected 2 bytes to be sent, got %+v", stats)
+	}
+}
+
+// failingFillScreen is an imagescreen.Screen with a FillScreen method that
+// always fails.
+type failingFillScreen struct {
+	*imagescreen.Screen
+}
+
+func (s failingFillScreen) FillScreen(c color.RGBA) error {
+	return errors.New("FillScreen failed")
+}
+
+// fillScreen565 is a screen565 that also implements FillScreen.
+type fillScreen565 struct {
+	*screen565
+}
+
+func (s *fillScreen565) FillScreen(c color.RGBA) error {
+	width, height := s.Size()
+	return s.FillRectangle(0, 0, width, height, c)
+}
+
+// Paint a 16x16 region into a buffer and compare it against four 8x8 regions
+// and against what is drawn on the screen.
+func TestPaintRegion(t *testing.T) {
+	screen := imagescreen.NewScreen(100, 100)
+	engine := NewEngine(screen)
+	drawTileSizeScene(engine)
+
+	for _, pos := range []image.Point{{16, 8}, {43, 51}, {90, 90}} {
+		buffer := make([]color.RGBA, 16*16)
+		if err := engine.PaintRegion(int16(pos.X), int16(pos.Y), 16, 16, buffer); err != nil {
+			t.Fatal("could not paint region:", err)
+		}
+		for i := 0; i < 4; i++ {
+			tileX := int16(pos.X + i%2*8)
+			tileY := int16(pos.Y + i/2*8)
+			tile := make([]color.RGBA, 8*8)
+			engine.PaintRegion(tileX, tileY, 8, 8, tile)
+			for y := 0; y < 8; y++ {
+				for x := 0; x < 8; x++ {
+					bufferX := i%2*8 + x
+					bufferY := i/2*8 + y
+					if c1, c2 := buffer[bufferY*16+bufferX], tile[y*8+x]; c1 != c2 {
+						t.Fatalf("region at %v: pixel X=%d Y=%d differs from tile: %v, %v", pos, bufferX, bufferY, c1, c2)
+					}
+				}
+			}
+		}
+		for y := 0; y < 16; y++ {
+			for x := 0; x < 16; x++ {
+				if pos.X+x >= 100 || pos.Y+y >= 100 {
+					// Outside of the screen.
+					continue
+				}
+				if c1, c2 := buffer[y*16+x], screen.RGBAAt(pos.X+x, pos.Y+y); c1 != c2 {
+					t.Fatalf("region at %v: pixel X=%d Y=%d differs from screen: %v, %v", pos, x, y, c1, c2)
+				}
+			}
+		}
+	}
+
+	if err := engine.PaintRegion(0, 0, 4, 4, make([]color.RGBA, 15)); err != ErrBufferSizeMismatch {
+		t.Errorf("expected ErrBufferSizeMismatch, got %v", err)
+	}
+}
+
+// Paint a region of more than 32767 pixels (the whole screen) in one call, and
+// compare it against what is drawn on the screen.
+func TestPaintRegionLarge(t *testing.T) {
+	screen := imagescreen.NewScreen(240, 240)
+	engine := NewEngine(screen)
+	engine.SetBackgroundColor(color.RGBA{50, 50, 50, 255})
+	engine.NewRectangle(20, 30, 200, 150, color.RGBA{255, 0, 0, 255})
+	engine.NewCircle(180, 200, 50, color.RGBA{0, 127, 0, 127})
+	engine.Display()
+
+	buffer := make([]color.RGBA, 240*240)
+	if err := engine.PaintRegion(0, 0, 240, 240, buffer); err != nil {
+		t.Fatal("could not paint region:", err)
+	}
+	for y := 0; y < 240; y++ {
+		for x := 0; x < 240; x++ {
+			if c1, c2 := buffer[y*240+x], screen.RGBAAt(x, y); c1 != c2 {
+				t.Fatalf("pixel X=%d Y=%d differs from screen: %v, %v", x, y, c1, c2)
+			}
+		}
+	}
+}
+
+// Draw stacked semi-transparent rectangles with the engine, and check that the
+// result is the same as sending the same colors directly to a blending screen,
+// both using FillRectangle and FillRectangleWithBuffer.
+func TestBlendingScreen(t *testing.T) {
+	background := color.RGBA{50, 50, 50, 255}
+	rects := []struct {
+		x, y, width, height int16
+		c                   color.RGBA
+	}{
+		{10, 10, 50, 40, color.RGBA{200, 200, 0, 200}},
+		{30, 20, 50, 50, color.RGBA{0, 0, 127, 127}},
+		{5, 35, 90, 20, ApplyAlpha(color.RGBA{255, 0, 0, 255}, 80)},
+		{45, 5, 10, 90, color.RGBA{}},
+	}
+
+	screen := imagescreen.NewScreen(100, 100)
+	engine := NewEngine(screen)
+	engine.SetBackgroundColor(background)
+	for _, r := range rects {
+		engine.NewRectangle(r.x, r.y, r.width, r.height, r.c)
+	}
+	engine.Display()
+
+	reference := imagescreen.NewBlendingScreen(100, 100, Blend)
+	reference.FillRectangle(0, 0, 100, 100, background)
+	for i, r := range rects {
+		if i%2 == 0 {
+			reference.FillRectangle(r.x, r.y, r.width, r.height, r.c)
+			continue
+		}
+		buffer := make([]color.RGBA, int(r.width)*int(r.height))
+		for j := range buffer {
+			buffer[j] = r.c
+		}
+		if err := reference.FillRectangleWithBuffer(r.x, r.y, r.width, r.height, buffer); err != nil {
+			t.Fatal("could not fill rectangle:", err)
+		}
+	}
+	if err := sameImage(screen, reference); err != nil {
+		t.Error("engine output differs from the blending screen:", err)
+		saveTemporaryImages(t, "BlendingScreen", 0, screen, reference)
+	}
+}
+
+// Fade out a layer in a few steps, checking the alpha and the painted color of
+// the layer after every step. The painted color must be blended in linear color
+// space, not in sRGB space.
+func TestFade(t *testing.T) {
+	screen := imagescreen.NewScreen(16, 16)
+	engine := NewEngine(screen)
+	engine.SetBackgroundColor(color.RGBA{0, 0, 0, 255})
+	layer := engine.NewLayer(0, 0, 16, 16, color.RGBA{255, 255, 255, 255})
+	engine.Display()
+
+	fade := layer.FadeTo(0, 4)
+	var alphas []uint8
+	step := 0
+	for fade.Step() {
+		step++
+		engine.Display()
+		alphas = append(alphas, fade.Alpha())
+		expected := Blend(color.RGBA{0, 0, 0, 255}, Premultiply(color.RGBA{255, 255, 255, fade.Alpha()}))
+		if c := screen.RGBAAt(8, 8); c != expected {
+			t.Errorf("step %d: expected color %v, got %v", step, expected, c)
+		}
+	}
+	if fmt.Sprint(alphas) != "[191 128 64 0]" {
+		t.Errorf("unexpected alpha sequence: %v", alphas)
+	}
+	if !fade.Done() || fade.Step() {
+		t.Error("expected the fade to be done")
+	}
+
+	// The last displayed frame has the target alpha.
+	if c := screen.RGBAAt(8, 8); c != (color.RGBA{0, 0, 0, 255}) {
+		t.Errorf("last frame: expected the layer to be faded out, got %v", c)
+	}
+
+	// Halfway, the gray must be brighter than halfway in sRGB space.
+	if gray := Blend(color.RGBA{0, 0, 0, 255}, Premultiply(color.RGBA{255, 255, 255, 128})); gray.R <= 128 {
+		t.Errorf("expected a gamma-correct gray above 128, got %v", gray)
+	}
+}
+
+// Paint the same objects into a standalone 8x8 tile and into an 8x8 region of a
+// larger 16x16 buffer, and check that the result is the same and that pixels
+// outside of the region are left alone.
+func TestSubTile(t *testing.T) {
+	engine := NewEngine(imagescreen.NewScreen(32, 32))
+	rect := engine.NewRectangle(3, 2, 9, 7, ApplyAlpha(color.RGBA{255, 0, 0, 255}, 200))
+	line := engine.NewLine(1, 14, 13, 1, color.RGBA{0, 255, 0, 255})
+	line.SetWidth(3)
+	layer := engine.NewLayer(4, 4, 10, 10, ApplyAlpha(color.RGBA{0, 0, 255, 255}, 128))
+	layer.NewCircle(5, 5, 4, color.RGBA{255, 255, 0, 255})
+	layer.NewLine(0, 9, 9, 0, color.RGBA{255, 255, 255, 255})
+	layer.SetOpacity(200)
+
+	background := color.RGBA{50, 50, 50, 255}
+	outside := color.RGBA{1, 2, 3, 255}
+	for _, obj := range []Element{rect, line, layer} {
+		for _, pos := range []image.Point{{0, 0}, {5, 3}, {8, 8}} {
+			expected := newTile(8, 8)
+			for i := range expected.pixels {
+				expected.pixels[i] = background
+			}
+			obj.paint(expected, int16(pos.X), int16(pos.Y))
+
+			whole := newTile(16, 16)
+			for i := range whole.pixels {
+				whole.pixels[i] = outside
+			}
+			region := whole.subTile(5, 3, 8, 8)
+			for y := int16(0); y < 8; y++ {
+				for x := int16(0); x < 8; x++ {
+					region.pixels[y*region.stride+x] = background
+				}
+			}
+			obj.paint(&region, int16(pos.X), int16(pos.Y))
+
+			for y := 0; y < 16; y++ {
+				for x := 0; x < 16; x++ {
+					c := whole.pixels[y*16+x]
+					want := outside
+					if x >= 5 && x < 13 && y >= 3 && y < 11 {
+						want = expected.pixels[(y-3)*8+x-5]
+					}
+					if c != want {
+						t.Errorf("%T at %v: pixel X=%d Y=%d is %v, expected %v", obj, pos, x, y, c, want)
+					}
+				}
+			}
+		}
+	}
+}
+
+// Move a rectangle across the right edge of a wrapping layer and put a circle
+// on its bottom right corner, and check that both re-enter at the opposite
+// edges. The result must be the same as drawing the shifted copies explicitly.
+func TestLayerWrap(t *testing.T) {
+	screen := imagescreen.NewScreen(100, 50)
+	engine := NewEngine(screen)
+	engine.SetBackgroundColor(color.RGBA{50, 50, 50, 255})
+	layer := engine.NewLayer(5, 5, 90, 40, color.RGBA{255, 255, 255, 255})
+	layer.SetWrap(true)
+	rect := layer.NewRectangle(30, 10, 30, 15, color.RGBA{255, 0, 0, 255})
+	layer.NewCircle(88, 38, 8, color.RGBA{0, 0, 255, 255})
+	engine.Display()
+	rect.Move(75, 10, 30, 15)
+	engine.Display()
+	matchImage(t, screen, "testdata/wrap1.png")
 
-		// Move the layer around a few times, checking with a reference each
-		// time that's built from scratch.
-		const layerCycleMax = 8
-		for layerCycle := 0; layerCycle < layerCycleMax; layerCycle++ {
-			layerX := int16(rand.Int31()%200 - 50)
-			layerY := int16(rand.Int31()%200 - 50)
-			layerWidth := int16(rand.Int31() % 50)
-			layerHeight := int16(rand.Int31() % 50)
-			layer.Move(layerX, layerY, layerWidth, layerHeight)
-			engine.Display()
+	reference := imagescreen.NewScreen(100, 50)
+	referenceEngine := NewEngine(reference)
+	referenceEngine.SetBackgroundColor(color.RGBA{50, 50, 50, 255})
+	referenceLayer := referenceEngine.NewLayer(5, 5, 90, 40, color.RGBA{255, 255, 255, 255})
+	for _, dx := range []int16{0, -90} {
+		referenceLayer.NewRectangle(75+dx, 10, 30, 15, color.RGBA{255, 0, 0, 255})
+	}
+	for _, dy := range []int16{0, -40} {
+		for _, dx := range []int16{0, -90} {
+			referenceLayer.NewCircle(88+dx, 38+dy, 8, color.RGBA{0, 0, 255, 255})
+		}
+	}
+	referenceEngine.Display()
+	if err := sameImage(screen, reference); err != nil {
+		t.Error("wrapped objects differ from the shifted copies:", err)
+		saveTemporaryImages(t, "LayerWrap", 0, screen, reference)
+	}
+}
 
-			reference := imagescreen.NewScreen(screenWidth, screenHeight)
-			referenceEngine := NewEngine(reference)
-			referenceEngine.SetBackgroundColor(color.RGBA{50, 50, 50, 255})
-			referenceLayer := referenceEngine.NewLayer(layerX, layerY, layerWidth, layerHeight, layerColor)
-			referenceLayer.NewRectangle(rectX, rectY, rectWidth, rectHeight, rectColor)
-			referenceEngine.Display()
+// Change a rectangle in a layer inside a wrapping layer, where the rectangle
+// straddles the edge of the wrapping layer. The copy at the opposite edge must
+// be updated as well, and must be found by ObjectAt.
+func TestLayerWrapNested(t *testing.T) {
+	screen := imagescreen.NewScreen(100, 50)
+	engine := NewEngine(screen)
+	engine.SetBackgroundColor(color.RGBA{50, 50, 50, 255})
+	layer := engine.NewLayer(5, 5, 90, 40, color.RGBA{255, 255, 255, 255})
+	layer.SetWrap(true)
+	inner := layer.NewLayer(70, 0, 40, 40, color.RGBA{})
+	rect := inner.NewRectangle(10, 5, 20, 20, color.RGBA{255, 0, 0, 255})
+	engine.Display()
 
-			if err := sameImage(screen, reference); err != nil {
-				t.Errorf("moving a layer didn't invalidate the correct area")
-				saveTemporaryImages(t, "LayerUpdate", screenCycle*screenCycleMax+layerCycle, screen, reference)
+	rect.SetColor(color.RGBA{0, 255, 0, 255})
+	engine.Display()
+	if err := sameImage(screen, engine.Snapshot()); err != nil {
+		t.Error("wrapped copy was not updated:", err)
+	}
+	if c := screen.RGBAAt(7, 17); c != (color.RGBA{0, 255, 0, 255}) {
+		t.Errorf("expected the wrapped copy at X=7 Y=17, got %v", c)
+	}
+	if obj := engine.ObjectAt(7, 17); obj != rect {
+		t.Errorf("expected to find the wrapped rectangle at X=7 Y=17, got %v", obj)
+	}
+}
+
+// Test that tiles with a single color are sent using FillRectangle, and that
+// this results in the same image.
+func TestUniformTiles(t *testing.T) {
+	screen := &countingScreen{Screen: imagescreen.NewScreen(100, 100)}
+	engine := NewEngine(screen)
+	background := color.RGBA{50, 50, 50, 255}
+	rectColor := color.RGBA{255, 0, 0, 255}
+	engine.SetBackgroundColor(background)
+	engine.NewRectangle(13, 20, 30, 21, rectColor)
+	engine.Display()
+
+	for y := 0; y < 100; y++ {
+		for x := 0; x < 100; x++ {
+			expected := background
+			if x >= 13 && x < 43 && y >= 20 && y < 41 {
+				expected = rectColor
+			}
+			if c := screen.RGBAAt(x, y); c != expected {
+				t.Fatalf("pixel at X=%d Y=%d: expected %v, got %v", x, y, expected, c)
+			}
+		}
+	}
+
+	// The rectangle touches 5 tile columns and 4 tile rows, of which it covers
+	// 3x2 tiles completely. So 14 tiles contain both colors.
+	if screen.fillBufferCalls != 14 {
+		t.Errorf("expected 14 calls to FillRectangleWithBuffer, got %d", screen.fillBufferCalls)
+	}
+	if screen.fillCalls != 13*13-14 {
+		t.Errorf("expected %d calls to FillRectangle, got %d", 13*13-14, screen.fillCalls)
+	}
+}
+
+// Benchmark redrawing a mostly empty screen, reporting the number of
+// FillRectangle and FillRectangleWithBuffer calls.
+func BenchmarkUniformTiles(b *testing.B) {
+	screen := &countingScreen{Screen: imagescreen.NewScreen(160, 128)}
+	engine := NewEngine(screen)
+	engine.NewRectangle(13, 20, 30, 21, color.RGBA{255, 0, 0, 255})
+	engine.NewCircle(100, 70, 15, color.RGBA{0, 0, 255, 255})
+	for i := 0; i < b.N; i++ {
+		engine.InvalidateAll()
+		engine.Display()
+	}
+	b.ReportMetric(float64(screen.fillCalls)/float64(b.N), "fills/op")
+	b.ReportMetric(float64(screen.fillBufferCalls)/float64(b.N), "bufferfills/op")
+}
+
+// Benchmark redrawing a screen that is mostly covered by an empty layer, like
+// in the reveal example.
+func BenchmarkEmptyLayer(b *testing.B) {
+	screen := imagescreen.NewScreen(160, 128)
+	engine := NewEngine(screen)
+	layer := engine.NewLayer(0, 0, 160, 100, color.RGBA{0, 0, 255, 255})
+	layer.NewRectangle(13, 20, 30, 21, color.RGBA{255, 0, 0, 255})
+	for i := 0; i < b.N; i++ {
+		engine.InvalidateAll()
+		engine.Display()
+	}
+}
+
+// countingScreen wraps an imagescreen.Screen and counts the number of calls to
+// FillRectangle and FillRectangleWithBuffer.
+type countingScreen struct {
+	*imagescreen.Screen
+	fillCalls       int
+	fillBufferCalls int
+}
+
+func (s *countingScreen) FillRectangle(x, y, width, height int16, c color.RGBA) error {
+	s.fillCalls++
+	return s.Screen.FillRectangle(x, y, width, height, c)
+}
+
+func (s *countingScreen) FillRectangleWithBuffer(x, y, width, height int16, buffer []color.RGBA) error {
+	s.fillBufferCalls++
+	return s.Screen.FillRectangleWithBuffer(x, y, width, height, buffer)
+}
+
+// Benchmark painting a frame of overlapping transparent objects on a screen
+// that takes a while to accept each tile, with and without asynchronous
+// flushing.
+func BenchmarkAsyncFlush(b *testing.B) {
+	for _, async := range []bool{false, true} {
+		name := "sync"
+		if async {
+			name = "async"
+		}
+		b.Run(name, func(b *testing.B) {
+			screen := &slowScreen{Screen: imagescreen.NewScreen(160, 128), delay: 20 * time.Microsecond}
+			engine := NewEngine(screen)
+			engine.SetAsyncFlush(async)
+			for i := int16(0); i < 10; i++ {
+				engine.NewCircle(20+i*12, 60, 40, color.RGBA{0, 100, 0, 100})
+				engine.NewRectangle(i*16, i*12, 40, 30, color.RGBA{100, 0, 0, 100})
 			}
+			engine.Display()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				engine.InvalidateAll()
+				engine.Display()
+			}
+		})
+	}
+}
+
+// slowScreen wraps an imagescreen.Screen and waits for a while on each
+// FillRectangleWithBuffer call, like a display connected over a slow bus. It
+// sleeps instead of busy-waiting, so that (like with DMA) the CPU is free to do
+// other work in the meantime.
+type slowScreen struct {
+	*imagescreen.Screen
+	delay time.Duration
+}
+
+func (s *slowScreen) FillRectangleWithBuffer(x, y, width, height int16, buffer []color.RGBA) error {
+	time.Sleep(s.delay)
+	return s.Screen.FillRectangleWithBuffer(x, y, width, height, buffer)
+}
+
+// boundsCheckScreen wraps an imagescreen.Screen and records an error when an
+// update falls outside of the screen or doesn't match the buffer size.
+type boundsCheckScreen struct {
+	*imagescreen.Screen
+	err error
+}
+
+func (s *boundsCheckScreen) FillRectangleWithBuffer(x, y, width, height int16, buffer []color.RGBA) error {
+	screenWidth, screenHeight := s.Size()
+	if x < 0 || y < 0 || x+width > screenWidth || y+height > screenHeight {
+		s.err = fmt.Errorf("rectangle x=%d y=%d width=%d height=%d falls outside the screen", x, y, width, height)
+	}
+	err := s.Screen.FillRectangleWithBuffer(x, y, width, height, buffer)
+	if err != nil {
+		s.err = err
+	}
+	return err
+}
+
+// Paint the same scene on a display with RGB565 pixels through the
+// Displayer565 interface and through the normal color.RGBA path, and check that
+// they result in the same image after the conversion to RGB565. The screen size
+// isn't a multiple of the tile size, to also test clipped tiles.
+func TestDisplayer565(t *testing.T) {
+	newScene := func(display Displayer, async bool) *Engine {
+		engine := NewEngine(display)
+		engine.SetAsyncFlush(async)
+		engine.SetBackgroundColor(color.RGBA{50, 50, 50, 255})
+		engine.NewGradientRectangle(5, 5, 90, 20, color.RGBA{255, 0, 0, 255}, color.RGBA{0, 0, 255, 255}, false)
+		layer := engine.NewLayer(20, 20, 60, 40, color.RGBA{0, 100, 0, 150})
+		layer.NewCircle(30, 20, 15, color.RGBA{127, 127, 0, 127})
+		engine.NewLine(0, 69, 99, 30, color.RGBA{255, 255, 255, 255})
+		return engine
+	}
+	for _, async := range []bool{false, true} {
+		reference := &quantizingScreen{Screen: imagescreen.NewScreen(100, 70)}
+		newScene(reference, async).Display()
+
+		screen := &screen565{quantizingScreen: quantizingScreen{Screen: imagescreen.NewScreen(100, 70)}}
+		engine := newScene(screen, async)
+		engine.Display()
+		if screen.fillBufferCalls != 0 || screen.fillBuffer565Calls == 0 {
+			t.Errorf("async=%v: expected only FillRectangleWithBuffer565 to be used, got %d calls to FillRectangleWithBuffer and %d calls to FillRectangleWithBuffer565", async, screen.fillBufferCalls, screen.fillBuffer565Calls)
+		}
+		if stats := engine.LastStats(); stats.BytesSent >= reference.bytes {
+			t.Errorf("async=%v: expected fewer than %d bytes to be sent, got %d", async, reference.bytes, stats.BytesSent)
+		}
+		if !async && engine.buffer565 != nil {
+			t.Error("expected tiles to be converted in place without asynchronous flushing")
+		}
+		if err := sameImage(screen.Screen, reference.Screen); err != nil {
+			t.Errorf("async=%v: %v", async, err)
+			saveTemporaryImages(t, "Displayer565", 0, screen.Screen, reference.Screen)
 		}
 	}
 }
 
-// Test that layers don't let their objects escape outside of the layer.
-func TestLayerBounds(t *testing.T) {
+// quantizingScreen wraps an imagescreen.Screen and reduces all colors to
+// RGB565 precision, like a display with 16-bit pixels. It also records the
+// number of bytes that were sent by the engine, according to FrameStats.
+type quantizingScreen struct {
+	*imagescreen.Screen
+	fillBufferCalls int
+	bytes           int
+}
+
+// fromRGB565 expands an RGB565 color to a color.RGBA by repeating the highest
+// bits in the lowest bits.
+func fromRGB565(c uint16) color.RGBA {
+	r := uint8(c>>11) << 3
+	g := uint8(c>>5) << 2
+	b := uint8(c) << 3
+	return color.RGBA{r | r>>5, g | g>>6, b | b>>5, 255}
+}
+
+func (s *quantizingScreen) FillRectangle(x, y, width, height int16, c color.RGBA) error {
+	s.bytes += 4
+	return s.Screen.FillRectangle(x, y, width, height, fromRGB565(ToRGB565(c)))
+}
+
+func (s *quantizingScreen) FillRectangleWithBuffer(x, y, width, height int16, buffer []color.RGBA) error {
+	s.fillBufferCalls++
+	s.bytes += len(buffer) * 4
+	quantized := make([]color.RGBA, len(buffer))
+	for i, c := range buffer {
+		quantized[i] = fromRGB565(ToRGB565(c))
+	}
+	return s.Screen.FillRectangleWithBuffer(x, y, width, height, quantized)
+}
+
+// screen565 is a quantizingScreen that implements Displayer565.
+type screen565 struct {
+	quantizingScreen
+	fillBuffer565Calls int
+}
+
+func (s *screen565) FillRectangleWithBuffer565(x, y, width, height int16, buffer []uint16) error {
+	s.fillBuffer565Calls++
+	if len(buffer) != int(width)*int(height) {
+		return ErrBufferSizeMismatch
+	}
+	expanded := make([]color.RGBA, len(buffer))
+	for i, c := range buffer {
+		expanded[i] = fromRGB565(c)
+	}
+	return s.Screen.FillRectangleWithBuffer(x, y, width, height, expanded)
+}
+
+// Draw a few arcs: a 270° progress ring, a small arc, and pie slices.
+func TestArc(t *testing.T) {
 	screen := imagescreen.NewScreen(100, 100)
 	engine := NewEngine(screen)
-	engine.SetBackgroundColor(color.RGBA{50, 50, 50, 255})
-	layer := engine.NewLayer(11, 7, 71, 83, color.RGBA{255, 255, 255, 255})
-	layer.NewRectangle(-5, -5, 30, 30, color.RGBA{255, 0, 0, 255})
-	layer.NewRectangle(60, 60, 30, 30, color.RGBA{0, 255, 0, 255})
+	engine.SetBackgroundColor(color.RGBA{255, 255, 255, 255})
+	engine.NewArc(30, 30, 25, 6, 135, 405, color.RGBA{200, 200, 200, 255})
+	arc := engine.NewArc(30, 30, 25, 6, 0, 10, color.RGBA{0, 0, 200, 255})
+	arc.SetAngles(135, 300)
+	engine.NewArc(75, 25, 15, 3, -30, 30, color.RGBA{200, 0, 0, 255})
+	engine.NewArc(30, 75, 20, 20, 0, 120, color.RGBA{0, 150, 0, 255})
+	engine.NewArc(75, 75, 20, 20, 45, 360, color.RGBA{0, 0, 127, 127})
 	engine.Display()
 
-	matchImage(t, screen, "testdata/layer1.png")
+	matchImage(t, screen, "testdata/arc1.png")
 }
 
-// Test drawing a few transparent rectangles partially over each other, and
-// check whether the image output matches the expected output.
-func TestRectTransparent(t *testing.T) {
+// Draw a wide and a tall ellipse, and a few degenerate ones.
+func TestEllipse(t *testing.T) {
 	screen := imagescreen.NewScreen(100, 100)
 	engine := NewEngine(screen)
-	engine.NewRectangle(10, 10, 50, 50, color.RGBA{127, 0, 0, 127})
-	engine.NewRectangle(25, 25, 50, 50, color.RGBA{0, 127, 0, 127})
-	engine.NewRectangle(40, 40, 50, 50, color.RGBA{0, 0, 127, 127})
+	engine.NewEllipse(40, 25, 35, 15, color.RGBA{255, 255, 0, 255})
+	engine.NewEllipse(60, 55, 12, 40, color.RGBA{0, 0, 127, 127})
+	engine.NewEllipse(10, 80, 0, 10, color.RGBA{255, 0, 0, 255})
+	ellipse := engine.NewEllipse(20, 80, 10, 0, color.RGBA{255, 0, 0, 255})
+	ellipse.Move(20, 80, 8, 5)
 	engine.Display()
 
-	matchImage(t, screen, "testdata/rect2.png")
+	matchImage(t, screen, "testdata/ellipse1.png")
 }
 
-// Test basic line rendering in all directions.
-func TestLine1(t *testing.T) {
+// Test drawing a few triangles, including a transparent one and one that is
+// partially outside of the screen.
+func TestTriangle(t *testing.T) {
 	screen := imagescreen.NewScreen(100, 100)
 	engine := NewEngine(screen)
 	engine.SetBackgroundColor(color.RGBA{255, 255, 255, 255})
-	engine.NewRectangle(8, 8, 85, 85, color.RGBA{0, 0, 0, 255})
-	for x := int16(10); x <= 90; x += 10 {
-		engine.NewLine(x, 10, 50, 50, color.RGBA{255, 255, 255, 255})
-		engine.NewLine(x, 90, 50, 50, color.RGBA{255, 255, 255, 255})
+	engine.NewTriangle(10, 10, 60, 20, 20, 70, color.RGBA{255, 0, 0, 255})
+	engine.NewTriangle(90, 40, 40, 50, 70, 90, color.RGBA{0, 0, 127, 127})
+	tr := engine.NewTriangle(0, 0, 1, 1, 2, 0, color.RGBA{0, 150, 0, 255})
+	tr.Move(80, -20, 120, 30, 60, 25)
+	engine.NewTriangle(5, 95, 50, 80, 5, 80, color.RGBA{0, 0, 0, 255})
+	engine.Display()
+
+	matchImage(t, screen, "testdata/triangle1.png")
+}
+
+// Test text rendering, including text that crosses tile boundaries and
+// transparent text.
+func TestText(t *testing.T) {
+	screen := imagescreen.NewScreen(100, 40)
+	engine := NewEngine(screen)
+	engine.NewText(10, 5, "Hi", Font5x8, color.RGBA{255, 255, 255, 255})
+	text := engine.NewText(3, 18, "", Font5x8, color.RGBA{255, 255, 0, 255})
+	text.SetText("Tiles: 42%, jpq!")
+	engine.NewRectangle(0, 28, 100, 12, color.RGBA{0, 0, 255, 255})
+	engine.NewText(-2, 30, "~translucent~", Font5x8, color.RGBA{127, 127, 127, 127})
+	engine.Display()
+
+	matchImage(t, screen, "testdata/text1.png")
+}
+
+// Draw the same text in all rotations, and check that each is the unrotated
+// text rotated pixel by pixel.
+func TestTextRotation(t *testing.T) {
+	screen := imagescreen.NewScreen(100, 60)
+	engine := NewEngine(screen)
+	engine.SetBackgroundColor(color.RGBA{50, 50, 50, 255})
+	engine.NewText(5, 2, "Axis 1", Font5x8, color.RGBA{255, 255, 255, 255})
+	for i, rotation := range []Rotation{Rotate90, Rotate180, Rotate270} {
+		text := engine.NewText(5+int16(i)*40, 14, "Axis 1", Font5x8, color.RGBA{255, 255, 0, 200})
+		text.SetRotation(rotation)
 	}
-	for y := int16(20); y <= 80; y += 10 {
-		engine.NewLine(10, y, 50, 50, color.RGBA{255, 255, 255, 255})
-		engine.NewLine(90, y, 50, 50, color.RGBA{255, 255, 255, 255})
+	engine.Display()
+	matchImage(t, screen, "testdata/textrotate1.png")
+
+	// The unrotated text as a reference.
+	reference := imagescreen.NewScreen(35, 8)
+	referenceEngine := NewEngine(reference)
+	referenceEngine.NewText(0, 0, "Axis 1", Font5x8, color.RGBA{255, 255, 255, 255})
+	referenceEngine.Display()
+	for _, rotation := range []Rotation{Rotate0, Rotate90, Rotate180, Rotate270} {
+		rotated := imagescreen.NewScreen(35, 35)
+		rotatedEngine := NewEngine(rotated)
+		text := rotatedEngine.NewText(0, 0, "Axis 1", Font5x8, color.RGBA{255, 255, 255, 255})
+		text.SetRotation(rotation)
+		rotatedEngine.Display()
+		if x1, y1, x2, y2 := text.boundingBox(); rotation%2 == 1 && (x2-x1 != 8 || y2-y1 != 35) {
+			t.Errorf("rotation %d: unexpected bounding box (%d, %d, %d, %d)", rotation, x1, y1, x2, y2)
+		}
+		for y := 0; y < 8; y++ {
+			for x := 0; x < 35; x++ {
+				var rx, ry int
+				switch rotation {
+				case Rotate0:
+					rx, ry = x, y
+				case Rotate90:
+					rx, ry = 7-y, x
+				case Rotate180:
+					rx, ry = 34-x, 7-y
+				case Rotate270:
+					rx, ry = y, 34-x
+				}
+				if expected, c := reference.RGBAAt(x, y), rotated.RGBAAt(rx, ry); c != expected {
+					t.Errorf("rotation %d: expected %v at X=%d Y=%d, got %v", rotation, expected, rx, ry, c)
+				}
+			}
+		}
+	}
+}
+
+// Test drawing a checkerboard image with transparent cut-outs at an offset,
+// partially outside of the screen.
+func TestSprite(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 24, 20))
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 24; x++ {
+			switch {
+			case x >= 8 && x < 16 && y >= 6 && y < 14:
+				// Transparent hole in the middle.
+			case (x/4+y/4)%2 == 0:
+				img.SetNRGBA(x, y, color.NRGBA{255, 255, 255, 255})
+			default:
+				img.SetNRGBA(x, y, color.NRGBA{255, 0, 0, 127})
+			}
+		}
 	}
+
+	screen := imagescreen.NewScreen(100, 100)
+	engine := NewEngine(screen)
+	engine.SetBackgroundColor(color.RGBA{0, 0, 100, 255})
+	engine.NewSprite(13, 11, img)
+	sprite := engine.NewSprite(0, 0, img)
+	sprite.Move(85, 50)
+	engine.NewSprite(40, 60, img.SubImage(image.Rect(4, 4, 20, 16)))
 	engine.Display()
 
-	matchImage(t, screen, "testdata/line1.png")
+	matchImage(t, screen, "testdata/sprite1.png")
 }
 
-// Test random lines in all directions, with colors and transparency.
-func TestLineBlend(t *testing.T) {
-	// Get a deterministic randomness source.
-	rand := rand.New(rand.NewSource(1))
+// Test a small gradient image as background: tiled on the display and clamped
+// in a layer.
+func TestBackgroundImage(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 30, 20))
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 30; x++ {
+			img.SetRGBA(x, y, color.RGBA{uint8(x * 8), uint8(y * 12), 128, 255})
+		}
+	}
 
-	// Create a screen to draw on.
-	const screenWidth = 100
-	const screenHeight = 100
-	screen := imagescreen.NewScreen(screenWidth, screenHeight)
+	screen := imagescreen.NewScreen(100, 100)
 	engine := NewEngine(screen)
+	engine.SetBackgroundImage(img, BackgroundTile)
+	layer := engine.NewLayer(0, 0, 30, 30, color.RGBA{0, 0, 0, 255})
+	layer.SetBackgroundImage(img, BackgroundClamp)
+	layer.Move(45, 55, 50, 40)
+	layer.NewRectangle(10, 10, 10, 10, color.RGBA{255, 255, 255, 255})
+	engine.Display()
 
-	// Draw some colors in the background.
-	engine.NewRectangle(0, 0, screenWidth/2, screenHeight/2, color.RGBA{255, 0, 0, 255})
-	engine.NewRectangle(screenWidth/2, 0, screenWidth, screenHeight/2, color.RGBA{0, 255, 0, 255})
-	engine.NewRectangle(0, screenHeight/2, screenWidth/2, screenHeight, color.RGBA{0, 0, 255, 255})
-	engine.NewRectangle(screenWidth/2, screenHeight/2, screenWidth, screenHeight, color.RGBA{255, 255, 0, 255})
+	matchImage(t, screen, "testdata/bgimage1.png")
+}
 
-	// Draw a lot of random lines.
-	for i := 0; i < 100; i++ {
-		x1 := int16(rand.Uint32()%(screenWidth+50) - 25)
-		y1 := int16(rand.Uint32()%(screenHeight+50) - 25)
-		x2 := int16(rand.Uint32()%(screenWidth+50) - 25)
-		y2 := int16(rand.Uint32()%(screenHeight+50) - 25)
-		lineColor := color.RGBA{uint8(rand.Uint32()), uint8(rand.Uint32()), uint8(rand.Uint32()), 255}
-		if rand.Uint32()%2 == 0 {
-			lineColor = ApplyAlpha(lineColor, uint8(rand.Uint32()))
+// Use a small texture as the tiled background of a layer (like a textured
+// panel) over a colored root, with a few transparent texture pixels that show
+// the layer background color.
+func TestLayerBackgroundImage(t *testing.T) {
+	texture := image.NewNRGBA(image.Rect(0, 0, 6, 6))
+	for y := 0; y < 6; y++ {
+		for x := 0; x < 6; x++ {
+			c := color.NRGBA{uint8(100 + x*20), uint8(80 + y*20), 60, 255}
+			if x == y {
+				c.A = 0
+			}
+			texture.SetNRGBA(x, y, c)
 		}
-		engine.NewLine(x1, y1, x2, y2, lineColor)
 	}
+
+	screen := imagescreen.NewScreen(64, 64)
+	engine := NewEngine(screen)
+	engine.SetBackgroundColor(color.RGBA{0, 0, 120, 255})
+	layer := engine.NewLayer(9, 13, 40, 35, color.RGBA{0, 0, 0, 255})
+	layer.SetBackgroundImage(texture, BackgroundTile)
+	layer.NewRectangle(10, 10, 12, 8, color.RGBA{255, 255, 255, 255})
 	engine.Display()
 
-	matchImage(t, screen, "testdata/line2.png")
+	matchImage(t, screen, "testdata/bgimage2.png")
 }
 
-// Test invalidation logic of a line. When a line is created, it should
-// invalidate at least all the tiles it touches, so that the next call to
-// Display() will correctly re-paint those tiles.
-func TestLineInvalidate(t *testing.T) {
+// Move and recolor a line a number of times, and check whether the result is
+// the same as creating the line from scratch. This tests the line invalidation
+// logic.
+func TestLineUpdate(t *testing.T) {
 	// Get a deterministic randomness source.
 	rand := rand.New(rand.NewSource(1))
 
-	// Test different display sizes/configurations.
-	for i := 0; i < 100; i++ {
-		screenWidth := int16(rand.Uint32()%20 + 100)
-		screenHeight := int16(rand.Uint32()%20 + 100)
-		screen := imagescreen.NewScreen(screenWidth,
-			screenHeight)
-		engine := NewEngine(screen)
-
-		// Draw some colors in the background.
-		engine.NewRectangle(0, 0, screenWidth/2, screenHeight/2, color.RGBA{255, 0, 0, 255})
-		engine.NewRectangle(screenWidth/2, 0, screenWidth, screenHeight/2, color.RGBA{0, 255, 0, 255})
-		engine.NewRectangle(0, screenHeight/2, screenWidth/2, screenHeight, color.RGBA{0, 0, 255, 255})
-		engine.NewRectangle(screenWidth/2, screenHeight/2, screenWidth, screenHeight, color.RGBA{255, 255, 0, 255})
-		engine.Display()
+	const screenWidth = 100
+	const screenHeight = 100
+	screen := imagescreen.NewScreen(screenWidth, screenHeight)
+	engine := NewEngine(screen)
+	engine.SetBackgroundColor(color.RGBA{50, 50, 50, 255})
+	line := engine.NewLine(10, 10, 90, 90, color.RGBA{255, 255, 255, 255})
+	engine.Display()
 
-		// Draw a line with a random color.
-		x1 := int16(rand.Int31())%(screenWidth+50) - 25
-		y1 := int16(rand.Int31())%(screenHeight+50) - 25
-		x2 := int16(rand.Int31())%(screenWidth+50) - 25
-		y2 := int16(rand.Int31())%(screenHeight+50) - 25
+	for i := 0; i < 50; i++ {
+		x1 := int16(rand.Uint32()%(screenWidth+50) - 25)
+		y1 := int16(rand.Uint32()%(screenHeight+50) - 25)
+		x2 := int16(rand.Uint32()%(screenWidth+50) - 25)
+		y2 := int16(rand.Uint32()%(screenHeight+50) - 25)
 		lineColor := color.RGBA{uint8(rand.Uint32()), uint8(rand.Uint32()), uint8(rand.Uint32()), 255}
 		if rand.Uint32()%2 == 0 {
 			lineColor = ApplyAlpha(lineColor, uint8(rand.Uint32()))
 		}
-		engine.NewLine(x1, y1, x2, y2, lineColor)
+		line.Move(x1, y1, x2, y2)
+		line.SetColor(lineColor)
 		engine.Display()
 
-		// Draw the background with the line at once.
 		reference := imagescreen.NewScreen(screenWidth, screenHeight)
 		referenceEngine := NewEngine(reference)
-		referenceEngine.NewRectangle(0, 0, screenWidth/2, screenHeight/2, color.RGBA{255, 0, 0, 255})
-		referenceEngine.NewRectangle(screenWidth/2, 0, screenWidth, screenHeight/2, color.RGBA{0, 255, 0, 255})
-		referenceEngine.NewRectangle(0, screenHeight/2, screenWidth/2, screenHeight, color.RGBA{0, 0, 255, 255})
-		referenceEngine.NewRectangle(screenWidth/2, screenHeight/2, screenWidth, screenHeight, color.RGBA{255, 255, 0, 255})
+		referenceEngine.SetBackgroundColor(color.RGBA{50, 50, 50, 255})
 		referenceEngine.NewLine(x1, y1, x2, y2, lineColor)
 		referenceEngine.Display()
-
 		if err := sameImage(screen, reference); err != nil {
-			t.Errorf("adding a line didn't invalidate the correct area")
-			saveTemporaryImages(t, "LineInvalidate", i, screen, reference)
+			t.Errorf("moving line to x1=%d y1=%d x2=%d y2=%d resulted in a different image from creating it from scratch: %v", x1, y1, x2, y2, err)
+			saveTemporaryImages(t, "LineUpdate", i, screen, reference)
 		}
 	}
 }
@@ -295,6 +3474,10 @@ func matchImage(t *testing.T, screen *imagescreen.Screen, path string) {
 	}
 	if err := sameImage(screen, reference); err != nil {
 		t.Errorf("image %s didn't match: %s", path, err)
+		diffPath := fmt.Sprintf("/tmp/graphics-%s-diff.png", strings.TrimSuffix(filepath.Base(path), ".png"))
+		if saveDiffImage(diffPath, screen, reference) == nil {
+			t.Error("\tdiff:", diffPath)
+		}
 	}
 }
 
@@ -309,6 +3492,17 @@ func saveTemporaryImages(t *testing.T, name string, num int, image1, image2 *ima
 	if saveImage(path2, image2) == nil {
 		t.Error("\timage 2:", path2)
 	}
+	diffPath := fmt.Sprintf("/tmp/graphics-%s-%d-diff.png", name, num)
+	if saveDiffImage(diffPath, image1, image2) == nil {
+		t.Error("\tdiff:", diffPath)
+	}
+}
+
+// saveDiffImage stores a visual diff of both images (see imagescreen.Compare)
+// as a PNG image.
+func saveDiffImage(path string, image1 *imagescreen.Screen, image2 image.Image) error {
+	diff := imagescreen.Compare(image1, image2)
+	return saveImage(path, &imagescreen.Screen{RGBA: diff.Image})
 }
 
 // sameImage returns nil if both images are the same, or an error when they
@@ -320,12 +3514,8 @@ func sameImage(screen *imagescreen.Screen, reference image.Image) error {
 		return fmt.Errorf("image is the wrong size: width=%d height=%d versus reference width=%d height=%d", width, height, referenceRect.Max.X, referenceRect.Max.Y)
 	}
 
-	for y := 0; y < referenceRect.Max.Y; y++ {
-		for x := 0; x < referenceRect.Max.X; x++ {
-			if reference.At(x, y) != screen.At(x, y) {
-				return fmt.Errorf("pixel mismatch at X=%d Y=%d", x, y)
-			}
-		}
+	if diff := imagescreen.Compare(screen, reference); diff.Count != 0 {
+		return fmt.Errorf("%d pixels differ in the area X=%d..%d Y=%d..%d", diff.Count, diff.Bounds.Min.X, diff.Bounds.Max.X-1, diff.Bounds.Min.Y, diff.Bounds.Max.Y-1)
 	}
 
 	return nil // the same image
@@ -0,0 +1,66 @@
+package imagescreen
+
+import (
+	"image"
+	"image/color"
+)
+
+// Diff describes the differences between two images, see Compare.
+type Diff struct {
+	// Count is the number of pixels that differ.
+	Count int
+
+	// Bounds is the smallest rectangle that contains all pixels that differ.
+	// It is empty when the images are the same.
+	Bounds image.Rectangle
+
+	// Image is a visual diff: the first image in faded grayscale, with all
+	// pixels that differ in magenta. It covers the bounds of both images.
+	Image *image.RGBA
+}
+
+// magenta is the color used for differing pixels in a visual diff.
+var magenta = color.RGBA{255, 0, 255, 255}
+
+// Compare compares two images pixel by pixel, which is useful for finding out
+// why a test failed. Colors are compared by their RGBA values, so the images
+// may use different color models. Pixels that only fall within one of both
+// images are counted as different.
+func Compare(a, b image.Image) Diff {
+	boundsA := a.Bounds()
+	boundsB := b.Bounds()
+	bounds := boundsA.Union(boundsB)
+	diff := Diff{
+		Image: image.NewRGBA(bounds),
+	}
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			p := image.Point{x, y}
+			inA := p.In(boundsA)
+			if inA && p.In(boundsB) && sameColor(a.At(x, y), b.At(x, y)) {
+				diff.Image.SetRGBA(x, y, faded(a.At(x, y)))
+				continue
+			}
+			diff.Image.SetRGBA(x, y, magenta)
+			diff.Count++
+			diff.Bounds = diff.Bounds.Union(image.Rect(x, y, x+1, y+1))
+		}
+	}
+	return diff
+}
+
+// sameColor returns whether both colors have the same RGBA values.
+func sameColor(a, b color.Color) bool {
+	r1, g1, b1, a1 := a.RGBA()
+	r2, g2, b2, a2 := b.RGBA()
+	return r1 == r2 && g1 == g2 && b1 == b2 && a1 == a2
+}
+
+// faded returns a light gray version of the given color, so that the magenta
+// pixels in a visual diff stand out while the shapes in the image can still be
+// recognized.
+func faded(c color.Color) color.RGBA {
+	r, g, b, _ := c.RGBA()
+	gray := uint8((r+g+b)/3>>8)/4 + 160
+	return color.RGBA{gray, gray, gray, 255}
+}
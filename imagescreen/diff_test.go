@@ -0,0 +1,56 @@
+package imagescreen
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// Compare two images that differ in a few known pixels, and check the count,
+// the bounds and the visual diff.
+func TestCompare(t *testing.T) {
+	a := NewScreen(10, 8)
+	a.FillRectangle(0, 0, 10, 8, color.RGBA{0, 0, 255, 255})
+	b := NewScreen(10, 8)
+	b.FillRectangle(0, 0, 10, 8, color.RGBA{0, 0, 255, 255})
+
+	if diff := Compare(a, b); diff.Count != 0 || !diff.Bounds.Empty() {
+		t.Errorf("expected no differences between equal images, got %d in %v", diff.Count, diff.Bounds)
+	}
+
+	b.FillRectangle(2, 3, 2, 1, color.RGBA{255, 0, 0, 255})
+	b.FillRectangle(6, 5, 1, 1, color.RGBA{0, 0, 254, 255})
+	diff := Compare(a, b)
+	if diff.Count != 3 {
+		t.Errorf("expected 3 differing pixels, got %d", diff.Count)
+	}
+	if diff.Bounds != image.Rect(2, 3, 7, 6) {
+		t.Errorf("unexpected bounds of the differences: %v", diff.Bounds)
+	}
+	for _, p := range []image.Point{{2, 3}, {3, 3}, {6, 5}} {
+		if c := diff.Image.RGBAAt(p.X, p.Y); c != magenta {
+			t.Errorf("expected pixel %v to be magenta in the diff, got %v", p, c)
+		}
+	}
+	if c := diff.Image.RGBAAt(0, 0); c == magenta || c.R != c.G || c.G != c.B {
+		t.Errorf("expected an equal pixel to be gray in the diff, got %v", c)
+	}
+
+	// The same colors in a different color model are equal.
+	nrgba := image.NewNRGBA(image.Rect(0, 0, 10, 8))
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 10; x++ {
+			nrgba.Set(x, y, color.NRGBA{0, 0, 255, 255})
+		}
+	}
+	if diff := Compare(a, nrgba); diff.Count != 0 {
+		t.Errorf("expected no differences with an NRGBA image, got %d", diff.Count)
+	}
+
+	// Pixels that only fall within one of the images are different.
+	small := NewScreen(10, 6)
+	small.FillRectangle(0, 0, 10, 6, color.RGBA{0, 0, 255, 255})
+	if diff := Compare(a, small); diff.Count != 20 || diff.Bounds != image.Rect(0, 6, 10, 8) {
+		t.Errorf("expected the missing rows to be different, got %d in %v", diff.Count, diff.Bounds)
+	}
+}
@@ -18,16 +18,31 @@ var (
 // interface. It is used for testing.
 type Screen struct {
 	*image.RGBA
+
+	// blend is used to draw incoming colors over the existing pixels, or nil
+	// if incoming colors replace the existing pixels.
+	blend func(bottom, top color.RGBA) color.RGBA
 }
 
 // NewScreen returns an in-memory memory buffer that acts as a screen,
 // implementing the Displayer interface.
 func NewScreen(width, height int16) *Screen {
 	return &Screen{
-		image.NewRGBA(image.Rect(0, 0, int(width), int(height))),
+		RGBA: image.NewRGBA(image.Rect(0, 0, int(width), int(height))),
 	}
 }
 
+// NewBlendingScreen returns a screen like NewScreen, except that all colors
+// sent to it are blended over the pixels that are already on the screen using
+// the given blend function (usually tilegraphics.Blend) instead of replacing
+// them. This can be used to build test references out of semi-transparent
+// colors without going through the engine.
+func NewBlendingScreen(width, height int16, blend func(bottom, top color.RGBA) color.RGBA) *Screen {
+	s := NewScreen(width, height)
+	s.blend = blend
+	return s
+}
+
 // Size returns the width and height of this screen.
 func (s *Screen) Size() (int16, int16) {
 	rect := s.Bounds()
@@ -44,7 +59,7 @@ func (s *Screen) Display() error {
 func (s *Screen) FillRectangle(x, y, width, height int16, c color.RGBA) error {
 	for pixelY := y; pixelY < y+height; pixelY++ {
 		for pixelX := x; pixelX < x+width; pixelX++ {
-			s.Set(int(pixelX), int(pixelY), c)
+			s.set(int(pixelX), int(pixelY), c)
 		}
 	}
 	return nil
@@ -58,8 +73,17 @@ func (s *Screen) FillRectangleWithBuffer(x, y, width, height int16, buffer []col
 	}
 	for pixelY := 0; pixelY < int(height); pixelY++ {
 		for pixelX := 0; pixelX < int(width); pixelX++ {
-			s.Set(int(x)+pixelX, int(y)+pixelY, buffer[pixelY*int(width)+pixelX])
+			s.set(int(x)+pixelX, int(y)+pixelY, buffer[pixelY*int(width)+pixelX])
 		}
 	}
 	return nil
 }
+
+// set stores a single pixel, blending it over the existing pixel for a
+// blending screen.
+func (s *Screen) set(x, y int, c color.RGBA) {
+	if s.blend != nil {
+		c = s.blend(s.RGBAAt(x, y), c)
+	}
+	s.SetRGBA(x, y, c)
+}
@@ -0,0 +1,191 @@
+// Package monoscreen wraps a monochrome display (such as the SSD1306 OLED) to
+// implement the Displayer interface as required by tilegraphics. Colors are
+// converted to black and white using a luminance threshold or using ordered
+// dithering.
+package monoscreen
+
+import (
+	"errors"
+	"image/color"
+)
+
+var (
+	// ErrBufferSizeMismatch is returned when the size of the buffer passed to
+	// FillRectangleWithBuffer doesn't match the to-be-updated area.
+	ErrBufferSizeMismatch = errors.New("monoscreen: buffer size did not match width*height")
+)
+
+// Device is a 1-bit display with a framebuffer in the SSD1306 page format:
+// every byte is a vertical column of 8 pixels with the least significant bit
+// at the top, and the bytes are ordered first by column and then by page (row
+// of 8 pixels high).
+type Device interface {
+	// Size returns the display size in pixels. The height must be a multiple
+	// of 8.
+	Size() (int16, int16)
+
+	// SetBuffer replaces the framebuffer of the device with the given buffer,
+	// which has a length of width*height/8 bytes.
+	SetBuffer(buffer []byte) error
+
+	// Display sends the framebuffer to the screen.
+	Display() error
+}
+
+// Mode determines how colors are converted to black and white.
+type Mode uint8
+
+const (
+	// Threshold turns on a pixel when its luminance is at least the threshold
+	// value. This is the default.
+	Threshold Mode = iota
+
+	// Dither uses ordered dithering with a 4x4 Bayer matrix, so that colors
+	// appear as a pattern of pixels with roughly the same average brightness.
+	// The threshold value is ignored.
+	Dither
+)
+
+// bayer4 is the 4x4 Bayer matrix used for ordered dithering.
+var bayer4 = [4][4]uint8{
+	{0, 8, 2, 10},
+	{12, 4, 14, 6},
+	{3, 11, 1, 9},
+	{15, 7, 13, 5},
+}
+
+// Screen converts all colors to 1-bit pixels and keeps them in a framebuffer
+// in the page format of the device, which is sent to the device on Display. It
+// needs width*height/8 bytes of memory.
+type Screen struct {
+	device        Device
+	width, height int16
+	mode          Mode
+	threshold     uint8
+
+	// buffer is the framebuffer in the SSD1306 page format.
+	buffer []byte
+
+	// dirty is true when the buffer has been changed since the last call to
+	// Display.
+	dirty bool
+}
+
+// NewScreen returns a new screen that wraps the given monochrome device. It
+// starts in threshold mode with a threshold of 128. The device must not change
+// in size.
+func NewScreen(device Device) *Screen {
+	width, height := device.Size()
+	return &Screen{
+		device:    device,
+		width:     width,
+		height:    height,
+		threshold: 128,
+		buffer:    make([]byte, int(width)*int((height+7)/8)),
+	}
+}
+
+// SetMode sets how colors are converted to black and white. It only affects
+// updates sent after this call.
+func (s *Screen) SetMode(mode Mode) {
+	s.mode = mode
+}
+
+// SetThreshold sets the minimum luminance (0-255) of a pixel that is turned on
+// in threshold mode. It only affects updates sent after this call.
+func (s *Screen) SetThreshold(threshold uint8) {
+	s.threshold = threshold
+}
+
+// Size returns the size of the underlying device.
+func (s *Screen) Size() (int16, int16) {
+	return s.width, s.height
+}
+
+// Display sends the framebuffer to the device if anything changed since the
+// last call to Display, and then flushes the device.
+func (s *Screen) Display() error {
+	if s.dirty {
+		err := s.device.SetBuffer(s.buffer)
+		if err != nil {
+			return err
+		}
+		s.dirty = false
+	}
+	return s.device.Display()
+}
+
+// FillRectangle fills the given rectangle with the given color. When
+// dithering, the rectangle is filled with a pattern.
+func (s *Screen) FillRectangle(x, y, width, height int16, c color.RGBA) error {
+	x1, y1, x2, y2 := s.clip(x, y, width, height)
+	luminance := Luminance(c)
+	for pixelY := y1; pixelY < y2; pixelY++ {
+		for pixelX := x1; pixelX < x2; pixelX++ {
+			s.setPixel(pixelX, pixelY, luminance)
+		}
+	}
+	s.dirty = true
+	return nil
+}
+
+// FillRectangleWithBuffer converts the given buffer to 1-bit pixels and stores
+// them in the framebuffer. The buffer must be in row major order.
+func (s *Screen) FillRectangleWithBuffer(x, y, width, height int16, buffer []color.RGBA) error {
+	if width < 0 || height < 0 || len(buffer) != int(width)*int(height) {
+		return ErrBufferSizeMismatch
+	}
+	x1, y1, x2, y2 := s.clip(x, y, width, height)
+	for pixelY := y1; pixelY < y2; pixelY++ {
+		row := buffer[int(pixelY-y)*int(width):]
+		for pixelX := x1; pixelX < x2; pixelX++ {
+			s.setPixel(pixelX, pixelY, Luminance(row[pixelX-x]))
+		}
+	}
+	s.dirty = true
+	return nil
+}
+
+// setPixel turns the given pixel on or off, depending on the luminance and the
+// current mode. The pixel must be within the screen.
+func (s *Screen) setPixel(x, y int16, luminance uint8) {
+	var on bool
+	if s.mode == Dither {
+		// The Bayer matrix values are scaled to the range 8-248, so that black
+		// is always off and white is always on.
+		on = luminance >= bayer4[y%4][x%4]*16+8
+	} else {
+		on = luminance >= s.threshold
+	}
+	index := int(y/8)*int(s.width) + int(x)
+	if on {
+		s.buffer[index] |= 1 << uint(y%8)
+	} else {
+		s.buffer[index] &^= 1 << uint(y%8)
+	}
+}
+
+// clip returns the part of the given rectangle that lies within the screen.
+func (s *Screen) clip(x, y, width, height int16) (x1, y1, x2, y2 int16) {
+	x1, y1, x2, y2 = x, y, x+width, y+height
+	if x1 < 0 {
+		x1 = 0
+	}
+	if y1 < 0 {
+		y1 = 0
+	}
+	if x2 > s.width {
+		x2 = s.width
+	}
+	if y2 > s.height {
+		y2 = s.height
+	}
+	return
+}
+
+// Luminance returns the perceived brightness of a color (0-255), using the
+// Rec. 709 weights directly on the gamma-encoded values. The alpha channel is
+// ignored.
+func Luminance(c color.RGBA) uint8 {
+	return uint8((uint32(c.R)*54 + uint32(c.G)*183 + uint32(c.B)*19) >> 8)
+}
@@ -0,0 +1,145 @@
+package monoscreen
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/aykevl/tilegraphics"
+)
+
+// Make sure Screen can be used by tilegraphics.
+var _ tilegraphics.Displayer = (*Screen)(nil)
+
+// testDevice is an in-memory monochrome display.
+type testDevice struct {
+	width, height int16
+	buffer        []byte
+	displays      int
+}
+
+func (d *testDevice) Size() (int16, int16) {
+	return d.width, d.height
+}
+
+func (d *testDevice) SetBuffer(buffer []byte) error {
+	d.buffer = append(d.buffer[:0], buffer...)
+	return nil
+}
+
+func (d *testDevice) Display() error {
+	d.displays++
+	return nil
+}
+
+// pixels returns the contents of the device as one string per row, with '#'
+// for pixels that are on and '.' for pixels that are off.
+func (d *testDevice) pixels() []string {
+	rows := make([]string, d.height)
+	for y := 0; y < int(d.height); y++ {
+		row := make([]byte, d.width)
+		for x := 0; x < int(d.width); x++ {
+			row[x] = '.'
+			if d.buffer[y/8*int(d.width)+x]&(1<<uint(y%8)) != 0 {
+				row[x] = '#'
+			}
+		}
+		rows[y] = string(row)
+	}
+	return rows
+}
+
+// gradient returns a horizontal gray gradient from black to white that is 16
+// pixels wide.
+func gradient(height int) []color.RGBA {
+	buffer := make([]color.RGBA, 16*height)
+	for i := range buffer {
+		value := uint8(i % 16 * 17)
+		buffer[i] = color.RGBA{value, value, value, 255}
+	}
+	return buffer
+}
+
+// checkPixels compares the device contents against the expected pattern.
+func checkPixels(t *testing.T, device *testDevice, expected []string) {
+	t.Helper()
+	actual := device.pixels()
+	for y := range expected {
+		if actual[y] != expected[y] {
+			t.Errorf("row %d: expected %s, got %s", y, expected[y], actual[y])
+		}
+	}
+}
+
+// Feed a gradient to a dithering screen, and check that it results in the
+// expected pattern in the page format.
+func TestDither(t *testing.T) {
+	device := &testDevice{width: 16, height: 8}
+	screen := NewScreen(device)
+	screen.SetMode(Dither)
+	screen.FillRectangleWithBuffer(0, 0, 16, 8, gradient(8))
+	screen.Display()
+	if device.displays != 1 {
+		t.Errorf("expected 1 display, got %d", device.displays)
+	}
+	checkPixels(t, device, []string{
+		"....#.#.########",
+		".....#.#.#.#####",
+		"..#.#.#.#.######",
+		".......#.#.#.###",
+		"....#.#.########",
+		".....#.#.#.#####",
+		"..#.#.#.#.######",
+		".......#.#.#.###",
+	})
+
+	// Check the page format directly: each byte is a column of 8 pixels.
+	expected := []byte{0x00, 0x00, 0x44, 0x00, 0x55, 0x22, 0x55, 0xaa, 0x55, 0xbb, 0x55, 0xff, 0x77, 0xff, 0xff, 0xff}
+	for i, b := range expected {
+		if device.buffer[i] != b {
+			t.Errorf("byte %d: expected %#02x, got %#02x", i, b, device.buffer[i])
+		}
+	}
+}
+
+// Check that the threshold is applied, and that updates are clipped to the
+// screen and span multiple pages.
+func TestThreshold(t *testing.T) {
+	device := &testDevice{width: 16, height: 16}
+	screen := NewScreen(device)
+	screen.FillRectangle(0, 0, 16, 16, color.RGBA{0, 0, 0, 255})
+	screen.FillRectangleWithBuffer(0, 6, 16, 3, gradient(3))
+	screen.SetThreshold(200)
+	screen.FillRectangleWithBuffer(0, 10, 16, 1, gradient(1))
+	screen.FillRectangle(-2, 13, 5, 10, color.RGBA{255, 255, 255, 255})
+	screen.FillRectangle(12, 13, 2, 1, color.RGBA{200, 150, 100, 255})
+	screen.Display()
+	checkPixels(t, device, []string{
+		"................",
+		"................",
+		"................",
+		"................",
+		"................",
+		"................",
+		"........########",
+		"........########",
+		"........########",
+		"................",
+		"............####",
+		"................",
+		"................",
+		"###.............",
+		"###.............",
+		"###.............",
+	})
+
+	// Nothing changed, so the buffer must not be sent again.
+	device.buffer = nil
+	screen.Display()
+	if device.buffer != nil {
+		t.Error("unchanged buffer was sent again")
+	}
+
+	if err := screen.FillRectangleWithBuffer(0, 0, 4, 4, gradient(2)); err != ErrBufferSizeMismatch {
+		t.Errorf("expected ErrBufferSizeMismatch, got %v", err)
+	}
+}
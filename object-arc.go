@@ -0,0 +1,160 @@
+package tilegraphics
+
+import (
+	"image/color"
+	"math"
+)
+
+// Arc is a part of a ring (an annular sector) with anti-aliased edges, useful
+// for gauges and progress indicators. With a thickness equal to the radius it
+// is a pie slice. It supports transparency in the fill color.
+//
+// Angles are in degrees, where 0° points to the right and angles increase
+// clockwise (so 90° points down). The arc is drawn clockwise from the start
+// angle to the end angle.
+type Arc struct {
+	parent     *Layer
+	cx, cy     int16
+	radius     int16
+	thickness  int16
+	start, end float32
+	color      color.RGBA
+}
+
+// boundingBox returns the bounding box of the full circle this arc is a part
+// of.
+func (a *Arc) boundingBox() (x1, y1, x2, y2 int16) {
+	return a.cx - a.radius, a.cy - a.radius, a.cx + a.radius + 1, a.cy + a.radius + 1
+}
+
+// contains returns whether the given point lies within the bounding box of this
+// arc.
+func (a *Arc) contains(x, y int16) bool {
+	return boundingBoxContains(a, x, y)
+}
+
+// parentLayer returns the layer this object is part of.
+func (a *Arc) parentLayer() *Layer {
+	return a.parent
+}
+
+// setParent changes the layer this object is part of, without invalidating
+// anything.
+func (a *Arc) setParent(parent *Layer) {
+	a.parent = parent
+}
+
+// Move sets the new center and radius of this arc.
+func (a *Arc) Move(cx, cy, radius int16) {
+	a.invalidate()
+	a.cx = cx
+	a.cy = cy
+	a.radius = radius
+	a.invalidate()
+}
+
+// SetAngles changes the start and end angle (in degrees) of this arc. This can
+// be used to animate a progress ring, for example.
+func (a *Arc) SetAngles(start, end float32) {
+	a.start = start
+	a.end = end
+	a.invalidate()
+}
+
+// Remove removes this arc from its parent layer. It must not be used anymore
+// afterwards.
+func (a *Arc) Remove() {
+	a.parent.Remove(a)
+}
+
+// invalidate marks the tiles under the bounding box of this arc as needing to
+// be re-painted.
+func (a *Arc) invalidate() {
+	x1, y1, x2, y2 := a.boundingBox()
+	r := Rectangle{parent: a.parent}
+	r.invalidate(x1, y1, x2, y2)
+}
+
+// paint draws the arc to the given tile at coordinates tileX and tileY.
+func (a *Arc) paint(t *tile, tileX, tileY int16) {
+	sweep := a.end - a.start
+	if sweep <= 0 || a.radius <= 0 || a.thickness <= 0 {
+		return
+	}
+	x1, y1, x2, y2 := a.boundingBox()
+	x1 -= tileX
+	y1 -= tileY
+	x2 -= tileX
+	y2 -= tileY
+	if x1 < 0 {
+		x1 = 0
+	}
+	if y1 < 0 {
+		y1 = 0
+	}
+	if x2 > t.width {
+		x2 = t.width
+	}
+	if y2 > t.height {
+		y2 = t.height
+	}
+
+	// Directions of the start and end edge of the arc.
+	startSin, startCos := math.Sincos(float64(a.start) * math.Pi / 180)
+	endSin, endCos := math.Sincos(float64(a.end) * math.Pi / 180)
+
+	outer := float32(a.radius)
+	inner := float32(a.radius - a.thickness)
+	for y := y1; y < y2; y++ {
+		dy := float32(y + tileY - a.cy)
+		for x := x1; x < x2; x++ {
+			dx := float32(x + tileX - a.cx)
+
+			// Determine how far (in pixels) this pixel lies within the arc by
+			// taking the distance to the closest edge. Negative means the pixel
+			// lies outside of the arc.
+			dist := float32(math.Sqrt(float64(dx*dx + dy*dy)))
+			inside := outer - dist
+			if inner > 0 && dist-inner < inside {
+				inside = dist - inner
+			}
+			if sweep < 360 {
+				// Signed distance to the lines through the start and end edge,
+				// positive on the side of the arc.
+				fromStart := float32(startCos)*dy - float32(startSin)*dx
+				fromEnd := float32(endSin)*dx - float32(endCos)*dy
+				angular := fromStart
+				if sweep <= 180 {
+					// The arc is the intersection of both half planes.
+					if fromEnd < angular {
+						angular = fromEnd
+					}
+				} else {
+					// The arc is the union of both half planes.
+					if fromEnd > angular {
+						angular = fromEnd
+					}
+				}
+				if angular < inside {
+					inside = angular
+				}
+			}
+
+			coverage := int32((inside + 0.5) * 256)
+			if coverage <= 0 {
+				continue
+			}
+			index := y*t.stride + x
+			if coverage >= 255 {
+				if a.color.A == 255 {
+					// Fast path, directly painting the color into the tile.
+					t.pixels[index] = a.color
+				} else {
+					t.pixels[index] = Blend(t.pixels[index], a.color)
+				}
+				continue
+			}
+			t.pixels[index] = Blend(t.pixels[index], ApplyAlpha(a.color, uint8(coverage)))
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package tilegraphics
+
+import "image/color"
+
+// BeveledRectangle is a filled rectangle with a two-tone border, with lighter
+// top and left edges and darker bottom and right edges. This gives it a raised
+// look, like a classic button. Swapping the light and dark colors makes it look
+// pressed instead. All colors support transparency.
+type BeveledRectangle struct {
+	parent            *Layer
+	x1, y1, x2, y2    int16
+	bevel             int16
+	fill, light, dark color.RGBA
+}
+
+// boundingBox returns the exact bounding box of the rectangle.
+func (r *BeveledRectangle) boundingBox() (x1, y1, x2, y2 int16) {
+	return r.x1, r.y1, r.x2, r.y2
+}
+
+// contains returns whether the given point lies within this rectangle.
+func (r *BeveledRectangle) contains(x, y int16) bool {
+	return boundingBoxContains(r, x, y)
+}
+
+// parentLayer returns the layer this object is part of.
+func (r *BeveledRectangle) parentLayer() *Layer {
+	return r.parent
+}
+
+// setParent changes the layer this object is part of, without invalidating
+// anything.
+func (r *BeveledRectangle) setParent(parent *Layer) {
+	r.parent = parent
+}
+
+// Move sets the new position and size of this rectangle.
+func (r *BeveledRectangle) Move(x, y, width, height int16) {
+	r.invalidate()
+	r.x1 = x
+	r.y1 = y
+	r.x2 = x + width
+	r.y2 = y + height
+	r.invalidate()
+}
+
+// SetColors changes the fill color and the colors of the light and dark edges.
+func (r *BeveledRectangle) SetColors(fill, light, dark color.RGBA) {
+	if fill == r.fill && light == r.light && dark == r.dark {
+		return
+	}
+	r.fill = fill
+	r.light = light
+	r.dark = dark
+	r.invalidate()
+}
+
+// Remove removes this rectangle from its parent layer. It must not be used
+// anymore afterwards.
+func (r *BeveledRectangle) Remove() {
+	r.parent.Remove(r)
+}
+
+// invalidate marks the tiles under this rectangle as needing to be re-painted.
+func (r *BeveledRectangle) invalidate() {
+	rect := Rectangle{parent: r.parent}
+	rect.invalidate(r.x1, r.y1, r.x2, r.y2)
+}
+
+// colorAt returns the color of the pixel at the given coordinates, relative to
+// the top left corner of the rectangle. The corners where a light and a dark
+// edge meet are split diagonally.
+func (r *BeveledRectangle) colorAt(x, y int16) color.RGBA {
+	lightDistance := x
+	if y < lightDistance {
+		lightDistance = y
+	}
+	darkDistance := r.x2 - r.x1 - 1 - x
+	if bottom := r.y2 - r.y1 - 1 - y; bottom < darkDistance {
+		darkDistance = bottom
+	}
+	if lightDistance >= r.bevel && darkDistance >= r.bevel {
+		return r.fill
+	}
+	if lightDistance < darkDistance {
+		return r.light
+	}
+	return r.dark
+}
+
+// paint draws the rectangle to the given tile at coordinates tileX and tileY.
+func (r *BeveledRectangle) paint(t *tile, tileX, tileY int16) {
+	x1 := r.x1 - tileX
+	y1 := r.y1 - tileY
+	x2 := r.x2 - tileX
+	y2 := r.y2 - tileY
+	if x1 < 0 {
+		x1 = 0
+	}
+	if y1 < 0 {
+		y1 = 0
+	}
+	if x2 > t.width {
+		x2 = t.width
+	}
+	if y2 > t.height {
+		y2 = t.height
+	}
+	for y := y1; y < y2; y++ {
+		for x := x1; x < x2; x++ {
+			c := r.colorAt(x+tileX-r.x1, y+tileY-r.y1)
+			if c.A == 255 {
+				t.pixels[y*t.stride+x] = c
+			} else {
+				t.pixels[y*t.stride+x] = Blend(t.pixels[y*t.stride+x], c)
+			}
+		}
+	}
+}
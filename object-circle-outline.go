@@ -0,0 +1,140 @@
+package tilegraphics
+
+import "image/color"
+
+// CircleOutline is the outline of a circle (a ring) with anti-aliased inner and
+// outer edges, drawn with a given thickness on the inside of the circle. It
+// supports transparency in the color.
+type CircleOutline struct {
+	parent    *Layer
+	cx, cy    int16
+	radius    int16
+	thickness int16
+	color     color.RGBA
+}
+
+// boundingBox returns the bounding box of the outer circle.
+func (c *CircleOutline) boundingBox() (x1, y1, x2, y2 int16) {
+	return c.cx - c.radius, c.cy - c.radius, c.cx + c.radius + 1, c.cy + c.radius + 1
+}
+
+// contains returns whether the given point lies on the ring, including
+// anti-aliased edge pixels.
+func (c *CircleOutline) contains(x, y int16) bool {
+	dx := int32(x - c.cx)
+	dy := int32(y - c.cy)
+	r := int32(c.radius)
+	inner := r - int32(c.thickness)
+	dist2 := dx*dx + dy*dy
+	return dist2 <= r*r+r && (inner <= 0 || dist2 > inner*inner-inner)
+}
+
+// parentLayer returns the layer this object is part of.
+func (c *CircleOutline) parentLayer() *Layer {
+	return c.parent
+}
+
+// setParent changes the layer this object is part of, without invalidating
+// anything.
+func (c *CircleOutline) setParent(parent *Layer) {
+	c.parent = parent
+}
+
+// Move sets the new center and outer radius of this ring. The thickness stays
+// the same.
+func (c *CircleOutline) Move(cx, cy, radius int16) {
+	c.invalidate()
+	c.cx = cx
+	c.cy = cy
+	c.radius = radius
+	c.invalidate()
+}
+
+// Remove removes this ring from its parent layer. It must not be used anymore
+// afterwards.
+func (c *CircleOutline) Remove() {
+	c.parent.Remove(c)
+}
+
+// invalidate marks the tiles under the bounding box of this ring as needing to
+// be re-painted.
+func (c *CircleOutline) invalidate() {
+	x1, y1, x2, y2 := c.boundingBox()
+	r := Rectangle{parent: c.parent}
+	r.invalidate(x1, y1, x2, y2)
+}
+
+// paint draws the ring to the given tile at coordinates tileX and tileY.
+func (c *CircleOutline) paint(t *tile, tileX, tileY int16) {
+	x1, y1, x2, y2 := c.boundingBox()
+	x1 -= tileX
+	y1 -= tileY
+	x2 -= tileX
+	y2 -= tileY
+	if x1 < 0 {
+		x1 = 0
+	}
+	if y1 < 0 {
+		y1 = 0
+	}
+	if x2 > t.width {
+		x2 = t.width
+	}
+	if y2 > t.height {
+		y2 = t.height
+	}
+
+	// The outer edge is the same as the edge of a Circle. The inner edge lies
+	// at the inner radius, which is the same as the edge of a Circle with
+	// that radius but with the coverage reversed. A ring that is at least as
+	// thick as its radius is a filled circle.
+	r := uint32(c.radius)
+	outerInnerDist2 := r*r - r
+	outerOuterDist2 := r*r + r
+	inner := int32(c.radius) - int32(c.thickness)
+	filled := inner <= 0
+	if filled {
+		inner = 0
+	}
+	ri := uint32(inner)
+	innerInnerDist2 := ri*ri - ri
+	innerOuterDist2 := ri*ri + ri
+	for y := y1; y < y2; y++ {
+		dy := int32(y + tileY - c.cy)
+		for x := x1; x < x2; x++ {
+			dx := int32(x + tileX - c.cx)
+			dist2 := uint32(dx*dx + dy*dy)
+			if dist2 > outerOuterDist2 || (!filled && dist2 <= innerInnerDist2) {
+				// Outside of the ring.
+				continue
+			}
+			if dist2 <= outerInnerDist2 && (filled || dist2 > innerOuterDist2) {
+				if c.color.A == 255 {
+					// Fast path, directly painting the color into the tile.
+					t.pixels[y*t.stride+x] = c.color
+				} else {
+					t.pixels[y*t.stride+x] = Blend(t.pixels[y*t.stride+x], c.color)
+				}
+				continue
+			}
+
+			// Edge pixel: use the coverage of the outer or inner edge,
+			// whichever is smaller. Very thin rings may have both edges in the
+			// same pixel.
+			dist := int32(sqrtQ8(dist2))
+			coverage := int32(r<<8) + 128 - dist
+			if !filled {
+				if innerCoverage := dist - int32(ri<<8) + 128; innerCoverage < coverage {
+					coverage = innerCoverage
+				}
+			}
+			if coverage <= 0 {
+				continue
+			}
+			if coverage > 255 {
+				coverage = 255
+			}
+			t.pixels[y*t.stride+x] = Blend(t.pixels[y*t.stride+x], ApplyAlpha(c.color, uint8(coverage)))
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package tilegraphics
+
+import "image/color"
+
+// Circle is a filled circle with anti-aliased edges. It supports transparency
+// in the fill color.
+type Circle struct {
+	parent *Layer
+	cx, cy int16
+	radius int16
+	color  color.RGBA
+}
+
+// boundingBox returns the bounding box of this circle.
+func (c *Circle) boundingBox() (x1, y1, x2, y2 int16) {
+	return c.cx - c.radius, c.cy - c.radius, c.cx + c.radius + 1, c.cy + c.radius + 1
+}
+
+// contains returns whether the given point lies within the painted area of this
+// circle, including anti-aliased edge pixels.
+func (c *Circle) contains(x, y int16) bool {
+	dx := int32(x - c.cx)
+	dy := int32(y - c.cy)
+	r := int32(c.radius)
+	return dx*dx+dy*dy <= r*r+r
+}
+
+// parentLayer returns the layer this object is part of.
+func (c *Circle) parentLayer() *Layer {
+	return c.parent
+}
+
+// setParent changes the layer this object is part of, without invalidating
+// anything.
+func (c *Circle) setParent(parent *Layer) {
+	c.parent = parent
+}
+
+// Move sets the new center and radius of this circle.
+func (c *Circle) Move(cx, cy, radius int16) {
+	c.invalidate()
+	c.cx = cx
+	c.cy = cy
+	c.radius = radius
+	c.invalidate()
+}
+
+// Remove removes this circle from its parent layer. It must not be used
+// anymore afterwards.
+func (c *Circle) Remove() {
+	c.parent.Remove(c)
+}
+
+// invalidate marks the tiles under the bounding box of this circle as needing
+// to be re-painted.
+func (c *Circle) invalidate() {
+	x1, y1, x2, y2 := c.boundingBox()
+	r := Rectangle{parent: c.parent}
+	r.invalidate(x1, y1, x2, y2)
+}
+
+// paint draws the circle to the given tile at coordinates tileX and tileY.
+func (c *Circle) paint(t *tile, tileX, tileY int16) {
+	x1, y1, x2, y2 := c.boundingBox()
+	x1 -= tileX
+	y1 -= tileY
+	x2 -= tileX
+	y2 -= tileY
+	if x1 < 0 {
+		x1 = 0
+	}
+	if y1 < 0 {
+		y1 = 0
+	}
+	if x2 > t.width {
+		x2 = t.width
+	}
+	if y2 > t.height {
+		y2 = t.height
+	}
+
+	// A pixel is fully covered when its center lies at least half a pixel
+	// inside the edge, and not covered at all when it lies at least half a
+	// pixel outside of it. Squaring these distances gives the bounds below,
+	// which avoid a square root for all but the edge pixels.
+	r := uint32(c.radius)
+	innerDist2 := r*r - r
+	outerDist2 := r*r + r
+	for y := y1; y < y2; y++ {
+		dy := int32(y + tileY - c.cy)
+		for x := x1; x < x2; x++ {
+			dx := int32(x + tileX - c.cx)
+			dist2 := uint32(dx*dx + dy*dy)
+			if dist2 > outerDist2 {
+				continue
+			}
+			if dist2 <= innerDist2 {
+				if c.color.A == 255 {
+					// Fast path, directly painting the color into the tile.
+					t.pixels[y*t.stride+x] = c.color
+				} else {
+					t.pixels[y*t.stride+x] = Blend(t.pixels[y*t.stride+x], c.color)
+				}
+				continue
+			}
+			// Edge pixel: the coverage is how far the pixel center lies
+			// within (radius + 0.5), as a 8-bit fraction.
+			coverage := int32(r<<8) + 128 - int32(sqrtQ8(dist2))
+			if coverage <= 0 {
+				continue
+			}
+			if coverage > 255 {
+				coverage = 255
+			}
+			t.pixels[y*t.stride+x] = Blend(t.pixels[y*t.stride+x], ApplyAlpha(c.color, uint8(coverage)))
+		}
+	}
+}
+
+// sqrtQ8 returns the square root of x as a fixed-point number with 8
+// fractional bits, rounded down.
+func sqrtQ8(x uint32) uint32 {
+	// Simple bit-by-bit integer square root of x<<16. This is only used for
+	// edge pixels, so it doesn't need to be particularly fast.
+	n := uint64(x) << 16
+	result := uint64(0)
+	bit := uint64(1) << 62
+	for bit > n {
+		bit >>= 2
+	}
+	for bit != 0 {
+		if n >= result+bit {
+			n -= result + bit
+			result = result>>1 + bit
+		} else {
+			result >>= 1
+		}
+		bit >>= 2
+	}
+	return uint32(result)
+}
@@ -0,0 +1,126 @@
+package tilegraphics
+
+import "image/color"
+
+// Object is a custom object that can be implemented outside of this package,
+// for objects that are too specific to be included here (like a QR code or a
+// waveform). Add it to a layer using Layer.Add.
+//
+// All coordinates are relative to the layer the object is added to, in the
+// same way as for the built-in objects.
+type Object interface {
+	// BoundingBox returns the area that the object draws in. The x2 and y2
+	// values are the coordinates that lie just outside of the bounding box,
+	// so (2, 2, 3, 4) covers just two pixels. It should be as small as
+	// possible for performance, but drawing outside of it leads to unexpected
+	// results.
+	BoundingBox() (x1, y1, x2, y2 int16)
+
+	// Paint draws the object on the given tile. The top left pixel of the
+	// tile is at coordinates (tileX, tileY) in the coordinate system of the
+	// object, so a point (x, y) of the object is pixel (x-tileX, y-tileY) of
+	// the tile. The tile may be of any size, and already contains everything
+	// that is drawn below the object. Paint is only called for tiles that
+	// overlap the bounding box.
+	Paint(t Tile, tileX, tileY int16)
+}
+
+// Tile is a rectangular area of pixels that is painted at once, as passed to
+// Object.Paint. It is only valid during the call to Paint.
+type Tile struct {
+	t *tile
+}
+
+// Size returns the width and height of the tile in pixels.
+func (t Tile) Size() (width, height int16) {
+	return t.t.width, t.t.height
+}
+
+// At returns the current color of the given pixel, or transparent black if it
+// lies outside of the tile.
+func (t Tile) At(x, y int16) color.RGBA {
+	if x < 0 || y < 0 || x >= t.t.width || y >= t.t.height {
+		return color.RGBA{}
+	}
+	return t.t.pixels[y*t.t.stride+x]
+}
+
+// Set replaces the color of the given pixel. Coordinates outside of the tile
+// are ignored.
+func (t Tile) Set(x, y int16, c color.RGBA) {
+	if x < 0 || y < 0 || x >= t.t.width || y >= t.t.height {
+		return
+	}
+	t.t.pixels[y*t.t.stride+x] = c
+}
+
+// Blend blends the given (possibly transparent) color over the given pixel,
+// see the Blend function. Coordinates outside of the tile are ignored.
+func (t Tile) Blend(x, y int16, c color.RGBA) {
+	if x < 0 || y < 0 || x >= t.t.width || y >= t.t.height {
+		return
+	}
+	t.t.pixels[y*t.t.stride+x] = Blend(t.t.pixels[y*t.t.stride+x], c)
+}
+
+// Custom is a custom object that has been added to a layer, see Layer.Add.
+type Custom struct {
+	parent         *Layer
+	obj            Object
+	x1, y1, x2, y2 int16 // bounding box at the time of the last update
+}
+
+// boundingBox returns the bounding box of the custom object as it was during
+// the last call to Update.
+func (c *Custom) boundingBox() (x1, y1, x2, y2 int16) {
+	return c.x1, c.y1, c.x2, c.y2
+}
+
+// contains returns whether the given point lies within the bounding box of this
+// object.
+func (c *Custom) contains(x, y int16) bool {
+	return boundingBoxContains(c, x, y)
+}
+
+// parentLayer returns the layer this object is part of.
+func (c *Custom) parentLayer() *Layer {
+	return c.parent
+}
+
+// setParent changes the layer this object is part of, without invalidating
+// anything.
+func (c *Custom) setParent(parent *Layer) {
+	c.parent = parent
+}
+
+// Object returns the custom object as passed to Layer.Add.
+func (c *Custom) Object() Object {
+	return c.obj
+}
+
+// Update must be called after the custom object changed its appearance or its
+// bounding box. Both the previous and the new area will be redrawn.
+func (c *Custom) Update() {
+	c.invalidate()
+	c.x1, c.y1, c.x2, c.y2 = c.obj.BoundingBox()
+	c.invalidate()
+}
+
+// Remove removes this object from its parent layer. It must not be used
+// anymore afterwards.
+func (c *Custom) Remove() {
+	c.parent.Remove(c)
+}
+
+// invalidate marks the tiles under the bounding box of this object as needing
+// to be re-painted.
+func (c *Custom) invalidate() {
+	r := Rectangle{parent: c.parent}
+	r.invalidate(c.x1, c.y1, c.x2, c.y2)
+}
+
+// paint draws the custom object to the given tile at coordinates tileX and
+// tileY.
+func (c *Custom) paint(t *tile, tileX, tileY int16) {
+	c.obj.Paint(Tile{t}, tileX, tileY)
+}
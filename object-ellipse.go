@@ -0,0 +1,151 @@
+package tilegraphics
+
+import (
+	"image/color"
+	"math"
+)
+
+// Ellipse is a filled, axis-aligned ellipse with anti-aliased edges. It
+// supports transparency in the fill color.
+type Ellipse struct {
+	parent *Layer
+	cx, cy int16
+	rx, ry int16
+	color  color.RGBA
+}
+
+// boundingBox returns the bounding box of this ellipse.
+func (e *Ellipse) boundingBox() (x1, y1, x2, y2 int16) {
+	return e.cx - e.rx, e.cy - e.ry, e.cx + e.rx + 1, e.cy + e.ry + 1
+}
+
+// contains returns whether the given point lies within the bounding box of this
+// ellipse.
+func (e *Ellipse) contains(x, y int16) bool {
+	return boundingBoxContains(e, x, y)
+}
+
+// parentLayer returns the layer this object is part of.
+func (e *Ellipse) parentLayer() *Layer {
+	return e.parent
+}
+
+// setParent changes the layer this object is part of, without invalidating
+// anything.
+func (e *Ellipse) setParent(parent *Layer) {
+	e.parent = parent
+}
+
+// Move sets the new center and radii of this ellipse.
+func (e *Ellipse) Move(cx, cy, rx, ry int16) {
+	e.invalidate()
+	e.cx = cx
+	e.cy = cy
+	e.rx = rx
+	e.ry = ry
+	e.invalidate()
+}
+
+// Remove removes this ellipse from its parent layer. It must not be used
+// anymore afterwards.
+func (e *Ellipse) Remove() {
+	e.parent.Remove(e)
+}
+
+// invalidate marks the tiles under the bounding box of this ellipse as needing
+// to be re-painted.
+func (e *Ellipse) invalidate() {
+	x1, y1, x2, y2 := e.boundingBox()
+	r := Rectangle{parent: e.parent}
+	r.invalidate(x1, y1, x2, y2)
+}
+
+// paint draws the ellipse to the given tile at coordinates tileX and tileY.
+func (e *Ellipse) paint(t *tile, tileX, tileY int16) {
+	if e.rx <= 0 || e.ry <= 0 {
+		// Degenerate ellipse: there is nothing to draw.
+		return
+	}
+
+	x1, y1, x2, y2 := e.boundingBox()
+	x1 -= tileX
+	y1 -= tileY
+	x2 -= tileX
+	y2 -= tileY
+	if x1 < 0 {
+		x1 = 0
+	}
+	if y1 < 0 {
+		y1 = 0
+	}
+	if x2 > t.width {
+		x2 = t.width
+	}
+	if y2 > t.height {
+		y2 = t.height
+	}
+
+	rx := int64(e.rx)
+	ry := int64(e.ry)
+	for y := y1; y < y2; y++ {
+		dy := int64(y + tileY - e.cy)
+		for x := x1; x < x2; x++ {
+			dx := int64(x + tileX - e.cx)
+
+			// Quickly determine whether the pixel lies well within the ellipse
+			// or well outside of it, using the ellipse equation
+			// x²/rx² + y²/ry² <= 1 for ellipses one pixel smaller and bigger.
+			// Only pixels on the edge need the (relatively slow) anti-aliasing
+			// calculation.
+			var coverage int32
+			if inEllipse(dx, dy, rx-1, ry-1) {
+				coverage = 255
+			} else if !inEllipse(dx, dy, rx+1, ry+1) {
+				continue
+			} else {
+				coverage = ellipseCoverage(float32(dx), float32(dy), float32(rx), float32(ry))
+				if coverage <= 0 {
+					continue
+				}
+			}
+
+			index := y*t.stride + x
+			if coverage >= 255 {
+				if e.color.A == 255 {
+					// Fast path, directly painting the color into the tile.
+					t.pixels[index] = e.color
+				} else {
+					t.pixels[index] = Blend(t.pixels[index], e.color)
+				}
+				continue
+			}
+			t.pixels[index] = Blend(t.pixels[index], ApplyAlpha(e.color, uint8(coverage)))
+		}
+	}
+}
+
+// inEllipse returns whether the point (x, y) lies within the ellipse with the
+// given radii centered around (0, 0).
+func inEllipse(x, y, rx, ry int64) bool {
+	if rx <= 0 || ry <= 0 {
+		return false
+	}
+	return x*x*ry*ry+y*y*rx*rx <= rx*rx*ry*ry
+}
+
+// ellipseCoverage returns how much of the pixel at (x, y) is covered by the
+// ellipse centered around (0, 0), as a value from 0 to 255 (it may lie outside
+// of that range). The distance to the edge of the ellipse is approximated
+// using the value of the ellipse equation divided by the length of its
+// gradient.
+func ellipseCoverage(x, y, rx, ry float32) int32 {
+	f := x*x/(rx*rx) + y*y/(ry*ry) - 1
+	gradX := 2 * x / (rx * rx)
+	gradY := 2 * y / (ry * ry)
+	gradient := float32(math.Sqrt(float64(gradX*gradX + gradY*gradY)))
+	if gradient == 0 {
+		return 255
+	}
+	distance := -f / gradient
+	return int32((distance + 0.5) * 256)
+}
@@ -0,0 +1,110 @@
+package tilegraphics
+
+import "image/color"
+
+// GradientRectangle is a rectangle filled with a linear gradient between two
+// colors, either from top to bottom or from left to right. Both colors may be
+// transparent.
+type GradientRectangle struct {
+	parent         *Layer
+	x1, y1, x2, y2 int16
+	start, end     color.RGBA
+	vertical       bool
+}
+
+// boundingBox returns the exact bounding box of the gradient.
+func (r *GradientRectangle) boundingBox() (x1, y1, x2, y2 int16) {
+	return r.x1, r.y1, r.x2, r.y2
+}
+
+// contains returns whether the given point lies within the bounding box of this
+// rectangle.
+func (r *GradientRectangle) contains(x, y int16) bool {
+	return boundingBoxContains(r, x, y)
+}
+
+// parentLayer returns the layer this object is part of.
+func (r *GradientRectangle) parentLayer() *Layer {
+	return r.parent
+}
+
+// setParent changes the layer this object is part of, without invalidating
+// anything.
+func (r *GradientRectangle) setParent(parent *Layer) {
+	r.parent = parent
+}
+
+// Move sets the new position and size of this gradient. The gradient is
+// stretched to fill the new size.
+func (r *GradientRectangle) Move(x, y, width, height int16) {
+	r.invalidate()
+	r.x1 = x
+	r.y1 = y
+	r.x2 = x + width
+	r.y2 = y + height
+	r.invalidate()
+}
+
+// Remove removes this gradient from its parent layer. It must not be used
+// anymore afterwards.
+func (r *GradientRectangle) Remove() {
+	r.parent.Remove(r)
+}
+
+// invalidate marks the tiles under this gradient as needing to be re-painted.
+func (r *GradientRectangle) invalidate() {
+	rect := Rectangle{parent: r.parent}
+	rect.invalidate(r.x1, r.y1, r.x2, r.y2)
+}
+
+// colorAt returns the gradient color at the given x or y coordinate (depending
+// on the direction of the gradient).
+func (r *GradientRectangle) colorAt(pos int16) color.RGBA {
+	// Determine the start and the length of the gradient minus one, so that
+	// the first and last row (or column) get exactly the start and end color.
+	first, length := r.x1, r.x2-r.x1-1
+	if r.vertical {
+		first, length = r.y1, r.y2-r.y1-1
+	}
+	if length <= 0 {
+		return r.start
+	}
+	return Lerp(r.start, r.end, uint8(int32(pos-first)*255/int32(length)))
+}
+
+// paint draws the gradient to the given tile at coordinates tileX and tileY.
+func (r *GradientRectangle) paint(t *tile, tileX, tileY int16) {
+	x1 := r.x1 - tileX
+	y1 := r.y1 - tileY
+	x2 := r.x2 - tileX
+	y2 := r.y2 - tileY
+	if x1 < 0 {
+		x1 = 0
+	}
+	if y1 < 0 {
+		y1 = 0
+	}
+	if x2 > t.width {
+		x2 = t.width
+	}
+	if y2 > t.height {
+		y2 = t.height
+	}
+	for y := y1; y < y2; y++ {
+		var c color.RGBA
+		if r.vertical {
+			// The color is the same for the whole row.
+			c = r.colorAt(y + tileY)
+		}
+		for x := x1; x < x2; x++ {
+			if !r.vertical {
+				c = r.colorAt(x + tileX)
+			}
+			if c.A == 255 {
+				t.pixels[y*t.stride+x] = c
+			} else {
+				t.pixels[y*t.stride+x] = Blend(t.pixels[y*t.stride+x], c)
+			}
+		}
+	}
+}
@@ -1,6 +1,9 @@
 package tilegraphics
 
-import "image/color"
+import (
+	"image"
+	"image/color"
+)
 
 // Layer contains other objects, but makes sure no containing objects will draw
 // outside of its boundaries. This object provides some encapsulation and
@@ -9,22 +12,380 @@ type Layer struct {
 	rect    Rectangle
 	engine  *Engine
 	parent  *Layer // may be nil for the root
-	objects []object
+	objects []Element
+	hidden  bool
+	opacity uint8 // opacity of the whole layer, 255 is fully opaque
+
+	// background is an optional image that is drawn instead of the flat
+	// background color.
+	background     image.Image
+	backgroundMode BackgroundMode
+
+	// cache is the composited contents of the whole layer (width*height
+	// pixels, in row major order) when the layer has been flattened, or nil
+	// otherwise. It is only up-to-date when cacheValid is set.
+	cache      []color.RGBA
+	cacheValid bool
+
+	// clip is the area of the layer (in layer coordinates) outside of which
+	// nothing is drawn, if clipped is set. See SetClipRect.
+	clip    Rectangle
+	clipped bool
+
+	// scrollX and scrollY are the coordinates of the objects in this layer
+	// that are drawn at the top left corner of the layer. See
+	// SetScrollOffset.
+	scrollX, scrollY int16
+
+	// wrap is set when objects that extend beyond an edge of the layer are
+	// also drawn at the opposite edge. See SetWrap.
+	wrap bool
 }
 
+// BackgroundMode determines how a background image is drawn when it is smaller
+// than the layer.
+type BackgroundMode uint8
+
+const (
+	// BackgroundClamp repeats the pixels at the edges of the image to fill the
+	// rest of the layer.
+	BackgroundClamp BackgroundMode = iota
+
+	// BackgroundTile repeats the whole image in both directions.
+	BackgroundTile
+)
+
 // boundingBox returns the exact bounding box of this layer.
 func (l *Layer) boundingBox() (x1, y1, x2, y2 int16) {
 	return l.rect.boundingBox()
 }
 
-// SetBackgroundColor updates the background color of this layer.
+// contains returns whether the given point lies within the visible part of
+// this layer, if it is visible.
+func (l *Layer) contains(x, y int16) bool {
+	if l.hidden || l.opacity == 0 {
+		return false
+	}
+	x1, y1, x2, y2 := l.clipBounds()
+	x -= l.rect.x1
+	y -= l.rect.y1
+	return x >= x1 && y >= y1 && x < x2 && y < y2
+}
+
+// parentLayer returns the layer that contains this layer, or nil for the root
+// layer.
+func (l *Layer) parentLayer() *Layer {
+	return l.parent
+}
+
+// setParent changes the layer that contains this layer, without invalidating
+// anything. The objects inside this layer are left untouched.
+func (l *Layer) setParent(parent *Layer) {
+	l.parent = parent
+}
+
+// clipBounds returns the area of the layer, in layer coordinates, that is
+// painted. This is the whole layer, unless it is further restricted using
+// SetClipRect.
+func (l *Layer) clipBounds() (x1, y1, x2, y2 int16) {
+	x1, y1, x2, y2 = 0, 0, l.rect.x2-l.rect.x1, l.rect.y2-l.rect.y1
+	if l.clipped {
+		if l.clip.x1 > x1 {
+			x1 = l.clip.x1
+		}
+		if l.clip.y1 > y1 {
+			y1 = l.clip.y1
+		}
+		if l.clip.x2 < x2 {
+			x2 = l.clip.x2
+		}
+		if l.clip.y2 < y2 {
+			y2 = l.clip.y2
+		}
+	}
+	return
+}
+
+// toParent converts the given area in this layer to coordinates in the parent
+// layer, taking the scroll offset into account. Layers never draw outside of
+// their bounds, so the area is clipped to the layer (and its clip rectangle).
+func (l *Layer) toParent(x1, y1, x2, y2 int16) (int16, int16, int16, int16) {
+	// Objects in a scrolled layer are drawn at an offset.
+	x1 -= l.scrollX
+	y1 -= l.scrollY
+	x2 -= l.scrollX
+	y2 -= l.scrollY
+	clipX1, clipY1, clipX2, clipY2 := l.clipBounds()
+	if x1 < clipX1 {
+		x1 = clipX1
+	}
+	if y1 < clipY1 {
+		y1 = clipY1
+	}
+	if x2 > clipX2 {
+		x2 = clipX2
+	}
+	if y2 > clipY2 {
+		y2 = clipY2
+	}
+	return x1 + l.rect.x1, y1 + l.rect.y1, x2 + l.rect.x1, y2 + l.rect.y1
+}
+
+// ObjectAt returns the topmost object in this layer (or in a layer inside it)
+// at the given screen coordinates, or nil if there is no object at that point.
+// Layers are returned when the point lies on their background and the
+// background is not fully transparent.
+func (l *Layer) ObjectAt(x, y int16) Element {
+	// Move the coordinates into the coordinate system of this layer.
+	layerX, layerY := l.rect.absolutePos(l.rect.x1, l.rect.y1)
+	return l.objectAt(x-layerX+l.scrollX, y-layerY+l.scrollY)
+}
+
+// ChildCount returns the number of objects directly in this layer, including
+// layers but not the objects inside those layers.
+func (l *Layer) ChildCount() int {
+	return len(l.objects)
+}
+
+// Children returns the objects directly in this layer in stacking order, from
+// the bottom to the top. The returned slice is a copy, so changing it doesn't
+// change the layer. Use a type switch to find out what kind of object each one
+// is, and BoundingBox to find out where it is drawn. This is meant for
+// debugging and inspection tools.
+func (l *Layer) Children() []Element {
+	return append([]Element(nil), l.objects...)
+}
+
+// objectAt returns the topmost object at the given coordinates, which are
+// relative to this layer. In a wrapping layer, objects are also found at the
+// opposite edges, where they are drawn as well.
+func (l *Layer) objectAt(x, y int16) Element {
+	offsetsX, offsetsY, n := l.wrapOffsets()
+	for i := len(l.objects) - 1; i >= 0; i-- {
+		for _, dy := range offsetsY[:n] {
+			for _, dx := range offsetsX[:n] {
+				if hit := hitObject(l.objects[i], x+dx, y+dy); hit != nil {
+					return hit
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// hitObject returns the object (or the topmost object inside it, for a layer)
+// if it is at the given coordinates relative to its parent layer, or nil if it
+// isn't.
+func hitObject(obj Element, x, y int16) Element {
+	if !obj.contains(x, y) {
+		return nil
+	}
+	if child, ok := obj.(*Layer); ok {
+		if hit := child.objectAt(x-child.rect.x1+child.scrollX, y-child.rect.y1+child.scrollY); hit != nil {
+			return hit
+		}
+		if child.rect.color.A == 0 {
+			// Transparent background, look at the objects below it.
+			return nil
+		}
+	}
+	return obj
+}
+
+// SetBackgroundColor updates the background color of this layer. A
+// semi-transparent background is blended with whatever is drawn below the
+// layer. The root layer has nothing below it, so its background is always made
+// fully opaque (as if it was blended over black).
 func (l *Layer) SetBackgroundColor(background color.RGBA) {
+	if l.parent == nil {
+		background.A = 255
+	}
 	l.rect.color = background
+	l.cacheValid = false
+	l.rect.invalidate(l.rect.x1, l.rect.y1, l.rect.x2, l.rect.y2)
+}
+
+// SetBackgroundImage sets an image to be drawn as the background of this layer,
+// with its top left corner at the top left of the layer. Transparent pixels in
+// the image are blended with the background color. Images smaller than the
+// layer are extended according to the given mode. Setting the image to nil
+// removes the background image again.
+//
+// The image is sampled while painting, so it must not be modified afterwards.
+// Use *image.RGBA or *image.NRGBA images for the best performance.
+func (l *Layer) SetBackgroundImage(img image.Image, mode BackgroundMode) {
+	l.background = img
+	l.backgroundMode = mode
+	l.cacheValid = false
+	l.rect.invalidate(l.rect.x1, l.rect.y1, l.rect.x2, l.rect.y2)
+}
+
+// backgroundAt returns the background color of this layer at the given
+// coordinates, relative to the layer.
+func (l *Layer) backgroundAt(x, y int) color.RGBA {
+	size := l.background.Bounds().Size()
+	background := l.backgroundColor()
+	if size.X <= 0 || size.Y <= 0 {
+		return background
+	}
+	switch l.backgroundMode {
+	case BackgroundTile:
+		x %= size.X
+		if x < 0 {
+			x += size.X
+		}
+		y %= size.Y
+		if y < 0 {
+			y += size.Y
+		}
+	default:
+		if x < 0 {
+			x = 0
+		} else if x >= size.X {
+			x = size.X - 1
+		}
+		if y < 0 {
+			y = 0
+		} else if y >= size.Y {
+			y = size.Y - 1
+		}
+	}
+	c := imageColorAt(l.background, x, y)
+	switch c.A {
+	case 255:
+		return c
+	case 0:
+		return background
+	default:
+		return Blend(background, c)
+	}
+}
+
+// backgroundColor returns the color that the background of this layer is
+// filled with. This is the background color, except for the root layer of an
+// engine with a transparent background (see Engine.SetTransparentBackground).
+func (l *Layer) backgroundColor() color.RGBA {
+	if l.parent == nil && l.engine.transparentBackground {
+		return color.RGBA{}
+	}
+	return l.rect.color
+}
+
+// SetVisible shows or hides this layer. A hidden layer (including all objects
+// in it) is not drawn at all, but is kept so it can be shown again cheaply. The
+// root layer cannot be hidden.
+func (l *Layer) SetVisible(visible bool) {
+	if l.parent == nil || l.hidden == !visible {
+		return
+	}
+	l.hidden = !visible
+	l.rect.invalidate(l.rect.x1, l.rect.y1, l.rect.x2, l.rect.y2)
+}
+
+// SetOpacity changes the opacity of the whole layer (including the background
+// and all objects in it) without changing any of the colors: the layer is
+// drawn as usual and then blended with the given opacity. An opacity of 0 means
+// the layer is not drawn at all. The root layer is always fully opaque.
+func (l *Layer) SetOpacity(opacity uint8) {
+	if l.parent == nil || l.opacity == opacity {
+		return
+	}
+	l.opacity = opacity
 	l.rect.invalidate(l.rect.x1, l.rect.y1, l.rect.x2, l.rect.y2)
 }
 
+// SetClipRect restricts drawing of this layer (including its background) to
+// the given area, relative to the top left corner of the layer. Outside of
+// this area, whatever is below the layer shows through. The clip rectangle is
+// also limited to the layer itself, and doesn't change when the layer is moved
+// or resized. By default, the whole layer is drawn.
+func (l *Layer) SetClipRect(x, y, width, height int16) {
+	l.clip = Rectangle{x1: x, y1: y, x2: x + width, y2: y + height}
+	l.clipped = true
+	l.rect.invalidate(l.rect.x1, l.rect.y1, l.rect.x2, l.rect.y2)
+}
+
+// ClearClipRect removes the clip rectangle set with SetClipRect, so that the
+// whole layer is drawn again.
+func (l *Layer) ClearClipRect() {
+	l.clipped = false
+	l.rect.invalidate(l.rect.x1, l.rect.y1, l.rect.x2, l.rect.y2)
+}
+
+// SetScrollOffset scrolls the contents of this layer, so that the objects in it
+// at the given coordinates are drawn at the top left corner of the layer. For
+// example, an offset of (0, 20) moves all objects 20 pixels up. The background
+// (color or image) doesn't scroll. The default offset is (0, 0).
+//
+// This is much faster than moving all objects in the layer, especially for
+// layers with many objects. Objects that are scrolled out of the layer (or its
+// clip rectangle) are not drawn.
+func (l *Layer) SetScrollOffset(dx, dy int16) {
+	if dx == l.scrollX && dy == l.scrollY {
+		return
+	}
+	l.scrollX = dx
+	l.scrollY = dy
+	l.cacheValid = false
+	l.rect.invalidate(l.rect.x1, l.rect.y1, l.rect.x2, l.rect.y2)
+}
+
+// SetWrap changes whether objects in this layer wrap around: when set, an
+// object that extends beyond an edge of the layer is also drawn shifted by the
+// width or height of the layer, so that it re-enters at the opposite edge. This
+// is useful for scrolling tickers, for example. Objects are only wrapped once,
+// so they should not extend more than the layer size beyond an edge. ObjectAt
+// also finds objects at the opposite edge.
+func (l *Layer) SetWrap(wrap bool) {
+	if l.wrap == wrap {
+		return
+	}
+	l.wrap = wrap
+	l.cacheValid = false
+	l.rect.invalidate(l.rect.x1, l.rect.y1, l.rect.x2, l.rect.y2)
+}
+
+// wrapOffsets returns the offsets on both axes at which objects in this layer
+// are drawn, and how many of them are used. Without wrapping, this is only the
+// offset 0. With wrapping, objects are also drawn one layer width or height
+// away in both directions.
+func (l *Layer) wrapOffsets() (dx, dy [3]int16, n int) {
+	if !l.wrap {
+		return dx, dy, 1
+	}
+	width := l.rect.x2 - l.rect.x1
+	height := l.rect.y2 - l.rect.y1
+	return [3]int16{0, -width, width}, [3]int16{0, -height, height}, 3
+}
+
+// Flatten caches the composited contents of this layer (its background and all
+// objects in it) in a buffer, so that they don't need to be painted again on
+// every redraw. Changing an object in the layer (or the layer itself) discards
+// the cache, after which the whole layer is painted into the cache again on the
+// next redraw. This is useful for layers that rarely change but are redrawn
+// often, for example because something moves over them.
+//
+// The cache needs width*height*4 bytes of memory. Use Unflatten to release it.
+func (l *Layer) Flatten() {
+	if l.cache == nil {
+		l.cache = make([]color.RGBA, int(l.rect.x2-l.rect.x1)*int(l.rect.y2-l.rect.y1))
+		l.cacheValid = false
+	}
+}
+
+// Unflatten releases the cache that was created by Flatten, so that the layer
+// will be painted as usual again.
+func (l *Layer) Unflatten() {
+	l.cache = nil
+	l.cacheValid = false
+}
+
 // Move sets the new position and size of this layer.
 func (l *Layer) Move(x, y, width, height int16) {
+	if width != l.rect.x2-l.rect.x1 || height != l.rect.y2-l.rect.y1 {
+		// The contents of a flattened layer only change when it is resized.
+		l.cacheValid = false
+	}
 	if x != l.rect.x1 || y != l.rect.y1 {
 		// The layer was moved, so all containing objects must be redrawn.
 		l.rect.invalidate(l.rect.x1, l.rect.y1, l.rect.x2, l.rect.y2)
@@ -35,9 +396,177 @@ func (l *Layer) Move(x, y, width, height int16) {
 	l.rect.Move(x, y, width, height)
 }
 
+// MoveBy moves this layer (including all objects in it) by the given offset,
+// keeping its size.
+func (l *Layer) MoveBy(dx, dy int16) {
+	l.Move(l.rect.x1+dx, l.rect.y1+dy, l.rect.x2-l.rect.x1, l.rect.y2-l.rect.y1)
+}
+
+// Resize changes the size of this layer, keeping the top left corner in place.
+// As the objects in the layer don't move, only the areas along the edges that
+// changed are redrawn.
+func (l *Layer) Resize(width, height int16) {
+	l.Move(l.rect.x1, l.rect.y1, width, height)
+}
+
+// Remove removes the given object from this layer, so that it won't be drawn
+// anymore. The area the object covered will be redrawn on the next call to
+// Display. Removing an object that is not a direct child of this layer (such
+// as the background of the layer itself) is a no-op.
+func (l *Layer) Remove(obj Element) {
+	i := l.indexOf(obj)
+	if i < 0 {
+		return
+	}
+
+	// Remove the object while keeping the order of the other objects.
+	copy(l.objects[i:], l.objects[i+1:])
+	l.objects[len(l.objects)-1] = nil
+	l.objects = l.objects[:len(l.objects)-1]
+
+	// Redraw the area under the object.
+	l.invalidateChild(obj)
+}
+
+// BringToFront moves the given object to the top of the stacking order of this
+// layer, so that it is drawn above all other objects in the layer. It is a
+// no-op if the object is not a direct child of this layer.
+func (l *Layer) BringToFront(obj Element) {
+	i := l.indexOf(obj)
+	if i < 0 {
+		return
+	}
+	copy(l.objects[i:], l.objects[i+1:])
+	l.objects[len(l.objects)-1] = obj
+	l.invalidateChild(obj)
+}
+
+// SendToBack moves the given object to the bottom of the stacking order of
+// this layer, so that it is drawn below all other objects in the layer. It is
+// a no-op if the object is not a direct child of this layer.
+func (l *Layer) SendToBack(obj Element) {
+	i := l.indexOf(obj)
+	if i < 0 {
+		return
+	}
+	copy(l.objects[1:i+1], l.objects[:i])
+	l.objects[0] = obj
+	l.invalidateChild(obj)
+}
+
+// Adopt moves the given object (which may also be a layer) from its current
+// parent layer to the top of this layer, without recreating it. The coordinates
+// of the object are kept as they are, so they are now relative to this layer.
+// Both the old and the new area of the object are redrawn. It is a no-op when
+// the object is a root layer or when it is this layer or one of its parents, as
+// that would create a cycle. Both layers must belong to the same engine.
+func (l *Layer) Adopt(obj Element) {
+	old := obj.parentLayer()
+	if old == nil {
+		return
+	}
+	for parent := l; parent != nil; parent = parent.parent {
+		if Element(parent) == obj {
+			return
+		}
+	}
+	old.Remove(obj)
+	obj.setParent(l)
+	l.objects = append(l.objects, obj)
+	l.invalidateChild(obj)
+}
+
+// indexOf returns the index of the given object in the list of objects of this
+// layer, or -1 if it isn't a direct child of this layer.
+func (l *Layer) indexOf(obj Element) int {
+	for i, child := range l.objects {
+		if child == obj {
+			return i
+		}
+	}
+	return -1
+}
+
+// invalidateChild marks the tiles under the bounding box of the given child
+// object as needing to be re-painted.
+func (l *Layer) invalidateChild(obj Element) {
+	x1, y1, x2, y2 := obj.boundingBox()
+	r := Rectangle{parent: l}
+	r.invalidate(x1, y1, x2, y2)
+}
+
+// Add adds a custom object to the top of this layer. Call Update on the
+// returned object whenever the custom object changes.
+func (l *Layer) Add(obj Object) *Custom {
+	c := &Custom{
+		parent: l,
+		obj:    obj,
+	}
+	c.x1, c.y1, c.x2, c.y2 = obj.BoundingBox()
+	l.objects = append(l.objects, c)
+	c.invalidate()
+	return c
+}
+
 // NewRectangle adds a new rectangle to the layer with the given color.
 func (l *Layer) NewRectangle(x, y, width, height int16, c color.RGBA) *Rectangle {
 	r := &Rectangle{
+		parent:  l,
+		x1:      x,
+		y1:      y,
+		x2:      x + width,
+		y2:      y + height,
+		color:   c,
+		opacity: 255,
+	}
+	l.objects = append(l.objects, r)
+	r.invalidate(r.x1, r.y1, r.x2, r.y2)
+	return r
+}
+
+// NewRectangleOutline adds the border of a rectangle to the layer, with the
+// given thickness and color. The border is drawn on the inside of the
+// rectangle.
+func (l *Layer) NewRectangleOutline(x, y, width, height, thickness int16, c color.RGBA) *RectangleOutline {
+	r := &RectangleOutline{
+		parent:    l,
+		x1:        x,
+		y1:        y,
+		x2:        x + width,
+		y2:        y + height,
+		thickness: thickness,
+		color:     c,
+	}
+	l.objects = append(l.objects, r)
+	r.invalidate()
+	return r
+}
+
+// NewBeveledRectangle adds a filled rectangle with a beveled border to the
+// layer. The border is bevel pixels wide and is drawn on the inside of the
+// rectangle, with the light color on the top and left edges and the dark color
+// on the bottom and right edges.
+func (l *Layer) NewBeveledRectangle(x, y, width, height, bevel int16, fill, light, dark color.RGBA) *BeveledRectangle {
+	r := &BeveledRectangle{
+		parent: l,
+		x1:     x,
+		y1:     y,
+		x2:     x + width,
+		y2:     y + height,
+		bevel:  bevel,
+		fill:   fill,
+		light:  light,
+		dark:   dark,
+	}
+	l.objects = append(l.objects, r)
+	r.invalidate()
+	return r
+}
+
+// NewRoundedRectangle adds a new filled rectangle with rounded corners to the
+// layer. The radius is limited to half the width or height of the rectangle.
+func (l *Layer) NewRoundedRectangle(x, y, width, height, radius int16, c color.RGBA) *RoundedRectangle {
+	r := &RoundedRectangle{
 		parent: l,
 		x1:     x,
 		y1:     y,
@@ -45,8 +574,9 @@ func (l *Layer) NewRectangle(x, y, width, height int16, c color.RGBA) *Rectangle
 		y2:     y + height,
 		color:  c,
 	}
+	r.setRadius(radius)
 	l.objects = append(l.objects, r)
-	r.invalidate(r.x1, r.y1, r.x2, r.y2)
+	r.invalidate()
 	return r
 }
 
@@ -61,8 +591,9 @@ func (l *Layer) NewLayer(x, y, width, height int16, background color.RGBA) *Laye
 			y2:    y + height,
 			color: background,
 		},
-		engine: l.engine,
-		parent: l,
+		engine:  l.engine,
+		parent:  l,
+		opacity: 255,
 	}
 	child.rect.parent = child
 	child.rect.invalidate(child.rect.x1, child.rect.y1, child.rect.x2, child.rect.y2)
@@ -75,82 +606,406 @@ func (l *Layer) NewLayer(x, y, width, height int16, background color.RGBA) *Laye
 // not exlusive, meaning that those pixels will get painted as well.
 func (l *Layer) NewLine(x1, y1, x2, y2 int16, stroke color.RGBA) *Line {
 	// Let the first coordinate always be to the left of the second coordinate.
-	if x1 > x2 {
+	reversed := x1 > x2
+	if reversed {
 		x1, x2 = x2, x1
 		y1, y2 = y2, y1
 	}
 	line := &Line{
+		parent:   l,
+		x1:       x1,
+		y1:       y1,
+		x2:       x2,
+		y2:       y2,
+		reversed: reversed,
+		width:    1,
+		color:    stroke,
+		opacity:  255,
+	}
+	l.objects = append(l.objects, line)
+	line.invalidate()
+	return line
+}
+
+// NewLineF creates a new single pixel wide line with end points at sub-pixel
+// positions, with the given stroke color. There is no restriction on the order
+// of the coordinates.
+func (l *Layer) NewLineF(x1, y1, x2, y2 float32, stroke color.RGBA) *LineF {
+	line := &LineF{
 		parent: l,
-		x1:     x1,
-		y1:     y1,
-		x2:     x2,
-		y2:     y2,
 		color:  stroke,
 	}
+	line.setPoints(x1, y1, x2, y2)
 	l.objects = append(l.objects, line)
 	line.invalidate()
 	return line
 }
 
-// paint draws the layer (and nothing outside the layer) to the tile at
-// coordinates tileX and tileY.
-func (l *Layer) paint(t *tile, tileX, tileY int16) {
-	// Get a new tile to paint on from the tile pool, to avoid a heap
-	// allocation.
-	subtile := l.engine.getTile()
+// NewHRule adds a new horizontal line at the given y coordinate to the layer,
+// that always spans the whole width of the layer (even after it is resized).
+func (l *Layer) NewHRule(y int16, c color.RGBA) *Rule {
+	r := &Rule{
+		parent: l,
+		pos:    y,
+		color:  c,
+	}
+	l.objects = append(l.objects, r)
+	r.invalidate()
+	return r
+}
 
-	// Paint the background, simply by filling this subtile with the layer
-	// background color. Blending takes place when painting this tile on the
-	// background, so don't blend here.
-	for y := 0; y < TileSize; y++ {
-		for x := 0; x < TileSize; x++ {
-			subtile[y*TileSize+x] = l.rect.color
-		}
+// NewVRule adds a new vertical line at the given x coordinate to the layer,
+// that always spans the whole height of the layer (even after it is resized).
+func (l *Layer) NewVRule(x int16, c color.RGBA) *Rule {
+	r := &Rule{
+		parent:   l,
+		pos:      x,
+		vertical: true,
+		color:    c,
+	}
+	l.objects = append(l.objects, r)
+	r.invalidate()
+	return r
+}
+
+// NewDashedLine adds a new dashed line to the layer, with dashes and gaps of the
+// given lengths (in pixels). See Line.SetDashPattern for details.
+func (l *Layer) NewDashedLine(x1, y1, x2, y2 int16, stroke color.RGBA, dash, gap int16) *Line {
+	line := l.NewLine(x1, y1, x2, y2, stroke)
+	line.SetDashPattern([]int16{dash, gap})
+	return line
+}
+
+// NewThickLine creates a new line like NewLine, but with the given stroke
+// width in pixels.
+func (l *Layer) NewThickLine(x1, y1, x2, y2, width int16, stroke color.RGBA) *Line {
+	line := l.NewLine(x1, y1, x2, y2, stroke)
+	line.SetWidth(width)
+	return line
+}
+
+// NewRadialLine adds a new line to the layer that starts at the pivot (cx, cy)
+// and points at the given angle (in radians, see RadialLine.SetAngle), like the
+// hand of a clock.
+func (l *Layer) NewRadialLine(cx, cy, length int16, angle float32, c color.RGBA) *RadialLine {
+	r := &RadialLine{
+		line: Line{
+			parent:  l,
+			x1:      cx,
+			y1:      cy,
+			x2:      cx,
+			y2:      cy,
+			width:   1,
+			color:   c,
+			opacity: 255,
+		},
+		cx:     cx,
+		cy:     cy,
+		length: length,
+	}
+	l.objects = append(l.objects, r)
+	r.SetAngle(angle)
+	return r
+}
+
+// NewPolyline adds a new open path of anti-aliased lines to the layer,
+// connecting each point to the next. The points slice is used directly, so it
+// must not be modified afterwards.
+func (l *Layer) NewPolyline(points []image.Point, stroke color.RGBA) *Polyline {
+	p := &Polyline{
+		parent: l,
+		points: points,
+		color:  stroke,
+	}
+	l.objects = append(l.objects, p)
+	p.invalidate()
+	return p
+}
+
+// NewQuadBezier adds a new anti-aliased quadratic Bézier curve to the layer,
+// from (x0, y0) to (x1, y1) with the control point (cx, cy).
+func (l *Layer) NewQuadBezier(x0, y0, cx, cy, x1, y1 int16, stroke color.RGBA) *QuadBezier {
+	b := &QuadBezier{
+		parent: l,
+		color:  stroke,
+	}
+	b.setPoints(x0, y0, cx, cy, x1, y1)
+	l.objects = append(l.objects, b)
+	b.invalidate()
+	return b
+}
+
+// NewPolygon adds a new filled convex polygon with the given corners, in
+// clockwise or counter-clockwise order. The points slice is used directly, so it
+// must not be modified afterwards.
+func (l *Layer) NewPolygon(points []image.Point, c color.RGBA) *Polygon {
+	p := &Polygon{
+		parent: l,
+		points: points,
+		color:  c,
+	}
+	l.objects = append(l.objects, p)
+	p.invalidate()
+	return p
+}
+
+// NewPixel adds a single pixel to the layer at the given coordinates.
+func (l *Layer) NewPixel(x, y int16, c color.RGBA) *Pixel {
+	p := &Pixel{
+		parent: l,
+		x:      x,
+		y:      y,
+		color:  c,
+	}
+	l.objects = append(l.objects, p)
+	p.invalidate()
+	return p
+}
+
+// NewPoints adds a new set of single pixels to the layer, all in the same
+// color. The points slice is used directly, so it must not be modified
+// afterwards (use AddPoint instead).
+func (l *Layer) NewPoints(points []image.Point, c color.RGBA) *Points {
+	p := &Points{
+		parent: l,
+		points: points,
+		color:  c,
+	}
+	l.objects = append(l.objects, p)
+	p.invalidate()
+	return p
+}
+
+// NewCircle creates a new filled circle with the given center, radius and fill
+// color. The edges of the circle are anti-aliased.
+func (l *Layer) NewCircle(cx, cy, radius int16, c color.RGBA) *Circle {
+	circle := &Circle{
+		parent: l,
+		cx:     cx,
+		cy:     cy,
+		radius: radius,
+		color:  c,
+	}
+	l.objects = append(l.objects, circle)
+	circle.invalidate()
+	return circle
+}
+
+// NewCircleOutline creates a new ring with the given center, outer radius,
+// thickness and color. The ring is drawn on the inside of the circle, and both
+// its inner and outer edges are anti-aliased.
+func (l *Layer) NewCircleOutline(cx, cy, radius, thickness int16, c color.RGBA) *CircleOutline {
+	circle := &CircleOutline{
+		parent:    l,
+		cx:        cx,
+		cy:        cy,
+		radius:    radius,
+		thickness: thickness,
+		color:     c,
+	}
+	l.objects = append(l.objects, circle)
+	circle.invalidate()
+	return circle
+}
+
+// NewGradientRectangle adds a new rectangle filled with a gradient from the
+// start color to the end color. The gradient runs from top to bottom if
+// vertical is true, and from left to right otherwise.
+func (l *Layer) NewGradientRectangle(x, y, width, height int16, start, end color.RGBA, vertical bool) *GradientRectangle {
+	r := &GradientRectangle{
+		parent:   l,
+		x1:       x,
+		y1:       y,
+		x2:       x + width,
+		y2:       y + height,
+		start:    start,
+		end:      end,
+		vertical: vertical,
+	}
+	l.objects = append(l.objects, r)
+	r.invalidate()
+	return r
+}
+
+// NewPatternRectangle adds a new rectangle filled with a checkerboard pattern
+// of square cells of the given size, alternating between colorA and colorB.
+// The top left cell has colorA.
+func (l *Layer) NewPatternRectangle(x, y, width, height int16, colorA, colorB color.RGBA, cellSize int16) *PatternRectangle {
+	r := &PatternRectangle{
+		parent:   l,
+		x1:       x,
+		y1:       y,
+		x2:       x + width,
+		y2:       y + height,
+		colorA:   colorA,
+		colorB:   colorB,
+		cellSize: cellSize,
+	}
+	l.objects = append(l.objects, r)
+	r.invalidate()
+	return r
+}
+
+// NewSprite creates a new sprite that draws the given image with the top left
+// corner at the given coordinates. The image must not be modified while it is
+// part of a layer.
+func (l *Layer) NewSprite(x, y int16, img image.Image) *Sprite {
+	sprite := &Sprite{
+		parent: l,
+		x:      x,
+		y:      y,
+		img:    img,
+	}
+	l.objects = append(l.objects, sprite)
+	sprite.invalidate()
+	return sprite
+}
+
+// NewArc adds a new arc (part of a ring) to the layer, drawn clockwise from
+// startAngle to endAngle (in degrees, where 0° points to the right). The ring
+// has the given outer radius and thickness. See Arc for details.
+func (l *Layer) NewArc(cx, cy, radius, thickness int16, startAngle, endAngle float32, c color.RGBA) *Arc {
+	a := &Arc{
+		parent:    l,
+		cx:        cx,
+		cy:        cy,
+		radius:    radius,
+		thickness: thickness,
+		start:     startAngle,
+		end:       endAngle,
+		color:     c,
+	}
+	l.objects = append(l.objects, a)
+	a.invalidate()
+	return a
+}
+
+// NewEllipse creates a new filled ellipse with the given center, horizontal and
+// vertical radius and fill color. The edges of the ellipse are anti-aliased.
+// Nothing is drawn when one of the radii is zero.
+func (l *Layer) NewEllipse(cx, cy, rx, ry int16, c color.RGBA) *Ellipse {
+	e := &Ellipse{
+		parent: l,
+		cx:     cx,
+		cy:     cy,
+		rx:     rx,
+		ry:     ry,
+		color:  c,
+	}
+	l.objects = append(l.objects, e)
+	e.invalidate()
+	return e
+}
+
+// NewTriangle creates a new filled triangle with the given corners and fill
+// color. The edges of the triangle are anti-aliased.
+func (l *Layer) NewTriangle(x1, y1, x2, y2, x3, y3 int16, c color.RGBA) *Triangle {
+	tr := &Triangle{
+		parent: l,
+		points: [3]image.Point{{int(x1), int(y1)}, {int(x2), int(y2)}, {int(x3), int(y3)}},
+		color:  c,
 	}
+	l.objects = append(l.objects, tr)
+	tr.invalidate()
+	return tr
+}
 
-	// Draw all objects in this tile.
-	l.paintObjects(subtile, tileX, tileY)
+// NewText creates a new line of text with the given font and color. The x and y
+// coordinates are the top left corner of the text.
+func (l *Layer) NewText(x, y int16, s string, font *Font, c color.RGBA) *Text {
+	text := &Text{
+		parent: l,
+		x:      x,
+		y:      y,
+		text:   []rune(s),
+		font:   font,
+		color:  c,
+	}
+	l.objects = append(l.objects, text)
+	text.invalidate()
+	return text
+}
 
-	// Move the tile coordinates into the layer coordinate system.
-	tileX -= l.rect.x1
-	tileY -= l.rect.y1
+// paint draws the layer (and nothing outside the layer) to the tile at
+// coordinates tileX and tileY.
+func (l *Layer) paint(t *tile, tileX, tileY int16) {
+	if l.hidden || l.opacity == 0 {
+		// Hidden layers don't paint anything, so that the parent layer shows
+		// through.
+		return
+	}
 
-	// Determine the bounds of the tile that should be painted to.
-	var (
-		x1 = int16(0)
-		x2 = int16(TileSize)
-		y1 = int16(0)
-		y2 = int16(TileSize)
-	)
-	if tileX < 0 {
-		x1 = -tileX
+	// Determine the bounds of the tile that should be painted to, by moving
+	// the visible area of the layer into the tile coordinate system.
+	x1, y1, x2, y2 := l.clipBounds()
+	x1 += l.rect.x1 - tileX
+	y1 += l.rect.y1 - tileY
+	x2 += l.rect.x1 - tileX
+	y2 += l.rect.y1 - tileY
+	if x1 < 0 {
+		x1 = 0
+	}
+	if y1 < 0 {
+		y1 = 0
 	}
-	if tileY < 0 {
-		y1 = -tileY
+	if x2 > t.width {
+		x2 = t.width
 	}
-	if tileX+TileSize > l.rect.x2-l.rect.x1 {
-		x2 = (l.rect.x2 - l.rect.x1) - tileX
+	if y2 > t.height {
+		y2 = t.height
 	}
-	if tileY+TileSize > l.rect.y2-l.rect.y1 {
-		y2 = (l.rect.y2 - l.rect.y1) - tileY
+	if x1 >= x2 || y1 >= y2 {
+		// Nothing of this layer is visible in this tile.
+		return
+	}
+
+	background := l.backgroundColor()
+	if background.A == 0xff && l.opacity == 0xff && l.background == nil && l.cache == nil && !l.hasObjectsIn(t, tileX, tileY) {
+		// Fastest path: the layer has an opaque background color and there is
+		// nothing else to draw in this tile. Fill the passed in tile directly,
+		// without painting a temporary tile first.
+		for y := y1; y < y2; y++ {
+			row := t.pixels[y*t.stride : y*t.stride+t.width]
+			for x := x1; x < x2; x++ {
+				row[x] = background
+			}
+		}
+		return
+	}
+
+	// Get a new tile to paint on from the tile pool, to avoid a heap
+	// allocation.
+	subtile := l.engine.getTile(t.width, t.height)
+
+	if l.cache != nil {
+		// The layer is flattened, so take the pixels from the cache.
+		l.paintFromCache(subtile, tileX, tileY)
+	} else {
+		// Paint the background and all objects in this tile.
+		l.paintBackground(subtile, tileX, tileY)
+		l.paintObjects(subtile, tileX, tileY)
 	}
 
 	// Paint the underlying tile using the temporary tile.
-	if l.rect.color.A == 0xff {
+	if background.A == 0xff && l.opacity == 0xff {
 		// Fast path: tile is fully opaque. We can draw directly in the passed
 		// in tile.
-		for x := x1; x < x2; x++ {
-			for y := y1; y < y2; y++ {
-				t[y*TileSize+x] = subtile[y*TileSize+x]
-			}
+		for y := y1; y < y2; y++ {
+			copy(t.pixels[y*t.stride+x1:y*t.stride+x2], subtile.pixels[y*subtile.stride+x1:y*subtile.stride+x2])
 		}
-	} else {
+	} else if l.opacity == 0xff {
 		// Slow path. The background of this tile is at least partially
 		// transparent, so blend the temporary tile with the passed in tile.
-		for x := x1; x < x2; x++ {
-			for y := y1; y < y2; y++ {
-				t[y*TileSize+x] = Blend(t[y*TileSize+x], subtile[y*TileSize+x])
-			}
+		for y := y1; y < y2; y++ {
+			BlendBuffer(t.pixels[y*t.stride+x1:y*t.stride+x2], subtile.pixels[y*subtile.stride+x1:y*subtile.stride+x2])
+		}
+	} else {
+		// Slowest path. The whole layer is partially transparent, so apply the
+		// opacity to every pixel before blending it.
+		for y := y1; y < y2; y++ {
+			row := subtile.pixels[y*subtile.stride+x1 : y*subtile.stride+x2]
+			ApplyAlphaBuffer(row, l.opacity)
+			BlendBuffer(t.pixels[y*t.stride+x1:y*t.stride+x2], row)
 		}
 	}
 
@@ -158,20 +1013,118 @@ func (l *Layer) paint(t *tile, tileX, tileY int16) {
 	l.engine.putTile(subtile)
 }
 
+// paintBackground fills the given tile with the layer background color (or
+// image). Blending takes place when painting this tile on the background, so
+// don't blend here.
+func (l *Layer) paintBackground(t *tile, tileX, tileY int16) {
+	if l.background != nil {
+		imageX := int(tileX - l.rect.x1)
+		imageY := int(tileY - l.rect.y1)
+		for y := int16(0); y < t.height; y++ {
+			for x := int16(0); x < t.width; x++ {
+				t.pixels[y*t.stride+x] = l.backgroundAt(imageX+int(x), imageY+int(y))
+			}
+		}
+	} else {
+		background := l.backgroundColor()
+		for y := int16(0); y < t.height; y++ {
+			row := t.pixels[y*t.stride : y*t.stride+t.width]
+			for i := range row {
+				row[i] = background
+			}
+		}
+	}
+}
+
+// paintFromCache copies the part of the flattened layer that falls within the
+// given tile into the tile, painting the whole layer into the cache first if it
+// isn't up-to-date. Pixels in the tile outside of the layer are left as-is.
+func (l *Layer) paintFromCache(t *tile, tileX, tileY int16) {
+	width := l.rect.x2 - l.rect.x1
+	height := l.rect.y2 - l.rect.y1
+	if !l.cacheValid {
+		if len(l.cache) != int(width)*int(height) {
+			// The layer was resized.
+			l.cache = make([]color.RGBA, int(width)*int(height))
+		}
+		l.engine.paintBuffer(l.rect.x1, l.rect.y1, width, height, l.cache, func(t *tile, tileX, tileY int16) {
+			l.paintBackground(t, tileX, tileY)
+			l.paintObjects(t, tileX, tileY)
+		})
+		l.cacheValid = true
+	}
+
+	// Copy the visible part of the cache, row by row.
+	x1 := tileX - l.rect.x1
+	y1 := tileY - l.rect.y1
+	x2 := x1 + t.width
+	y2 := y1 + t.height
+	offsetX := int16(0)
+	offsetY := int16(0)
+	if x1 < 0 {
+		offsetX = -x1
+		x1 = 0
+	}
+	if y1 < 0 {
+		offsetY = -y1
+		y1 = 0
+	}
+	if x2 > width {
+		x2 = width
+	}
+	if y2 > height {
+		y2 = height
+	}
+	if x1 >= x2 {
+		return
+	}
+	for y := y1; y < y2; y++ {
+		row := t.pixels[(offsetY+y-y1)*t.stride+offsetX:]
+		copy(row[:x2-x1], l.cache[int(y)*int(width)+int(x1):])
+	}
+}
+
+// hasObjectsIn returns whether any of the objects in this layer would be
+// painted in the given tile, at the given coordinates.
+func (l *Layer) hasObjectsIn(t *tile, tileX, tileY int16) bool {
+	tileX += l.scrollX - l.rect.x1
+	tileY += l.scrollY - l.rect.y1
+	offsetsX, offsetsY, n := l.wrapOffsets()
+	for _, obj := range l.objects {
+		x1, y1, x2, y2 := obj.boundingBox()
+		for _, dy := range offsetsY[:n] {
+			for _, dx := range offsetsX[:n] {
+				if x1 >= tileX+dx+t.width || y1 >= tileY+dy+t.height || x2 <= tileX+dx || y2 <= tileY+dy {
+					continue
+				}
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // paintObjects will paint the objects in this layer into the given tile, at the
 // given coordinates.
 func (l *Layer) paintObjects(t *tile, tileX, tileY int16) {
-	// Move the tile coordinates into the layer coordinate system.
-	tileX -= l.rect.x1
-	tileY -= l.rect.y1
+	// Move the tile coordinates into the layer coordinate system, taking the
+	// scroll offset into account.
+	tileX += l.scrollX - l.rect.x1
+	tileY += l.scrollY - l.rect.y1
 
-	// Draw all objects in this tile.
+	// Draw all objects in this tile. Objects in a wrapping layer may be drawn
+	// more than once, shifted by the layer size, see SetWrap.
+	offsetsX, offsetsY, n := l.wrapOffsets()
 	for _, obj := range l.objects {
 		x1, y1, x2, y2 := obj.boundingBox()
-		if x1 > tileX+TileSize || y1 > tileY+TileSize || x2 <= tileX || y2 <= tileY {
-			// Object falls outside of this layer, so don't draw.
-			continue
+		for _, dy := range offsetsY[:n] {
+			for _, dx := range offsetsX[:n] {
+				if x1 >= tileX+dx+t.width || y1 >= tileY+dy+t.height || x2 <= tileX+dx || y2 <= tileY+dy {
+					// Object falls outside of this tile, so don't draw.
+					continue
+				}
+				obj.paint(t, tileX+dx, tileY+dy)
+			}
 		}
-		obj.paint(t, tileX, tileY)
 	}
 }
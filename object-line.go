@@ -1,13 +1,65 @@
 package tilegraphics
 
-import "image/color"
+import (
+	"image/color"
+	"math"
+)
 
 // Line is an anti-aliased line drawn between two coordinates (inclusive), with
-// a given color. It supports transparency in the color.
+// a given color and stroke width. It supports transparency in the color, and
+// can optionally be drawn dashed or without anti-aliasing.
 type Line struct {
 	parent         *Layer
 	x1, y1, x2, y2 int16
+	reversed       bool // the points were swapped to get x1 <= x2
+	width          int16
+	dash           dashPattern
+	aliased        bool // draw without anti-aliasing
+	cap            LineCap
 	color          color.RGBA
+	opacity        uint8 // multiplied with the color alpha while painting
+}
+
+// LineCap is the shape of the ends of a thick line, see Line.SetCap.
+type LineCap uint8
+
+const (
+	// CapButt cuts the line off straight, half a pixel beyond the end points.
+	// This is the default.
+	CapButt LineCap = iota
+
+	// CapRound ends the line in a semicircle around each end point, with a
+	// diameter of the stroke width. This looks better where two lines meet at
+	// an angle.
+	CapRound
+)
+
+// dashPattern is a list of alternating dash and gap lengths in pixels, starting
+// with a dash. It always has an even length. An empty pattern means a solid
+// line.
+type dashPattern []int16
+
+// visible returns whether the pixel at the given position along the line (0 at
+// the start of the line) falls in a dash.
+func (d dashPattern) visible(pos int16) bool {
+	if len(d) == 0 {
+		return true
+	}
+	total := int16(0)
+	for _, n := range d {
+		total += n
+	}
+	pos %= total
+	if pos < 0 {
+		pos += total
+	}
+	for i, n := range d {
+		if pos < n {
+			return i%2 == 0
+		}
+		pos -= n
+	}
+	return false
 }
 
 // boundingBox returns the bounding box of this line.
@@ -24,86 +76,341 @@ func (l *Line) boundingBox() (x1, y1, x2, y2 int16) {
 	if y1 > y2 {
 		y1, y2 = y2, y1
 	}
+	if l.width > 1 {
+		// Thick lines extend to both sides of the line, and round caps extend
+		// half the width beyond the end points. Be conservative here, a
+		// slightly bigger bounding box is much better than a too small one.
+		extra := l.width/2 + 1
+		return x1 - extra, y1 - extra, x2 + 1 + extra, y2 + 1 + extra
+	}
 	return x1, y1, x2 + 1, y2 + 1
 }
 
+// contains returns whether the given point lies at most half the stroke width
+// plus half a pixel away from the line. This makes thin lines a bit easier to
+// hit.
+func (l *Line) contains(x, y int16) bool {
+	dx := int64(l.x2 - l.x1)
+	dy := int64(l.y2 - l.y1)
+	px := int64(x - l.x1)
+	py := int64(y - l.y1)
+	width := int64(l.width)
+	if width < 1 {
+		width = 1
+	}
+
+	// Calculate the squared distance from the point to the closest point on
+	// the line, times 4 to avoid fractions.
+	var dist4 int64
+	length2 := dx*dx + dy*dy
+	along := px*dx + py*dy
+	switch {
+	case length2 == 0 || along <= 0:
+		// Closest to the start of the line.
+		dist4 = 4 * (px*px + py*py)
+	case along >= length2:
+		// Closest to the end of the line.
+		qx := px - dx
+		qy := py - dy
+		dist4 = 4 * (qx*qx + qy*qy)
+	default:
+		across := px*dy - py*dx
+		dist4 = 4 * across * across / length2
+	}
+	return dist4 <= (width+1)*(width+1)
+}
+
+// parentLayer returns the layer this object is part of.
+func (l *Line) parentLayer() *Layer {
+	return l.parent
+}
+
+// setParent changes the layer this object is part of, without invalidating
+// anything.
+func (l *Line) setParent(parent *Layer) {
+	l.parent = parent
+}
+
+// Move sets the new coordinates of this line. Like NewLine, there is no
+// restriction on the order of the coordinates.
+func (l *Line) Move(x1, y1, x2, y2 int16) {
+	// Let the first coordinate always be to the left of the second coordinate.
+	reversed := x1 > x2
+	if reversed {
+		x1, x2 = x2, x1
+		y1, y2 = y2, y1
+	}
+	l.invalidate()
+	l.x1 = x1
+	l.y1 = y1
+	l.x2 = x2
+	l.y2 = y2
+	l.reversed = reversed
+	l.invalidate()
+}
+
+// Length returns the distance between the two end points of this line, in
+// pixels.
+func (l *Line) Length() float32 {
+	dx := float64(l.x2) - float64(l.x1)
+	dy := float64(l.y2) - float64(l.y1)
+	return float32(math.Sqrt(dx*dx + dy*dy))
+}
+
+// Angle returns the direction of this line from the first to the second end
+// point (as passed to NewLine or Move) in radians, in the range -π to π. An
+// angle of 0 points to the right, and as the y axis points down, positive
+// angles are clockwise on the screen: π/2 points down.
+func (l *Line) Angle() float32 {
+	dx := int32(l.x2) - int32(l.x1)
+	dy := int32(l.y2) - int32(l.y1)
+	if l.reversed {
+		dx, dy = -dx, -dy
+	}
+	return float32(math.Atan2(float64(dy), float64(dx)))
+}
+
+// SetColor updates the stroke color of this line.
+func (l *Line) SetColor(c color.RGBA) {
+	l.color = c
+	l.invalidate()
+}
+
+// SetOpacity changes the opacity of this line, without changing its color. See
+// Rectangle.SetOpacity for details.
+func (l *Line) SetOpacity(opacity uint8) {
+	l.opacity = opacity
+	l.invalidate()
+}
+
+// SetWidth changes the stroke width of this line. A width of 1 (or lower)
+// results in the standard single-pixel line.
+func (l *Line) SetWidth(width int16) {
+	l.invalidate()
+	l.width = width
+	l.invalidate()
+}
+
+// SetCap changes the shape of the ends of this line, see LineCap. It only
+// affects lines with a stroke width above 1.
+func (l *Line) SetCap(style LineCap) {
+	l.cap = style
+	l.invalidate()
+}
+
+// SetDashPattern changes the line to be drawn dashed. The pattern is a list of
+// alternating dash and gap lengths in pixels, starting with a dash. If the
+// pattern has an odd number of elements, it is repeated to make it even (so
+// that 5, 3, 2 is drawn as 5, 3, 2, 5, 3, 2). An empty or nil pattern results
+// in a solid line again.
+//
+// The pattern always starts at the leftmost point of the line, or the top point
+// for vertical lines. For thin lines, the lengths are counted in pixels along
+// the x or y axis (whichever is longer) while for thick lines they are measured
+// along the line itself.
+func (l *Line) SetDashPattern(pattern []int16) {
+	dash := dashPattern(nil)
+	total := int16(0)
+	for _, n := range pattern {
+		if n < 0 {
+			n = 0
+		}
+		dash = append(dash, n)
+		total += n
+	}
+	if total <= 0 {
+		// Nothing to draw, or an invalid pattern.
+		dash = nil
+	} else if len(dash)%2 != 0 {
+		dash = append(dash, dash...)
+	}
+	l.dash = dash
+	l.invalidate()
+}
+
+// SetAntialiased changes whether this line is drawn with anti-aliased edges,
+// which is the default. Without anti-aliasing, every pixel is either painted in
+// the line color or not at all. This looks more jagged, but is faster to paint
+// as no blending is needed for opaque colors.
+func (l *Line) SetAntialiased(antialiased bool) {
+	l.aliased = !antialiased
+	l.invalidate()
+}
+
+// Remove removes this line from its parent layer. It must not be used
+// anymore afterwards.
+func (l *Line) Remove() {
+	l.parent.Remove(l)
+}
+
 // invalidate marks the tiles that this line goes over as needing to be
 // re-painted.
 func (l *Line) invalidate() {
-	// Crude hack to invalidate at least the area that this line is drawn in. It
-	// is possible to make this far more efficient, by marking just the tiles
-	// that need to be redrawn (using Wu's algorithm at the tile level).
-	x1, y1, x2, y2 := l.boundingBox()
-	r := Rectangle{
-		parent: l.parent,
-		x1:     x1,
-		y1:     y1,
-		x2:     x2,
-		y2:     y2,
+	// Thick lines extend to all sides of the line, see boundingBox.
+	margin := int16(0)
+	if l.width > 1 {
+		margin = l.width/2 + 1
+	}
+
+	// Walk along the major axis of the line, one tile column (or row) at a
+	// time, so that only the tiles near the line are marked instead of the
+	// whole bounding box. This makes a big difference for long diagonal
+	// lines.
+	r := Rectangle{parent: l.parent}
+	if _, _, _, _, visible := r.screenArea(l.boundingBox()); !visible {
+		// The line is entirely off-screen, so don't bother walking along it.
+		// This still marks flattened layers as needing to be painted again.
+		r.invalidate(l.boundingBox())
+		return
+	}
+	offsetX, offsetY := r.absolutePos(0, 0)
+	dy := l.y2 - l.y1
+	if dy < 0 {
+		dy = -dy
+	}
+	if l.x2-l.x1 >= dy {
+		r.invalidateLine(l.x1, l.y1, l.x2, l.y2, offsetX, margin, false)
+	} else if l.y1 < l.y2 {
+		r.invalidateLine(l.y1, l.x1, l.y2, l.x2, offsetY, margin, true)
+	} else {
+		r.invalidateLine(l.y2, l.x2, l.y1, l.x1, offsetY, margin, true)
+	}
+}
+
+// invalidateLine invalidates the area around a line from (a1, b1) to (a2, b2)
+// with a1 <= a2, in parts that each fall within a single tile column on the
+// screen. The a axis is the major axis of the line and offset is the screen
+// coordinate of a=0. When swapped is set, the a axis is the y axis instead of
+// the x axis. The line is assumed to cover at most margin pixels beyond its end
+// points on both axes.
+func (r *Rectangle) invalidateLine(a1, b1, a2, b2, offset, margin int16, swapped bool) {
+	tileSize := r.parent.engine.tileSize
+	da := int32(a2 - a1)
+	db := int32(b2 - b1)
+	for start := a1; start <= a2; {
+		// Find the last coordinate that falls in the same tile column.
+		column := (start + offset) % tileSize
+		if column < 0 {
+			column += tileSize
+		}
+		end := start + tileSize - 1 - column
+		if end > a2 {
+			end = a2
+		}
+
+		// Calculate the position on the minor axis at both ends of this part
+		// of the line. Anti-aliasing paints the pixel next to it as well, and
+		// the division rounds towards zero, so add a pixel on both sides.
+		bStart, bEnd := b1, b1
+		if da != 0 {
+			bStart = b1 + int16(int32(start-a1)*db/da)
+			bEnd = b1 + int16(int32(end-a1)*db/da)
+		}
+		if bStart > bEnd {
+			bStart, bEnd = bEnd, bStart
+		}
+		x1, y1, x2, y2 := start-margin, bStart-1-margin, end+1+margin, bEnd+2+margin
+		if swapped {
+			x1, y1, x2, y2 = y1, x1, y2, x2
+		}
+		r.invalidate(x1, y1, x2, y2)
+
+		if end == a2 {
+			break // avoid overflow at the end of the int16 range
+		}
+		start = end + 1
 	}
-	r.invalidate(x1, y1, x2, y2)
 }
 
 // paint draws the line to the given tile at coordinates tileX and tileY.
 func (l *Line) paint(t *tile, tileX, tileY int16) {
+	if l.opacity == 0 {
+		// Fully transparent, nothing to draw.
+		return
+	}
+	c := l.color
+	if l.opacity != 255 {
+		c = ApplyAlpha(c, l.opacity)
+	}
+
+	if l.width > 1 {
+		l.paintThick(t, tileX, tileY, c)
+		return
+	}
+
+	paintLineSegment(t, tileX, tileY, l.x1, l.y1, l.x2, l.y2, c, l.dash, !l.aliased)
+}
+
+// paintLineSegment paints a single pixel wide anti-aliased line between the
+// two given points (inclusive) to the tile at coordinates tileX and tileY. Only
+// the pixels that fall in a dash of the dash pattern are painted. The position
+// in the pattern is calculated from the start of the line, so that it doesn't
+// depend on the tile that is painted. Without anti-aliasing, only the pixel
+// closest to the line is painted in each column (or row).
+func paintLineSegment(t *tile, tileX, tileY, x1, y1, x2, y2 int16, c color.RGBA, dash dashPattern, antialiased bool) {
+	// Let the first coordinate always be to the left of the second coordinate.
+	if x1 > x2 {
+		x1, x2 = x2, x1
+		y1, y2 = y2, y1
+	}
+
 	switch {
-	case l.x1 == l.x2:
+	case x1 == x2:
 		// Easy: paint a vertical line.
-		y1, y2 := l.y1, l.y2
 		if y1 > y2 {
 			y1, y2 = y2, y1
 		}
-		x := l.x1 - tileX
+		x := x1 - tileX
 		y1 -= tileY
 		y2 -= tileY
-		if x < 0 || x >= TileSize {
+		yStart := y1
+		if x < 0 || x >= t.width {
 			return
 		}
 		if y1 < 0 {
 			y1 = 0
 		}
-		if y2 >= TileSize {
-			y2 = TileSize - 1
+		if y2 >= t.height {
+			y2 = t.height - 1
 		}
-		if l.color.A == 0xff {
-			// Fast path, directly painting the color into the tile.
-			for y := y1; y <= y2; y++ {
-				t[y*TileSize+x] = l.color
+		for y := y1; y <= y2; y++ {
+			if !dash.visible(y - yStart) {
+				continue
 			}
-		} else {
-			// Slow path, with color blending.
-			for y := y1; y <= y2; y++ {
-				t[y*TileSize+x] = Blend(t[y*TileSize+x], l.color)
+			if c.A == 0xff {
+				// Fast path, directly painting the color into the tile.
+				t.pixels[y*t.stride+x] = c
+			} else {
+				// Slow path, with color blending.
+				t.pixels[y*t.stride+x] = Blend(t.pixels[y*t.stride+x], c)
 			}
 		}
 
-	case l.y1 == l.y2:
+	case y1 == y2:
 		// Easy: paint a horizontal line.
-		x1, x2 := l.x1, l.x2
-		if x1 > x2 {
-			x1, x2 = x2, x1
-		}
-		y := l.y1 - tileY
+		y := y1 - tileY
 		x1 -= tileX
 		x2 -= tileX
-		if y < 0 || y >= TileSize {
+		xStart := x1
+		if y < 0 || y >= t.height {
 			return
 		}
 		if x1 < 0 {
 			x1 = 0
 		}
-		if x2 >= TileSize {
-			x2 = TileSize - 1
+		if x2 >= t.width {
+			x2 = t.width - 1
 		}
-		if l.color.A == 0xff {
-			// Fast path, directly painting the color into the tile.
-			for x := x1; x <= x2; x++ {
-				t[y*TileSize+x] = l.color
+		for x := x1; x <= x2; x++ {
+			if !dash.visible(x - xStart) {
+				continue
 			}
-		} else {
-			// Slow path, with color blending.
-			for x := x1; x <= x2; x++ {
-				t[y*TileSize+x] = Blend(t[y*TileSize+x], l.color)
+			if c.A == 0xff {
+				// Fast path, directly painting the color into the tile.
+				t.pixels[y*t.stride+x] = c
+			} else {
+				// Slow path, with color blending.
+				t.pixels[y*t.stride+x] = Blend(t.pixels[y*t.stride+x], c)
 			}
 		}
 
@@ -123,13 +430,13 @@ func (l *Line) paint(t *tile, tileX, tileY int16) {
 		// http://archive.gamedev.net/archive/reference/articles/article382.html
 
 		// Starting point, as an offset from the tile.
-		x1 := l.x1 - tileX
-		x2 := l.x2 - tileX
-		y1 := l.y1 - tileY
-		y2 := l.y2 - tileY
+		x1 -= tileX
+		x2 -= tileX
+		y1 -= tileY
+		y2 -= tileY
 
-		width := l.x2 - l.x1
-		height := l.y2 - l.y1
+		width := x2 - x1
+		height := y2 - y1
 		if height < 0 {
 			height = -height
 		}
@@ -143,15 +450,24 @@ func (l *Line) paint(t *tile, tileX, tileY int16) {
 			if x1 < 0 {
 				x1 = 0
 			}
-			if x2 >= TileSize {
-				x2 = TileSize - 1
+			if x2 >= t.width {
+				x2 = t.width - 1
 			}
 			for x := x1; x <= x2; x++ {
+				if !dash.visible(x - xStart) {
+					continue
+				}
 				// The y coordinate as a 15.16 fixed-point number.
 				yQ16 := int32(x-xStart) * yIncrementQ16
+				if !antialiased {
+					// Round to the nearest pixel, which results in the same
+					// pixels as Bresenham's algorithm.
+					fillPixel(t, x, y1+int16((yQ16+0x8000)>>16), c)
+					continue
+				}
 				y := y1 + int16(yQ16>>16)
-				l.paintPixel(t, x, y, 255-uint8(yQ16>>8))
-				l.paintPixel(t, x, y+1, uint8(yQ16>>8))
+				paintPixel(t, x, y, c, 255-uint8(yQ16>>8))
+				paintPixel(t, x, y+1, c, uint8(yQ16>>8))
 			}
 		} else {
 			// The line is more vertical than horizontal.
@@ -168,21 +484,199 @@ func (l *Line) paint(t *tile, tileX, tileY int16) {
 			if y1 < 0 {
 				y1 = 0
 			}
-			if y2 >= TileSize {
-				y2 = TileSize - 1
+			if y2 >= t.height {
+				y2 = t.height - 1
 			}
 			for y := y1; y <= y2; y++ {
+				if !dash.visible(y - yStart) {
+					continue
+				}
 				xQ16 := int32(y-yStart) * xIncrementQ16
+				if !antialiased {
+					fillPixel(t, x1+int16((xQ16+0x8000)>>16), y, c)
+					continue
+				}
 				x := x1 + int16(xQ16>>16)
-				l.paintPixel(t, x, y, 255-uint8(xQ16>>8))
-				l.paintPixel(t, x+1, y, uint8(xQ16>>8))
+				paintPixel(t, x, y, c, 255-uint8(xQ16>>8))
+				paintPixel(t, x+1, y, c, uint8(xQ16>>8))
+			}
+		}
+	}
+}
+
+// paintPixel blends the given color with the given weight into the pixel at
+// x, y, if that pixel lies within the tile.
+func paintPixel(t *tile, x, y int16, c color.RGBA, weight uint8) {
+	if x >= 0 && y >= 0 && x < t.width && y < t.height {
+		t.pixels[y*t.stride+x] = Blend(t.pixels[y*t.stride+x], ApplyAlpha(c, weight))
+	}
+}
+
+// fillPixel paints the given color into the pixel at x, y, if that pixel lies
+// within the tile.
+func fillPixel(t *tile, x, y int16, c color.RGBA) {
+	if x >= 0 && y >= 0 && x < t.width && y < t.height {
+		if c.A == 255 {
+			// Fast path, directly painting the color into the tile.
+			t.pixels[y*t.stride+x] = c
+		} else {
+			t.pixels[y*t.stride+x] = Blend(t.pixels[y*t.stride+x], c)
+		}
+	}
+}
+
+// paintThick paints a line that is wider than a single pixel. The line is drawn
+// as a rectangle rotated along the direction of the line and centered on it,
+// with anti-aliased edges in the given color. The ends of the line are cut off
+// straight or rounded, depending on the cap style. A line of zero length is
+// painted as a square or a circle of the line width, respectively. Without
+// anti-aliasing, pixels are painted when their center lies within the line.
+func (l *Line) paintThick(t *tile, tileX, tileY int16, c color.RGBA) {
+	bx1, by1, bx2, by2 := l.boundingBox()
+	bx1 -= tileX
+	by1 -= tileY
+	bx2 -= tileX
+	by2 -= tileY
+	if bx1 < 0 {
+		bx1 = 0
+	}
+	if by1 < 0 {
+		by1 = 0
+	}
+	if bx2 > t.width {
+		bx2 = t.width
+	}
+	if by2 > t.height {
+		by2 = t.height
+	}
+
+	if bx1 >= bx2 || by1 >= by2 {
+		// Not in this tile.
+		return
+	}
+
+	// The direction of the line, and its length as a Q8 fixed-point number.
+	dx := int64(l.x2 - l.x1)
+	dy := int64(l.y2 - l.y1)
+	lengthQ8 := int64(sqrtQ8(uint32(dx*dx + dy*dy)))
+	halfWidthQ8 := int64(l.width) * 128
+
+	// Runs of fully covered pixels are painted a part of a row at a time, by
+	// blending a row of the line color over them.
+	src := l.parent.engine.getTile(bx2-bx1, 1)
+	for i := range src.pixels {
+		src.pixels[i] = c
+	}
+	for y := by1; y < by2; y++ {
+		py := int64(y + tileY - l.y1)
+		row := t.pixels[y*t.stride : y*t.stride+bx2]
+		runStart := int16(-1)
+		for x := bx1; x < bx2; x++ {
+			coverage := l.thickCoverage(int64(x+tileX-l.x1), py, dx, dy, lengthQ8, halfWidthQ8)
+			if coverage == 255 {
+				if runStart < 0 {
+					runStart = x
+				}
+				continue
+			}
+			if runStart >= 0 {
+				paintRun(row[runStart:x], src.pixels)
+				runStart = -1
 			}
+			if coverage > 0 {
+				paintPixel(t, x, y, c, uint8(coverage))
+			}
+		}
+		if runStart >= 0 {
+			paintRun(row[runStart:], src.pixels)
 		}
 	}
+	l.parent.engine.putTile(src)
+}
+
+// thickCoverage returns how much of the pixel at px, py (relative to the start
+// of the line) is covered by a thick line, from 0 to 255. The direction of the
+// line is dx, dy, and its length and half its width are Q8 fixed-point numbers.
+// Without anti-aliasing, pixels are either covered fully or not at all.
+func (l *Line) thickCoverage(px, py, dx, dy, lengthQ8, halfWidthQ8 int64) int64 {
+	var inside int64
+	if lengthQ8 == 0 {
+		// A line of zero length has no direction, so paint it as a square
+		// with butt caps and as a circle with round caps.
+		if l.cap == CapRound {
+			inside = halfWidthQ8 - int64(sqrtQ8(uint32(px*px+py*py)))
+		} else {
+			distance := px
+			if distance < 0 {
+				distance = -distance
+			}
+			if py > distance {
+				distance = py
+			} else if -py > distance {
+				distance = -py
+			}
+			inside = halfWidthQ8 - distance<<8
+		}
+	} else {
+		// Calculate the distance of this pixel to the center of the line
+		// (across) and the distance from the start of the line along the line
+		// (along), both as Q8 fixed-point numbers.
+		across := (px*dy - py*dx) << 16 / (lengthQ8 | 1)
+		if across < 0 {
+			across = -across
+		}
+		along := (px*dx + py*dy) << 16 / (lengthQ8 | 1)
+		if !l.dash.visible(int16((along + 128) >> 8)) {
+			return 0
+		}
+
+		// Determine how far this pixel lies within the line, taking the
+		// closest edge. With butt caps, the ends of the line extend half a
+		// pixel beyond the end points, so that those pixels are fully painted.
+		// With round caps, pixels beyond an end point are painted by their
+		// distance to that end point.
+		inside = halfWidthQ8 - across
+		if l.cap == CapRound {
+			if along <= 0 {
+				inside = halfWidthQ8 - int64(sqrtQ8(uint32(px*px+py*py)))
+			} else if along >= lengthQ8 {
+				ex := px - dx
+				ey := py - dy
+				inside = halfWidthQ8 - int64(sqrtQ8(uint32(ex*ex+ey*ey)))
+			}
+		} else {
+			if start := along + 128; start < inside {
+				inside = start
+			}
+			if end := lengthQ8 + 128 - along; end < inside {
+				inside = end
+			}
+		}
+	}
+
+	coverage := inside + 128
+	if l.aliased {
+		if coverage < 128 {
+			return 0
+		}
+		return 255
+	}
+	if coverage < 0 {
+		return 0
+	}
+	if coverage > 255 {
+		return 255
+	}
+	return coverage
 }
 
-func (l *Line) paintPixel(t *tile, x, y int16, weight uint8) {
-	if x >= 0 && y >= 0 && x < TileSize && y < TileSize {
-		t[y*TileSize+x] = Blend(t[y*TileSize+x], ApplyAlpha(l.color, weight))
+// paintRun paints the line color (repeated in src) over a run of fully covered
+// pixels, using one call for the whole run.
+func paintRun(dst, src []color.RGBA) {
+	if src[0].A == 255 {
+		// Fast path: the line is opaque.
+		copy(dst, src)
+		return
 	}
+	BlendBuffer(dst, src)
 }
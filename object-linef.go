@@ -0,0 +1,198 @@
+package tilegraphics
+
+import (
+	"image/color"
+	"math"
+)
+
+// LineF is an anti-aliased line of a single pixel wide, with end points at
+// sub-pixel positions. This results in much smoother motion than a Line when
+// the line is animated slowly. It supports transparency in the color.
+//
+// Like with Line, pixel centers are at integer coordinates and the end points
+// are included: a line from x=10 to x=20 fully covers the pixels 10 to 20. A
+// line starting at x=10.5 only covers half of pixel 10.
+type LineF struct {
+	parent *Layer
+
+	// The end points as fixed-point numbers with 8 fractional bits, with the
+	// first point never to the right of the second point.
+	x1, y1, x2, y2 int32
+
+	color color.RGBA
+}
+
+// toQ8 converts a coordinate to a fixed-point number with 8 fractional bits,
+// rounding to the nearest value.
+func toQ8(x float32) int32 {
+	return int32(math.Floor(float64(x)*256 + 0.5))
+}
+
+// boundingBox returns the bounding box of this line, rounded outwards to whole
+// pixels. It includes the neighboring pixels that are painted for
+// anti-aliasing.
+func (l *LineF) boundingBox() (x1, y1, x2, y2 int16) {
+	minY, maxY := l.y1, l.y2
+	if minY > maxY {
+		minY, maxY = maxY, minY
+	}
+	return int16(l.x1 >> 8), int16(minY >> 8), int16(l.x2>>8) + 2, int16(maxY>>8) + 2
+}
+
+// contains returns whether the given point lies within the bounding box of this
+// line.
+func (l *LineF) contains(x, y int16) bool {
+	return boundingBoxContains(l, x, y)
+}
+
+// parentLayer returns the layer this object is part of.
+func (l *LineF) parentLayer() *Layer {
+	return l.parent
+}
+
+// setParent changes the layer this object is part of, without invalidating
+// anything.
+func (l *LineF) setParent(parent *Layer) {
+	l.parent = parent
+}
+
+// Move sets the new coordinates of this line. Like NewLineF, there is no
+// restriction on the order of the coordinates.
+func (l *LineF) Move(x1, y1, x2, y2 float32) {
+	l.invalidate()
+	l.setPoints(x1, y1, x2, y2)
+	l.invalidate()
+}
+
+// setPoints stores the given end points, swapping them if needed so that the
+// first point is never to the right of the second point.
+func (l *LineF) setPoints(x1, y1, x2, y2 float32) {
+	if x1 > x2 {
+		x1, x2 = x2, x1
+		y1, y2 = y2, y1
+	}
+	l.x1 = toQ8(x1)
+	l.y1 = toQ8(y1)
+	l.x2 = toQ8(x2)
+	l.y2 = toQ8(y2)
+}
+
+// SetColor updates the stroke color of this line.
+func (l *LineF) SetColor(c color.RGBA) {
+	l.color = c
+	l.invalidate()
+}
+
+// Remove removes this line from its parent layer. It must not be used
+// anymore afterwards.
+func (l *LineF) Remove() {
+	l.parent.Remove(l)
+}
+
+// invalidate marks the tiles under the bounding box of this line as needing to
+// be re-painted.
+func (l *LineF) invalidate() {
+	x1, y1, x2, y2 := l.boundingBox()
+	r := Rectangle{parent: l.parent}
+	r.invalidate(x1, y1, x2, y2)
+}
+
+// paint draws the line to the given tile at coordinates tileX and tileY.
+func (l *LineF) paint(t *tile, tileX, tileY int16) {
+	// Like Line, this uses Wu's algorithm, calculating the position on the
+	// minor axis from scratch for every pixel on the major axis. The only
+	// difference is that the positions are fixed-point numbers, and that the
+	// pixels at both ends are only partially covered.
+	dx := l.x2 - l.x1
+	dy := l.y2 - l.y1
+	if dy < 0 {
+		dy = -dy
+	}
+	if dx > dy {
+		// The line is more horizontal than vertical.
+		paintLineF(t, tileX, tileY, l.x1, l.y1, l.x2, l.y2, l.color, false)
+	} else {
+		// The line is more vertical than horizontal. Swap the axes, with
+		// point 2 below point 1.
+		x1, y1, x2, y2 := l.x1, l.y1, l.x2, l.y2
+		if y1 > y2 {
+			x1, y1, x2, y2 = x2, y2, x1, y1
+		}
+		paintLineF(t, tileY, tileX, y1, x1, y2, x2, l.color, true)
+	}
+}
+
+// paintLineF paints a mostly horizontal line between the given fixed-point
+// coordinates, with a1 <= a2. The a axis is the major axis (x for horizontal
+// lines) and the b axis the minor axis. When swapped is set, the a axis is the
+// y axis of the tile instead of the x axis, and tileA and tileB are swapped
+// accordingly.
+func paintLineF(t *tile, tileA, tileB int16, a1, b1, a2, b2 int32, c color.RGBA, swapped bool) {
+	// The size of the tile along the a and b axis.
+	sizeA, sizeB := t.width, t.height
+	if swapped {
+		sizeA, sizeB = sizeB, sizeA
+	}
+
+	// slopeQ16 is how far b is incremented each time a is incremented, as a
+	// fixed-point number with 16 fractional bits.
+	slopeQ16 := int64(0)
+	if a2 != a1 {
+		slopeQ16 = int64(b2-b1) << 16 / int64(a2-a1)
+	}
+
+	// All pixels that are at least partially covered by the line, limited to
+	// the tile.
+	start := int32(a1 >> 8)
+	end := (a2 + 255) >> 8
+	if start < int32(tileA) {
+		start = int32(tileA)
+	}
+	if end > int32(tileA)+int32(sizeA)-1 {
+		end = int32(tileA) + int32(sizeA) - 1
+	}
+	for a := start; a <= end; a++ {
+		// Each pixel covers a-0.5 to a+0.5, and the line covers a1-0.5 to
+		// a2+0.5. The overlap is how much of this pixel is covered.
+		weight := int32(256)
+		if over := a1 - a<<8; over > 0 {
+			weight -= over
+		}
+		if over := a<<8 - a2; over > 0 {
+			weight -= over
+		}
+		if weight <= 0 {
+			continue
+		}
+
+		// Split the pixel over the two closest pixels on the b axis. The end
+		// pixels are only partially covered, so calculate the position within
+		// the line to stay inside the bounding box.
+		pos := a << 8
+		if pos < a1 {
+			pos = a1
+		}
+		if pos > a2 {
+			pos = a2
+		}
+		bQ8 := b1 + int32((int64(pos-a1)*slopeQ16)>>16)
+		b := int16(bQ8>>8) - tileB
+		frac := bQ8 & 0xff
+		tilePos := int16(a) - tileA
+		for i, coverage := range [2]int32{255 - frac, frac} {
+			pixel := b + int16(i)
+			if pixel < 0 || pixel >= sizeB {
+				continue
+			}
+			alpha := uint8(coverage * weight >> 8)
+			if alpha == 0 {
+				continue
+			}
+			x, y := tilePos, pixel
+			if swapped {
+				x, y = pixel, tilePos
+			}
+			t.pixels[y*t.stride+x] = Blend(t.pixels[y*t.stride+x], ApplyAlpha(c, alpha))
+		}
+	}
+}
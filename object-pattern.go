@@ -0,0 +1,107 @@
+package tilegraphics
+
+import "image/color"
+
+// PatternRectangle is a rectangle filled with a checkerboard pattern of two
+// colors, for example to indicate transparency. The pattern starts at the top
+// left corner of the rectangle with the first color and moves along with it.
+// Both colors may be transparent.
+type PatternRectangle struct {
+	parent         *Layer
+	x1, y1, x2, y2 int16
+	colorA, colorB color.RGBA
+	cellSize       int16
+}
+
+// boundingBox returns the exact bounding box of the pattern.
+func (r *PatternRectangle) boundingBox() (x1, y1, x2, y2 int16) {
+	return r.x1, r.y1, r.x2, r.y2
+}
+
+// contains returns whether the given point lies within the bounding box of this
+// rectangle.
+func (r *PatternRectangle) contains(x, y int16) bool {
+	return boundingBoxContains(r, x, y)
+}
+
+// parentLayer returns the layer this object is part of.
+func (r *PatternRectangle) parentLayer() *Layer {
+	return r.parent
+}
+
+// setParent changes the layer this object is part of, without invalidating
+// anything.
+func (r *PatternRectangle) setParent(parent *Layer) {
+	r.parent = parent
+}
+
+// Move sets the new position and size of this pattern. The pattern moves along
+// with the top left corner, the cells keep their size.
+func (r *PatternRectangle) Move(x, y, width, height int16) {
+	r.invalidate()
+	r.x1 = x
+	r.y1 = y
+	r.x2 = x + width
+	r.y2 = y + height
+	r.invalidate()
+}
+
+// SetColors changes both colors of the checkerboard pattern.
+func (r *PatternRectangle) SetColors(colorA, colorB color.RGBA) {
+	r.colorA = colorA
+	r.colorB = colorB
+	r.invalidate()
+}
+
+// Remove removes this pattern from its parent layer. It must not be used
+// anymore afterwards.
+func (r *PatternRectangle) Remove() {
+	r.parent.Remove(r)
+}
+
+// invalidate marks the tiles under this pattern as needing to be re-painted.
+func (r *PatternRectangle) invalidate() {
+	rect := Rectangle{parent: r.parent}
+	rect.invalidate(r.x1, r.y1, r.x2, r.y2)
+}
+
+// paint draws the pattern to the given tile at coordinates tileX and tileY.
+func (r *PatternRectangle) paint(t *tile, tileX, tileY int16) {
+	x1 := r.x1 - tileX
+	y1 := r.y1 - tileY
+	x2 := r.x2 - tileX
+	y2 := r.y2 - tileY
+	if x1 < 0 {
+		x1 = 0
+	}
+	if y1 < 0 {
+		y1 = 0
+	}
+	if x2 > t.width {
+		x2 = t.width
+	}
+	if y2 > t.height {
+		y2 = t.height
+	}
+	cellSize := r.cellSize
+	if cellSize < 1 {
+		cellSize = 1
+	}
+	for y := y1; y < y2; y++ {
+		// The cells are counted from the top left corner of the rectangle, not
+		// from the tile, so that the pattern continues across tiles.
+		cellY := (y + tileY - r.y1) / cellSize
+		for x := x1; x < x2; x++ {
+			cellX := (x + tileX - r.x1) / cellSize
+			c := r.colorA
+			if (cellX+cellY)%2 != 0 {
+				c = r.colorB
+			}
+			if c.A == 255 {
+				t.pixels[y*t.stride+x] = c
+			} else {
+				t.pixels[y*t.stride+x] = Blend(t.pixels[y*t.stride+x], c)
+			}
+		}
+	}
+}
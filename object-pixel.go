@@ -0,0 +1,77 @@
+package tilegraphics
+
+import "image/color"
+
+// Pixel is a single pixel in a given color, for example a cursor or a single
+// point in a plot. It is cheaper to paint than a 1x1 Rectangle. It supports
+// transparency in the color.
+type Pixel struct {
+	parent *Layer
+	x, y   int16
+	color  color.RGBA
+}
+
+// boundingBox returns the 1x1 bounding box of this pixel.
+func (p *Pixel) boundingBox() (x1, y1, x2, y2 int16) {
+	return p.x, p.y, p.x + 1, p.y + 1
+}
+
+// contains returns whether the given point is this pixel.
+func (p *Pixel) contains(x, y int16) bool {
+	return x == p.x && y == p.y
+}
+
+// parentLayer returns the layer this object is part of.
+func (p *Pixel) parentLayer() *Layer {
+	return p.parent
+}
+
+// setParent changes the layer this object is part of, without invalidating
+// anything.
+func (p *Pixel) setParent(parent *Layer) {
+	p.parent = parent
+}
+
+// Move moves this pixel to the given coordinates.
+func (p *Pixel) Move(x, y int16) {
+	if x == p.x && y == p.y {
+		return
+	}
+	p.invalidate()
+	p.x = x
+	p.y = y
+	p.invalidate()
+}
+
+// SetColor updates the color of this pixel.
+func (p *Pixel) SetColor(c color.RGBA) {
+	p.color = c
+	p.invalidate()
+}
+
+// Remove removes this pixel from the parent layer. It must not be used anymore
+// afterwards.
+func (p *Pixel) Remove() {
+	p.parent.Remove(p)
+}
+
+// invalidate marks the tile under this pixel as needing to be re-painted.
+func (p *Pixel) invalidate() {
+	r := Rectangle{parent: p.parent}
+	r.invalidate(p.x, p.y, p.x+1, p.y+1)
+}
+
+// paint draws the pixel if it falls within the given tile at coordinates tileX
+// and tileY.
+func (p *Pixel) paint(t *tile, tileX, tileY int16) {
+	x := p.x - tileX
+	y := p.y - tileY
+	if x < 0 || y < 0 || x >= t.width || y >= t.height {
+		return
+	}
+	if p.color.A == 255 {
+		t.pixels[y*t.stride+x] = p.color
+	} else {
+		t.pixels[y*t.stride+x] = Blend(t.pixels[y*t.stride+x], p.color)
+	}
+}
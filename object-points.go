@@ -0,0 +1,86 @@
+package tilegraphics
+
+import (
+	"image"
+	"image/color"
+)
+
+// Points is a set of single pixels in the same color, like in a scatter plot.
+// It is a lot more efficient than separate 1x1 rectangles when drawing many
+// points.
+type Points struct {
+	parent *Layer
+	points []image.Point
+	color  color.RGBA
+}
+
+// boundingBox returns the bounding box of all points.
+func (p *Points) boundingBox() (x1, y1, x2, y2 int16) {
+	return pointsBoundingBox(p.points)
+}
+
+// contains returns whether the given point is one of the points in this set.
+func (p *Points) contains(x, y int16) bool {
+	for _, point := range p.points {
+		if int16(point.X) == x && int16(point.Y) == y {
+			return true
+		}
+	}
+	return false
+}
+
+// parentLayer returns the layer this object is part of.
+func (p *Points) parentLayer() *Layer {
+	return p.parent
+}
+
+// setParent changes the layer this object is part of, without invalidating
+// anything.
+func (p *Points) setParent(parent *Layer) {
+	p.parent = parent
+}
+
+// AddPoint adds a single point to the set. Only the tile under the new point
+// needs to be redrawn.
+func (p *Points) AddPoint(x, y int16) {
+	p.points = append(p.points, image.Point{int(x), int(y)})
+	r := Rectangle{parent: p.parent}
+	r.invalidate(x, y, x+1, y+1)
+}
+
+// SetColor updates the color of all points.
+func (p *Points) SetColor(c color.RGBA) {
+	p.color = c
+	p.invalidate()
+}
+
+// Remove removes these points from the parent layer. It must not be used
+// anymore afterwards.
+func (p *Points) Remove() {
+	p.parent.Remove(p)
+}
+
+// invalidate marks the tiles under the bounding box of all points as needing to
+// be re-painted.
+func (p *Points) invalidate() {
+	x1, y1, x2, y2 := p.boundingBox()
+	r := Rectangle{parent: p.parent}
+	r.invalidate(x1, y1, x2, y2)
+}
+
+// paint draws all points that fall within the given tile at coordinates tileX
+// and tileY.
+func (p *Points) paint(t *tile, tileX, tileY int16) {
+	for _, point := range p.points {
+		x := int16(point.X) - tileX
+		y := int16(point.Y) - tileY
+		if x < 0 || y < 0 || x >= t.width || y >= t.height {
+			continue
+		}
+		if p.color.A == 255 {
+			t.pixels[y*t.stride+x] = p.color
+		} else {
+			t.pixels[y*t.stride+x] = Blend(t.pixels[y*t.stride+x], p.color)
+		}
+	}
+}
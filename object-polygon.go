@@ -0,0 +1,73 @@
+package tilegraphics
+
+import (
+	"image"
+	"image/color"
+)
+
+// Polygon is a filled convex polygon with anti-aliased edges, such as a hexagon
+// or an octagon. It supports transparency in the fill color.
+//
+// The polygon must be convex: every line between two corners must lie within
+// the polygon. Concave polygons are not drawn correctly (they are drawn as if
+// the concave parts are cut off), use multiple polygons or triangles for them.
+type Polygon struct {
+	parent *Layer
+	points []image.Point
+	color  color.RGBA
+}
+
+// boundingBox returns the bounding box of all corners of this polygon.
+func (p *Polygon) boundingBox() (x1, y1, x2, y2 int16) {
+	return pointsBoundingBox(p.points)
+}
+
+// contains returns whether the given point lies within the bounding box of this
+// polygon.
+func (p *Polygon) contains(x, y int16) bool {
+	return boundingBoxContains(p, x, y)
+}
+
+// parentLayer returns the layer this object is part of.
+func (p *Polygon) parentLayer() *Layer {
+	return p.parent
+}
+
+// setParent changes the layer this object is part of, without invalidating
+// anything.
+func (p *Polygon) setParent(parent *Layer) {
+	p.parent = parent
+}
+
+// SetPoints replaces the corners of this polygon. The slice is used directly,
+// so it must not be modified afterwards.
+func (p *Polygon) SetPoints(points []image.Point) {
+	p.invalidate()
+	p.points = points
+	p.invalidate()
+}
+
+// SetColor updates the fill color of this polygon.
+func (p *Polygon) SetColor(c color.RGBA) {
+	p.color = c
+	p.invalidate()
+}
+
+// Remove removes this polygon from its parent layer. It must not be used
+// anymore afterwards.
+func (p *Polygon) Remove() {
+	p.parent.Remove(p)
+}
+
+// invalidate marks the tiles under the bounding box of this polygon as needing
+// to be re-painted.
+func (p *Polygon) invalidate() {
+	x1, y1, x2, y2 := p.boundingBox()
+	r := Rectangle{parent: p.parent}
+	r.invalidate(x1, y1, x2, y2)
+}
+
+// paint draws the polygon to the given tile at coordinates tileX and tileY.
+func (p *Polygon) paint(t *tile, tileX, tileY int16) {
+	paintConvexPolygon(t, tileX, tileY, p.points, p.color)
+}
@@ -0,0 +1,89 @@
+package tilegraphics
+
+import (
+	"image"
+	"image/color"
+)
+
+// Polyline is an open path of anti-aliased lines, connecting each point to the
+// next. It is a lot more efficient than separate Line objects when drawing a
+// long sequence of connected lines, like a chart.
+//
+// Points where two lines meet are painted twice, which is visible when the
+// stroke color is transparent.
+type Polyline struct {
+	parent *Layer
+	points []image.Point
+	color  color.RGBA
+}
+
+// boundingBox returns the bounding box of all points in this polyline.
+func (p *Polyline) boundingBox() (x1, y1, x2, y2 int16) {
+	return pointsBoundingBox(p.points)
+}
+
+// contains returns whether the given point lies within the bounding box of this
+// polyline.
+func (p *Polyline) contains(x, y int16) bool {
+	return boundingBoxContains(p, x, y)
+}
+
+// parentLayer returns the layer this object is part of.
+func (p *Polyline) parentLayer() *Layer {
+	return p.parent
+}
+
+// setParent changes the layer this object is part of, without invalidating
+// anything.
+func (p *Polyline) setParent(parent *Layer) {
+	p.parent = parent
+}
+
+// SetPoints replaces the points of this polyline. The slice is used directly,
+// so it must not be modified afterwards.
+func (p *Polyline) SetPoints(points []image.Point) {
+	p.invalidate()
+	p.points = points
+	p.invalidate()
+}
+
+// SetColor updates the stroke color of this polyline.
+func (p *Polyline) SetColor(c color.RGBA) {
+	p.color = c
+	p.invalidate()
+}
+
+// Remove removes this polyline from its parent layer. It must not be used
+// anymore afterwards.
+func (p *Polyline) Remove() {
+	p.parent.Remove(p)
+}
+
+// invalidate marks the tiles under the bounding box of this polyline as needing
+// to be re-painted.
+func (p *Polyline) invalidate() {
+	x1, y1, x2, y2 := p.boundingBox()
+	r := Rectangle{parent: p.parent}
+	r.invalidate(x1, y1, x2, y2)
+}
+
+// paint draws all lines of this polyline that pass over the given tile at
+// coordinates tileX and tileY.
+func (p *Polyline) paint(t *tile, tileX, tileY int16) {
+	paintPolyline(t, tileX, tileY, p.points, p.color)
+}
+
+// paintPolyline paints anti-aliased lines between each point and the next to
+// the given tile, skipping the lines that don't pass over the tile.
+func paintPolyline(t *tile, tileX, tileY int16, points []image.Point, c color.RGBA) {
+	for i := 1; i < len(points); i++ {
+		x1, y1, x2, y2 := pointsBoundingBox(points[i-1 : i+1])
+		if x1 >= tileX+t.width || y1 >= tileY+t.height || x2 <= tileX || y2 <= tileY {
+			// This line doesn't pass over the tile.
+			continue
+		}
+		a := points[i-1]
+		b := points[i]
+		paintLineSegment(t, tileX, tileY, int16(a.X), int16(a.Y), int16(b.X), int16(b.Y), c, nil, true)
+	}
+}
@@ -0,0 +1,105 @@
+package tilegraphics
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// QuadBezier is an anti-aliased quadratic Bézier curve, like the Q command in
+// SVG paths. It is drawn as a sequence of short lines that are close enough to
+// the real curve to be indistinguishable from it. It supports transparency in
+// the color, but like with Polyline the points where two of those lines meet
+// are painted twice.
+type QuadBezier struct {
+	parent   *Layer
+	controls [3]image.Point // start point, control point, end point
+	points   []image.Point  // the flattened curve
+	color    color.RGBA
+}
+
+// boundingBox returns the bounding box of the start, control and end point.
+// The curve never leaves the triangle formed by those points, so this is a
+// (slightly too big) bounding box of the curve.
+func (b *QuadBezier) boundingBox() (x1, y1, x2, y2 int16) {
+	return pointsBoundingBox(b.controls[:])
+}
+
+// contains returns whether the given point lies within the bounding box of this
+// curve.
+func (b *QuadBezier) contains(x, y int16) bool {
+	return boundingBoxContains(b, x, y)
+}
+
+// parentLayer returns the layer this object is part of.
+func (b *QuadBezier) parentLayer() *Layer {
+	return b.parent
+}
+
+// setParent changes the layer this object is part of, without invalidating
+// anything.
+func (b *QuadBezier) setParent(parent *Layer) {
+	b.parent = parent
+}
+
+// SetPoints changes the start point (x0, y0), the control point (cx, cy) and
+// the end point (x1, y1) of this curve.
+func (b *QuadBezier) SetPoints(x0, y0, cx, cy, x1, y1 int16) {
+	b.invalidate()
+	b.setPoints(x0, y0, cx, cy, x1, y1)
+	b.invalidate()
+}
+
+// setPoints stores the given points and flattens the curve into lines. The
+// number of lines is chosen so that no line deviates more than a quarter pixel
+// from the real curve: with n lines, the maximum deviation of a quadratic curve
+// is |p0 - 2*c + p1| / (4*n*n).
+func (b *QuadBezier) setPoints(x0, y0, cx, cy, x1, y1 int16) {
+	b.controls = [3]image.Point{{int(x0), int(y0)}, {int(cx), int(cy)}, {int(x1), int(y1)}}
+	ddx := float64(x0) - 2*float64(cx) + float64(x1)
+	ddy := float64(y0) - 2*float64(cy) + float64(y1)
+	n := int(math.Ceil(math.Sqrt(math.Sqrt(ddx*ddx + ddy*ddy))))
+	if n < 1 {
+		n = 1
+	}
+
+	b.points = append(b.points[:0], b.controls[0])
+	for i := 1; i <= n; i++ {
+		t := float64(i) / float64(n)
+		u := 1 - t
+		x := u*u*float64(x0) + 2*u*t*float64(cx) + t*t*float64(x1)
+		y := u*u*float64(y0) + 2*u*t*float64(cy) + t*t*float64(y1)
+		p := image.Point{int(math.Floor(x + 0.5)), int(math.Floor(y + 0.5))}
+		if p == b.points[len(b.points)-1] {
+			// Avoid painting the same pixel twice.
+			continue
+		}
+		b.points = append(b.points, p)
+	}
+}
+
+// SetColor updates the stroke color of this curve.
+func (b *QuadBezier) SetColor(c color.RGBA) {
+	b.color = c
+	b.invalidate()
+}
+
+// Remove removes this curve from its parent layer. It must not be used anymore
+// afterwards.
+func (b *QuadBezier) Remove() {
+	b.parent.Remove(b)
+}
+
+// invalidate marks the tiles under the bounding box of this curve as needing to
+// be re-painted.
+func (b *QuadBezier) invalidate() {
+	x1, y1, x2, y2 := b.boundingBox()
+	r := Rectangle{parent: b.parent}
+	r.invalidate(x1, y1, x2, y2)
+}
+
+// paint draws the lines of this curve that pass over the given tile at
+// coordinates tileX and tileY.
+func (b *QuadBezier) paint(t *tile, tileX, tileY int16) {
+	paintPolyline(t, tileX, tileY, b.points, b.color)
+}
@@ -0,0 +1,81 @@
+package tilegraphics
+
+import (
+	"image/color"
+	"math"
+)
+
+// RadialLine is a line that starts at a pivot point and points in a given
+// direction, like the hand of an analog clock. It is drawn like a Line, with
+// the same support for transparency and stroke widths.
+type RadialLine struct {
+	line   Line
+	cx, cy int16
+	length int16
+	angle  float32
+}
+
+// boundingBox returns the bounding box of the line.
+func (r *RadialLine) boundingBox() (x1, y1, x2, y2 int16) {
+	return r.line.boundingBox()
+}
+
+// contains returns whether the given point lies on the line.
+func (r *RadialLine) contains(x, y int16) bool {
+	return r.line.contains(x, y)
+}
+
+// parentLayer returns the layer this object is part of.
+func (r *RadialLine) parentLayer() *Layer {
+	return r.line.parent
+}
+
+// setParent changes the layer this object is part of, without invalidating
+// anything.
+func (r *RadialLine) setParent(parent *Layer) {
+	r.line.parent = parent
+}
+
+// endPoint returns the end point of the line for the current pivot, length and
+// angle, rounded to the nearest pixel.
+func (r *RadialLine) endPoint() (x, y int16) {
+	sin, cos := math.Sincos(float64(r.angle))
+	x = r.cx + int16(math.Floor(float64(r.length)*cos+0.5))
+	y = r.cy + int16(math.Floor(float64(r.length)*sin+0.5))
+	return x, y
+}
+
+// Angle returns the current angle of the line in radians, see SetAngle.
+func (r *RadialLine) Angle() float32 {
+	return r.angle
+}
+
+// SetAngle changes the direction in which the line points from the pivot, in
+// radians. Like with Line.Angle, an angle of 0 points to the right and positive
+// angles turn clockwise: the 12 o'clock position of a clock is at -π/2.
+func (r *RadialLine) SetAngle(angle float32) {
+	r.angle = angle
+	x, y := r.endPoint()
+	r.line.Move(r.cx, r.cy, x, y)
+}
+
+// SetColor updates the stroke color of this line.
+func (r *RadialLine) SetColor(c color.RGBA) {
+	r.line.SetColor(c)
+}
+
+// SetWidth changes the stroke width of this line, see Line.SetWidth.
+func (r *RadialLine) SetWidth(width int16) {
+	r.line.SetWidth(width)
+}
+
+// Remove removes this line from its parent layer. It must not be used anymore
+// afterwards.
+func (r *RadialLine) Remove() {
+	r.line.parent.Remove(r)
+}
+
+// paint draws the line to the given tile at coordinates tileX and tileY.
+func (r *RadialLine) paint(t *tile, tileX, tileY int16) {
+	r.line.paint(t, tileX, tileY)
+}
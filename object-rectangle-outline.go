@@ -0,0 +1,99 @@
+package tilegraphics
+
+import "image/color"
+
+// RectangleOutline is the border of a rectangle, drawn with a given thickness
+// on the inside of the rectangle. It supports transparency in the color.
+type RectangleOutline struct {
+	parent         *Layer
+	x1, y1, x2, y2 int16
+	thickness      int16
+	color          color.RGBA
+}
+
+// boundingBox returns the exact bounding box of the outline, which is the
+// outer rectangle.
+func (r *RectangleOutline) boundingBox() (x1, y1, x2, y2 int16) {
+	return r.x1, r.y1, r.x2, r.y2
+}
+
+// contains returns whether the given point lies within the bounding box of this
+// rectangle.
+func (r *RectangleOutline) contains(x, y int16) bool {
+	return boundingBoxContains(r, x, y)
+}
+
+// parentLayer returns the layer this object is part of.
+func (r *RectangleOutline) parentLayer() *Layer {
+	return r.parent
+}
+
+// setParent changes the layer this object is part of, without invalidating
+// anything.
+func (r *RectangleOutline) setParent(parent *Layer) {
+	r.parent = parent
+}
+
+// Move sets the new position and size of this outline.
+func (r *RectangleOutline) Move(x, y, width, height int16) {
+	r.invalidate()
+	r.x1 = x
+	r.y1 = y
+	r.x2 = x + width
+	r.y2 = y + height
+	r.invalidate()
+}
+
+// Remove removes this outline from its parent layer. It must not be used
+// anymore afterwards.
+func (r *RectangleOutline) Remove() {
+	r.parent.Remove(r)
+}
+
+// invalidate marks the tiles under this outline as needing to be re-painted.
+func (r *RectangleOutline) invalidate() {
+	rect := Rectangle{parent: r.parent}
+	rect.invalidate(r.x1, r.y1, r.x2, r.y2)
+}
+
+// paint draws the outline to the given tile at coordinates tileX and tileY.
+func (r *RectangleOutline) paint(t *tile, tileX, tileY int16) {
+	x1 := r.x1 - tileX
+	y1 := r.y1 - tileY
+	x2 := r.x2 - tileX
+	y2 := r.y2 - tileY
+
+	// The inner rectangle, which is not painted.
+	innerX1 := x1 + r.thickness
+	innerY1 := y1 + r.thickness
+	innerX2 := x2 - r.thickness
+	innerY2 := y2 - r.thickness
+
+	if x1 < 0 {
+		x1 = 0
+	}
+	if y1 < 0 {
+		y1 = 0
+	}
+	if x2 > t.width {
+		x2 = t.width
+	}
+	if y2 > t.height {
+		y2 = t.height
+	}
+	for y := y1; y < y2; y++ {
+		insideRow := y >= innerY1 && y < innerY2
+		for x := x1; x < x2; x++ {
+			if insideRow && x >= innerX1 && x < innerX2 {
+				// Skip the inside of the rectangle.
+				x = innerX2 - 1
+				continue
+			}
+			if r.color.A == 255 {
+				t.pixels[y*t.stride+x] = r.color
+			} else {
+				t.pixels[y*t.stride+x] = Blend(t.pixels[y*t.stride+x], r.color)
+			}
+		}
+	}
+}
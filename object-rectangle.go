@@ -8,6 +8,7 @@ type Rectangle struct {
 	parent         *Layer // nil for the root
 	x1, y1, x2, y2 int16
 	color          color.RGBA
+	opacity        uint8 // multiplied with the color alpha while painting
 }
 
 // boundingBox returns the exact bounding box of the rectangle.
@@ -15,6 +16,23 @@ func (r *Rectangle) boundingBox() (x1, y1, x2, y2 int16) {
 	return r.x1, r.y1, r.x2, r.y2
 }
 
+// contains returns whether the given point lies within the bounding box of this
+// rectangle.
+func (r *Rectangle) contains(x, y int16) bool {
+	return boundingBoxContains(r, x, y)
+}
+
+// parentLayer returns the layer this object is part of.
+func (r *Rectangle) parentLayer() *Layer {
+	return r.parent
+}
+
+// setParent changes the layer this object is part of, without invalidating
+// anything.
+func (r *Rectangle) setParent(parent *Layer) {
+	r.parent = parent
+}
+
 // Move sets the new position and size of this rectangle.
 func (r *Rectangle) Move(x, y, width, height int16) {
 	newX1 := x
@@ -80,6 +98,50 @@ func (r *Rectangle) Move(x, y, width, height int16) {
 	r.y2 = newY2
 }
 
+// MoveBy moves this rectangle by the given offset, keeping its size.
+func (r *Rectangle) MoveBy(dx, dy int16) {
+	r.Move(r.x1+dx, r.y1+dy, r.x2-r.x1, r.y2-r.y1)
+}
+
+// Resize changes the size of this rectangle, keeping the top left corner in
+// place. Only the areas along the edges that changed are redrawn.
+func (r *Rectangle) Resize(width, height int16) {
+	r.Move(r.x1, r.y1, width, height)
+}
+
+// ScaleAboutCenter changes the size of this rectangle, keeping it centered at
+// the same position. An odd width or height is centered on the center pixel,
+// an even one on the left or top edge of that pixel. This keeps the center
+// stable during an animation, so that scaling back to the original size
+// results in the original rectangle.
+func (r *Rectangle) ScaleAboutCenter(width, height int16) {
+	cx := (r.x1 + r.x2) >> 1
+	cy := (r.y1 + r.y2) >> 1
+	r.Move(cx-width>>1, cy-height>>1, width, height)
+}
+
+// SetColor updates the fill color of this rectangle, without changing its
+// position or size.
+func (r *Rectangle) SetColor(c color.RGBA) {
+	r.color = c
+	r.invalidate(r.x1, r.y1, r.x2, r.y2)
+}
+
+// SetOpacity changes the opacity of this rectangle, without changing its color.
+// The color is multiplied with the opacity while painting, so that an opacity
+// of 255 draws the color as-is and an opacity of 0 doesn't draw anything. This
+// is useful for fading a rectangle in or out.
+func (r *Rectangle) SetOpacity(opacity uint8) {
+	r.opacity = opacity
+	r.invalidate(r.x1, r.y1, r.x2, r.y2)
+}
+
+// Remove removes this rectangle from its parent layer. It must not be used
+// anymore afterwards.
+func (r *Rectangle) Remove() {
+	r.parent.Remove(r)
+}
+
 // invalidateMiddleBlock invalidates an area where the two X coordinates might
 // be swapped.
 func (r *Rectangle) invalidateMiddleBlock(xA, maxY1, xB, minY2 int16) {
@@ -91,12 +153,57 @@ func (r *Rectangle) invalidateMiddleBlock(xA, maxY1, xB, minY2 int16) {
 
 // invalidate invalidates all tiles currently under the rectangle.
 func (r *Rectangle) invalidate(x1, y1, x2, y2 int16) {
-	x, y := r.absolutePos(x1, y1)
+	layer := r.parent
+	if &layer.rect == r {
+		// This is the rectangle of a layer itself, so the coordinates are
+		// relative to the parent layer.
+		layer = layer.parent
+	}
+
+	// The contents of the layer (and all layers around it) changed, so
+	// flattened layers need to be painted again.
+	root := r.parent
+	for l := layer; l != nil; l = l.parent {
+		l.cacheValid = false
+		root = l
+	}
+
+	if root != r.parent.engine.root {
+		// Part of a scene that isn't currently shown.
+		return
+	}
+	r.invalidateScreen(layer, x1, y1, x2, y2)
+}
+
+// invalidateScreen marks the tiles on the screen under the given area relative
+// to the given layer as needing an update. Layers that wrap around also draw
+// the area at their opposite edges, so those copies are marked as well, for
+// every wrapping layer on the way up to the screen.
+func (r *Rectangle) invalidateScreen(layer *Layer, x1, y1, x2, y2 int16) {
+	for ; layer != nil; layer = layer.parent {
+		if layer.wrap {
+			offsetsX, offsetsY, n := layer.wrapOffsets()
+			for _, dy := range offsetsY[:n] {
+				for _, dx := range offsetsX[:n] {
+					wx1, wy1, wx2, wy2 := layer.toParent(x1+dx, y1+dy, x2+dx, y2+dy)
+					r.invalidateScreen(layer.parent, wx1, wy1, wx2, wy2)
+				}
+			}
+			return
+		}
+		x1, y1, x2, y2 = layer.toParent(x1, y1, x2, y2)
+	}
+	if x1 >= x2 || y1 >= y2 {
+		// Nothing visible to invalidate.
+		return
+	}
+
 	// Calculate tile grid indices.
-	tileX1 := x / TileSize
-	tileY1 := y / TileSize
-	tileX2 := (x + (x2 - x1) + TileSize) / TileSize
-	tileY2 := (y + (y2 - y1) + TileSize) / TileSize
+	tileSize := r.parent.engine.tileSize
+	tileX1 := x1 / tileSize
+	tileY1 := y1 / tileSize
+	tileX2 := (x2 + tileSize - 1) / tileSize
+	tileY2 := (y2 + tileSize - 1) / tileSize
 
 	// Limit the tile grid indices to the screen.
 	if tileY1 < 0 {
@@ -121,8 +228,38 @@ func (r *Rectangle) invalidate(x1, y1, x2, y2 int16) {
 	}
 }
 
+// screenArea converts the given area relative to the parent layer to screen
+// coordinates, clipped to all layers it is in. It also returns whether any of it
+// is visible: the area may fall outside of the screen or a layer, or be part of
+// a scene that isn't currently shown.
+func (r *Rectangle) screenArea(x1, y1, x2, y2 int16) (int16, int16, int16, int16, bool) {
+	layer := r.parent
+	if &layer.rect == r {
+		layer = layer.parent
+	}
+
+	// Convert the coordinates to screen coordinates, one layer at a time.
+	// Copies drawn by wrapping layers are ignored here, see invalidateScreen.
+	root := r.parent
+	for layer != nil {
+		x1, y1, x2, y2 = layer.toParent(x1, y1, x2, y2)
+		root = layer
+		layer = layer.parent
+	}
+	visible := x1 < x2 && y1 < y2 && root == r.parent.engine.root
+	return x1, y1, x2, y2, visible
+}
+
 // paint draws the rectangle to the given tile at coordinates tileX and tileY.
 func (r *Rectangle) paint(t *tile, tileX, tileY int16) {
+	if r.opacity == 0 {
+		// Fully transparent, nothing to draw.
+		return
+	}
+	c := r.color
+	if r.opacity != 255 {
+		c = ApplyAlpha(c, r.opacity)
+	}
 	x1 := r.x1 - tileX
 	y1 := r.y1 - tileY
 	x2 := r.x2 - tileX
@@ -133,24 +270,24 @@ func (r *Rectangle) paint(t *tile, tileX, tileY int16) {
 	if y1 < 0 {
 		y1 = 0
 	}
-	if x2 > TileSize {
-		x2 = TileSize
+	if x2 > t.width {
+		x2 = t.width
 	}
-	if y2 > TileSize {
-		y2 = TileSize
+	if y2 > t.height {
+		y2 = t.height
 	}
-	if r.color.A == 255 {
+	if c.A == 255 {
 		// Fill without blending, because the rectangle is not transparent.
 		for x := x1; x < x2; x++ {
 			for y := y1; y < y2; y++ {
-				t[x+y*TileSize] = r.color
+				t.pixels[x+y*t.stride] = c
 			}
 		}
 	} else {
 		// Blend with the background (slow path).
 		for x := x1; x < x2; x++ {
 			for y := y1; y < y2; y++ {
-				t[x+y*TileSize] = Blend(t[x+y*TileSize], r.color)
+				t.pixels[x+y*t.stride] = Blend(t.pixels[x+y*t.stride], c)
 			}
 		}
 	}
@@ -163,8 +300,8 @@ func (r *Rectangle) absolutePos(x, y int16) (int16, int16) {
 		layer = layer.parent
 	}
 	for layer != nil {
-		x += layer.rect.x1
-		y += layer.rect.y1
+		x += layer.rect.x1 - layer.scrollX
+		y += layer.rect.y1 - layer.scrollY
 		layer = layer.parent
 	}
 	return x, y
@@ -0,0 +1,134 @@
+package tilegraphics
+
+import "image/color"
+
+// RoundedRectangle is a filled rectangle with rounded, anti-aliased corners. It
+// supports transparency in the fill color.
+type RoundedRectangle struct {
+	parent         *Layer
+	x1, y1, x2, y2 int16
+	radius         int16
+	color          color.RGBA
+}
+
+// boundingBox returns the exact bounding box of the rectangle.
+func (r *RoundedRectangle) boundingBox() (x1, y1, x2, y2 int16) {
+	return r.x1, r.y1, r.x2, r.y2
+}
+
+// contains returns whether the given point lies within the bounding box of this
+// rectangle.
+func (r *RoundedRectangle) contains(x, y int16) bool {
+	return boundingBoxContains(r, x, y)
+}
+
+// parentLayer returns the layer this object is part of.
+func (r *RoundedRectangle) parentLayer() *Layer {
+	return r.parent
+}
+
+// setParent changes the layer this object is part of, without invalidating
+// anything.
+func (r *RoundedRectangle) setParent(parent *Layer) {
+	r.parent = parent
+}
+
+// Move sets the new position and size of this rectangle. The corner radius is
+// reduced if it doesn't fit anymore.
+func (r *RoundedRectangle) Move(x, y, width, height int16) {
+	r.invalidate()
+	r.x1 = x
+	r.y1 = y
+	r.x2 = x + width
+	r.y2 = y + height
+	r.setRadius(r.radius)
+	r.invalidate()
+}
+
+// Remove removes this rectangle from its parent layer. It must not be used
+// anymore afterwards.
+func (r *RoundedRectangle) Remove() {
+	r.parent.Remove(r)
+}
+
+// setRadius sets the corner radius, limiting it to half the width or height
+// (whichever is smaller).
+func (r *RoundedRectangle) setRadius(radius int16) {
+	if max := (r.x2 - r.x1) / 2; radius > max {
+		radius = max
+	}
+	if max := (r.y2 - r.y1) / 2; radius > max {
+		radius = max
+	}
+	if radius < 0 {
+		radius = 0
+	}
+	r.radius = radius
+}
+
+// invalidate marks the tiles under this rectangle as needing to be re-painted.
+func (r *RoundedRectangle) invalidate() {
+	rect := Rectangle{parent: r.parent}
+	rect.invalidate(r.x1, r.y1, r.x2, r.y2)
+}
+
+// paint draws the rectangle to the given tile at coordinates tileX and tileY.
+func (r *RoundedRectangle) paint(t *tile, tileX, tileY int16) {
+	x1 := r.x1 - tileX
+	y1 := r.y1 - tileY
+	x2 := r.x2 - tileX
+	y2 := r.y2 - tileY
+	if x1 < 0 {
+		x1 = 0
+	}
+	if y1 < 0 {
+		y1 = 0
+	}
+	if x2 > t.width {
+		x2 = t.width
+	}
+	if y2 > t.height {
+		y2 = t.height
+	}
+	for y := y1; y < y2; y++ {
+		// Vertical distance from the center of the nearest corner circle, in
+		// half pixels (so that pixel centers are at whole numbers).
+		dy := int32(0)
+		if py := y + tileY; py < r.y1+r.radius {
+			dy = int32(2*(r.y1+r.radius) - (2*py + 1))
+		} else if py >= r.y2-r.radius {
+			dy = int32(2*py + 1 - 2*(r.y2-r.radius))
+		}
+		for x := x1; x < x2; x++ {
+			dx := int32(0)
+			if px := x + tileX; px < r.x1+r.radius {
+				dx = int32(2*(r.x1+r.radius) - (2*px + 1))
+			} else if px >= r.x2-r.radius {
+				dx = int32(2*px + 1 - 2*(r.x2-r.radius))
+			}
+
+			coverage := int32(255)
+			if dx != 0 && dy != 0 {
+				// The pixel is in one of the corners. Calculate how far
+				// the pixel center lies within the corner circle.
+				distQ8 := int32(sqrtQ8(uint32(dx*dx+dy*dy))) / 2
+				coverage = int32(r.radius)*256 + 128 - distQ8
+				if coverage <= 0 {
+					continue
+				}
+			}
+
+			index := y*t.stride + x
+			if coverage >= 255 {
+				if r.color.A == 255 {
+					// Fast path, directly painting the color into the tile.
+					t.pixels[index] = r.color
+				} else {
+					t.pixels[index] = Blend(t.pixels[index], r.color)
+				}
+				continue
+			}
+			t.pixels[index] = Blend(t.pixels[index], ApplyAlpha(r.color, uint8(coverage)))
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package tilegraphics
+
+import "image/color"
+
+// Rule is a horizontal or vertical line of a single pixel wide that always
+// spans the whole width or height of its layer, even when the layer is resized.
+// It is useful as a separator.
+type Rule struct {
+	parent   *Layer
+	pos      int16
+	vertical bool
+	color    color.RGBA
+}
+
+// boundingBox returns the exact bounding box of this rule, which depends on the
+// current size and scroll offset of the parent layer.
+func (r *Rule) boundingBox() (x1, y1, x2, y2 int16) {
+	width := r.parent.rect.x2 - r.parent.rect.x1
+	height := r.parent.rect.y2 - r.parent.rect.y1
+	if r.vertical {
+		return r.pos, r.parent.scrollY, r.pos + 1, r.parent.scrollY + height
+	}
+	return r.parent.scrollX, r.pos, r.parent.scrollX + width, r.pos + 1
+}
+
+// contains returns whether the given point lies on this rule.
+func (r *Rule) contains(x, y int16) bool {
+	return boundingBoxContains(r, x, y)
+}
+
+// parentLayer returns the layer this object is part of.
+func (r *Rule) parentLayer() *Layer {
+	return r.parent
+}
+
+// setParent changes the layer this object is part of, without invalidating
+// anything.
+func (r *Rule) setParent(parent *Layer) {
+	r.parent = parent
+}
+
+// Move sets the new position of this rule: the y coordinate for horizontal
+// rules and the x coordinate for vertical rules.
+func (r *Rule) Move(pos int16) {
+	r.invalidate()
+	r.pos = pos
+	r.invalidate()
+}
+
+// SetColor updates the color of this rule.
+func (r *Rule) SetColor(c color.RGBA) {
+	r.color = c
+	r.invalidate()
+}
+
+// Remove removes this rule from its parent layer. It must not be used anymore
+// afterwards.
+func (r *Rule) Remove() {
+	r.parent.Remove(r)
+}
+
+// invalidate marks the tiles under this rule as needing to be re-painted.
+func (r *Rule) invalidate() {
+	x1, y1, x2, y2 := r.boundingBox()
+	rect := Rectangle{parent: r.parent}
+	rect.invalidate(x1, y1, x2, y2)
+}
+
+// paint draws the rule to the given tile at coordinates tileX and tileY.
+func (r *Rule) paint(t *tile, tileX, tileY int16) {
+	x1, y1, x2, y2 := r.boundingBox()
+	x1 -= tileX
+	y1 -= tileY
+	x2 -= tileX
+	y2 -= tileY
+	if x1 < 0 {
+		x1 = 0
+	}
+	if y1 < 0 {
+		y1 = 0
+	}
+	if x2 > t.width {
+		x2 = t.width
+	}
+	if y2 > t.height {
+		y2 = t.height
+	}
+	for y := y1; y < y2; y++ {
+		for x := x1; x < x2; x++ {
+			if r.color.A == 255 {
+				t.pixels[y*t.stride+x] = r.color
+			} else {
+				t.pixels[y*t.stride+x] = Blend(t.pixels[y*t.stride+x], r.color)
+			}
+		}
+	}
+}
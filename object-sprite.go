@@ -0,0 +1,108 @@
+package tilegraphics
+
+import (
+	"image"
+	"image/color"
+)
+
+// Sprite is a bitmap image drawn at a given position. Transparent pixels in the
+// image are blended with the background.
+type Sprite struct {
+	parent *Layer
+	x, y   int16
+	img    image.Image
+}
+
+// boundingBox returns the exact bounding box of this sprite.
+func (s *Sprite) boundingBox() (x1, y1, x2, y2 int16) {
+	size := s.img.Bounds().Size()
+	return s.x, s.y, s.x + int16(size.X), s.y + int16(size.Y)
+}
+
+// contains returns whether the given point lies within the bounding box of this
+// sprite.
+func (s *Sprite) contains(x, y int16) bool {
+	return boundingBoxContains(s, x, y)
+}
+
+// parentLayer returns the layer this object is part of.
+func (s *Sprite) parentLayer() *Layer {
+	return s.parent
+}
+
+// setParent changes the layer this object is part of, without invalidating
+// anything.
+func (s *Sprite) setParent(parent *Layer) {
+	s.parent = parent
+}
+
+// Move sets the new position of the top left corner of this sprite.
+func (s *Sprite) Move(x, y int16) {
+	s.invalidate()
+	s.x = x
+	s.y = y
+	s.invalidate()
+}
+
+// Remove removes this sprite from its parent layer. It must not be used
+// anymore afterwards.
+func (s *Sprite) Remove() {
+	s.parent.Remove(s)
+}
+
+// invalidate marks the tiles under this sprite as needing to be re-painted.
+func (s *Sprite) invalidate() {
+	x1, y1, x2, y2 := s.boundingBox()
+	r := Rectangle{parent: s.parent}
+	r.invalidate(x1, y1, x2, y2)
+}
+
+// imageColorAt returns the color of the image at the given coordinates relative
+// to the top left corner of the image.
+func imageColorAt(img image.Image, x, y int) color.RGBA {
+	min := img.Bounds().Min
+	switch img := img.(type) {
+	case *image.RGBA:
+		// Fast path for the most common image types, avoiding an allocation.
+		return img.RGBAAt(min.X+x, min.Y+y)
+	case *image.NRGBA:
+		c := img.NRGBAAt(min.X+x, min.Y+y)
+		return premultiply(c.R, c.G, c.B, c.A)
+	default:
+		return ConvertColor(img.At(min.X+x, min.Y+y))
+	}
+}
+
+// paint draws the sprite to the given tile at coordinates tileX and tileY.
+func (s *Sprite) paint(t *tile, tileX, tileY int16) {
+	x1, y1, x2, y2 := s.boundingBox()
+	x1 -= tileX
+	y1 -= tileY
+	x2 -= tileX
+	y2 -= tileY
+	if x1 < 0 {
+		x1 = 0
+	}
+	if y1 < 0 {
+		y1 = 0
+	}
+	if x2 > t.width {
+		x2 = t.width
+	}
+	if y2 > t.height {
+		y2 = t.height
+	}
+	for y := y1; y < y2; y++ {
+		for x := x1; x < x2; x++ {
+			c := imageColorAt(s.img, int(x+tileX-s.x), int(y+tileY-s.y))
+			switch c.A {
+			case 0:
+				// Fully transparent, nothing to draw.
+			case 255:
+				t.pixels[y*t.stride+x] = c
+			default:
+				t.pixels[y*t.stride+x] = Blend(t.pixels[y*t.stride+x], c)
+			}
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package tilegraphics
+
+import "image/color"
+
+// Text is a single line of text, drawn with a bitmap font. It supports
+// transparency in the color, and can be rotated in steps of 90°.
+type Text struct {
+	parent   *Layer
+	x, y     int16
+	text     []rune
+	font     *Font
+	color    color.RGBA
+	rotation Rotation
+}
+
+// Rotation is a clockwise rotation in steps of 90°.
+type Rotation uint8
+
+// All supported rotations.
+const (
+	Rotate0 Rotation = iota
+	Rotate90
+	Rotate180
+	Rotate270
+)
+
+// size returns the width and height of the text before rotation.
+func (t *Text) size() (width, height int16) {
+	width = int16(len(t.text)) * t.font.advance()
+	if width > 0 {
+		// There is no spacing after the last glyph.
+		width -= int16(t.font.Spacing)
+	}
+	return width, int16(t.font.Height)
+}
+
+// boundingBox returns the bounding box of this text. The width and height are
+// swapped when the text is rotated by 90° or 270°.
+func (t *Text) boundingBox() (x1, y1, x2, y2 int16) {
+	width, height := t.size()
+	if t.rotation == Rotate90 || t.rotation == Rotate270 {
+		width, height = height, width
+	}
+	return t.x, t.y, t.x + width, t.y + height
+}
+
+// contains returns whether the given point lies within the bounding box of this
+// text.
+func (t *Text) contains(x, y int16) bool {
+	return boundingBoxContains(t, x, y)
+}
+
+// parentLayer returns the layer this object is part of.
+func (t *Text) parentLayer() *Layer {
+	return t.parent
+}
+
+// setParent changes the layer this object is part of, without invalidating
+// anything.
+func (t *Text) setParent(parent *Layer) {
+	t.parent = parent
+}
+
+// SetText replaces the displayed text.
+func (t *Text) SetText(s string) {
+	t.invalidate()
+	t.text = []rune(s)
+	t.invalidate()
+}
+
+// SetRotation rotates the text clockwise around its top left corner, while
+// keeping the top left corner of the bounding box at the same position. For
+// example, Rotate270 results in text that reads from bottom to top, as is
+// common for the labels of the vertical axis of a chart.
+func (t *Text) SetRotation(rotation Rotation) {
+	t.invalidate()
+	t.rotation = rotation % 4
+	t.invalidate()
+}
+
+// Remove removes this text from its parent layer. It must not be used anymore
+// afterwards.
+func (t *Text) Remove() {
+	t.parent.Remove(t)
+}
+
+// invalidate marks the tiles under this text as needing to be re-painted.
+func (t *Text) invalidate() {
+	x1, y1, x2, y2 := t.boundingBox()
+	r := Rectangle{parent: t.parent}
+	r.invalidate(x1, y1, x2, y2)
+}
+
+// paint draws the text to the given tile at coordinates tileX and tileY.
+func (t *Text) paint(tl *tile, tileX, tileY int16) {
+	x1, y1, x2, y2 := t.boundingBox()
+	x1 -= tileX
+	y1 -= tileY
+	x2 -= tileX
+	y2 -= tileY
+	if x1 < 0 {
+		x1 = 0
+	}
+	if y1 < 0 {
+		y1 = 0
+	}
+	if x2 > tl.width {
+		x2 = tl.width
+	}
+	if y2 > tl.height {
+		y2 = tl.height
+	}
+
+	if t.rotation != Rotate0 {
+		t.paintRotated(tl, tileX, tileY, x1, y1, x2, y2)
+		return
+	}
+
+	advance := t.font.advance()
+	for x := x1; x < x2; x++ {
+		// Find the glyph column that falls on this tile column.
+		textX := x + tileX - t.x
+		column := uint8(textX % advance)
+		if column >= t.font.Width {
+			// Spacing between glyphs.
+			continue
+		}
+		bits := t.font.glyph(t.text[textX/advance])[column]
+		for y := y1; y < y2; y++ {
+			if bits>>uint8(y+tileY-t.y)&1 == 0 {
+				continue
+			}
+			t.paintPixel(tl, x, y)
+		}
+	}
+}
+
+// paintRotated paints the part of the rotated text that falls within the given
+// area of the tile. Every pixel is mapped back to a position in the unrotated
+// text, so that glyph columns become rows (or are reversed) on the screen.
+func (t *Text) paintRotated(tl *tile, tileX, tileY, x1, y1, x2, y2 int16) {
+	width, height := t.size()
+	advance := t.font.advance()
+	for y := y1; y < y2; y++ {
+		boxY := y + tileY - t.y
+		for x := x1; x < x2; x++ {
+			boxX := x + tileX - t.x
+			var textX, textY int16
+			switch t.rotation {
+			case Rotate90:
+				textX, textY = boxY, height-1-boxX
+			case Rotate180:
+				textX, textY = width-1-boxX, height-1-boxY
+			case Rotate270:
+				textX, textY = width-1-boxY, boxX
+			}
+			column := uint8(textX % advance)
+			if column >= t.font.Width {
+				// Spacing between glyphs.
+				continue
+			}
+			bits := t.font.glyph(t.text[textX/advance])[column]
+			if bits>>uint8(textY)&1 == 0 {
+				continue
+			}
+			t.paintPixel(tl, x, y)
+		}
+	}
+}
+
+// paintPixel paints a single pixel of a glyph in the text color.
+func (t *Text) paintPixel(tl *tile, x, y int16) {
+	if t.color.A == 255 {
+		// Fast path, directly painting the color into the tile.
+		tl.pixels[y*tl.stride+x] = t.color
+	} else {
+		tl.pixels[y*tl.stride+x] = Blend(tl.pixels[y*tl.stride+x], t.color)
+	}
+}
@@ -0,0 +1,175 @@
+package tilegraphics
+
+import (
+	"image"
+	"image/color"
+)
+
+// Triangle is a filled triangle with anti-aliased edges. It supports
+// transparency in the fill color.
+type Triangle struct {
+	parent *Layer
+	points [3]image.Point
+	color  color.RGBA
+}
+
+// boundingBox returns the bounding box of this triangle.
+func (tr *Triangle) boundingBox() (x1, y1, x2, y2 int16) {
+	return pointsBoundingBox(tr.points[:])
+}
+
+// contains returns whether the given point lies within the bounding box of this
+// triangle.
+func (tr *Triangle) contains(x, y int16) bool {
+	return boundingBoxContains(tr, x, y)
+}
+
+// parentLayer returns the layer this object is part of.
+func (tr *Triangle) parentLayer() *Layer {
+	return tr.parent
+}
+
+// setParent changes the layer this object is part of, without invalidating
+// anything.
+func (tr *Triangle) setParent(parent *Layer) {
+	tr.parent = parent
+}
+
+// Move sets the new coordinates of the three corners of this triangle.
+func (tr *Triangle) Move(x1, y1, x2, y2, x3, y3 int16) {
+	tr.invalidate()
+	tr.points = [3]image.Point{{int(x1), int(y1)}, {int(x2), int(y2)}, {int(x3), int(y3)}}
+	tr.invalidate()
+}
+
+// Remove removes this triangle from its parent layer. It must not be used
+// anymore afterwards.
+func (tr *Triangle) Remove() {
+	tr.parent.Remove(tr)
+}
+
+// invalidate marks the tiles under this triangle as needing to be re-painted.
+func (tr *Triangle) invalidate() {
+	x1, y1, x2, y2 := tr.boundingBox()
+	r := Rectangle{parent: tr.parent}
+	r.invalidate(x1, y1, x2, y2)
+}
+
+// paint draws the triangle to the given tile at coordinates tileX and tileY.
+func (tr *Triangle) paint(t *tile, tileX, tileY int16) {
+	paintConvexPolygon(t, tileX, tileY, tr.points[:], tr.color)
+}
+
+// pointsBoundingBox returns the bounding box of the pixels at the given
+// coordinates.
+func pointsBoundingBox(points []image.Point) (x1, y1, x2, y2 int16) {
+	if len(points) == 0 {
+		return 0, 0, 0, 0
+	}
+	x1, y1 = int16(points[0].X), int16(points[0].Y)
+	x2, y2 = x1, y1
+	for _, p := range points[1:] {
+		if int16(p.X) < x1 {
+			x1 = int16(p.X)
+		}
+		if int16(p.Y) < y1 {
+			y1 = int16(p.Y)
+		}
+		if int16(p.X) > x2 {
+			x2 = int16(p.X)
+		}
+		if int16(p.Y) > y2 {
+			y2 = int16(p.Y)
+		}
+	}
+	return x1, y1, x2 + 1, y2 + 1
+}
+
+// paintConvexPolygon paints a convex polygon with anti-aliased edges to the
+// given tile. The corners of the polygon may be in clockwise or
+// counter-clockwise order.
+//
+// For every pixel, the distance to each edge is calculated. The pixel lies
+// inside the polygon when it lies on the inner side of all edges, and the
+// distance to the closest edge determines the coverage at the edges.
+func paintConvexPolygon(t *tile, tileX, tileY int16, points []image.Point, c color.RGBA) {
+	if len(points) < 3 {
+		return
+	}
+
+	// Determine the orientation of the polygon, using the signed area.
+	area := int64(0)
+	for i, p := range points {
+		next := points[(i+1)%len(points)]
+		area += int64(p.X)*int64(next.Y) - int64(next.X)*int64(p.Y)
+	}
+	if area == 0 {
+		// Degenerate polygon, with all points on a single line.
+		return
+	}
+
+	// Calculate the length of every edge as a Q8 fixed-point number.
+	var lengthsBuf [8]int64
+	lengths := lengthsBuf[:0]
+	for i, p := range points {
+		next := points[(i+1)%len(points)]
+		dx := int64(next.X - p.X)
+		dy := int64(next.Y - p.Y)
+		lengths = append(lengths, int64(sqrtQ8(uint32(dx*dx+dy*dy)))|1)
+	}
+
+	x1, y1, x2, y2 := pointsBoundingBox(points)
+	x1 -= tileX
+	y1 -= tileY
+	x2 -= tileX
+	y2 -= tileY
+	if x1 < 0 {
+		x1 = 0
+	}
+	if y1 < 0 {
+		y1 = 0
+	}
+	if x2 > t.width {
+		x2 = t.width
+	}
+	if y2 > t.height {
+		y2 = t.height
+	}
+	for y := y1; y < y2; y++ {
+		py := int64(y + tileY)
+		for x := x1; x < x2; x++ {
+			px := int64(x + tileX)
+
+			// Find the (Q8) distance to the closest edge, where a positive
+			// distance is inside the polygon.
+			inside := int64(1 << 30)
+			for i, p := range points {
+				next := points[(i+1)%len(points)]
+				cross := int64(next.X-p.X)*(py-int64(p.Y)) - int64(next.Y-p.Y)*(px-int64(p.X))
+				if area < 0 {
+					cross = -cross
+				}
+				dist := cross << 16 / lengths[i]
+				if dist < inside {
+					inside = dist
+				}
+			}
+
+			coverage := inside + 128
+			if coverage <= 0 {
+				continue
+			}
+			index := y*t.stride + x
+			if coverage >= 255 {
+				if c.A == 255 {
+					// Fast path, directly painting the color into the tile.
+					t.pixels[index] = c
+				} else {
+					t.pixels[index] = Blend(t.pixels[index], c)
+				}
+				continue
+			}
+			t.pixels[index] = Blend(t.pixels[index], ApplyAlpha(c, uint8(coverage)))
+		}
+	}
+}
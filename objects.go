@@ -1,7 +1,12 @@
 package tilegraphics
 
-// object is something that can be drawn on the screen.
-type object interface {
+// Element is something that can be drawn on the screen: one of the objects in
+// this package (such as a *Rectangle, *Circle or *Layer), or a *Custom for a
+// custom object added with Layer.Add. It is returned by ObjectAt and Children,
+// and can be passed to methods like Remove and BringToFront. Use a type switch
+// to find out what kind of element it is. It can only be implemented by this
+// package, see Object for custom objects.
+type Element interface {
 	// Paint draws this object on the given tile. The tile coordinates are the
 	// offsets of the tile from the coordinates of the objects relative to the
 	// parent.
@@ -13,4 +18,34 @@ type object interface {
 	// The x2 and y2 values are the coordinates that lie just outside of the
 	// bounding box, so (2, 2, 3, 4) will cover just two pixels.
 	boundingBox() (x1, y1, x2, y2 int16)
+
+	// contains returns whether the given point (in the coordinate system of
+	// the parent) lies on this object. It is used for hit testing, so it
+	// doesn't need to be pixel perfect. Objects that don't have a more precise
+	// check use boundingBoxContains.
+	contains(x, y int16) bool
+
+	// parentLayer returns the layer this object is part of, or nil for the
+	// root layer.
+	parentLayer() *Layer
+
+	// setParent changes the layer this object is part of. It is only used by
+	// Layer.Adopt, which also updates the list of objects in both layers.
+	setParent(parent *Layer)
+}
+
+// BoundingBox returns the area that the given object draws in, in the
+// coordinate system of its parent layer. Like Object.BoundingBox, the x2 and y2
+// values are the coordinates that lie just outside of the bounding box. It may
+// be larger than the area that is actually drawn, for example for a diagonal
+// line.
+func BoundingBox(obj Element) (x1, y1, x2, y2 int16) {
+	return obj.boundingBox()
+}
+
+// boundingBoxContains returns whether the given point lies within the bounding
+// box of the object.
+func boundingBoxContains(obj Element, x, y int16) bool {
+	x1, y1, x2, y2 := obj.boundingBox()
+	return x >= x1 && y >= y1 && x < x2 && y < y2
 }
@@ -0,0 +1,32 @@
+package tilegraphics
+
+import "image/color"
+
+// Commonly used fully opaque colors, and fully transparent black.
+var (
+	Black       = color.RGBA{0, 0, 0, 255}
+	White       = color.RGBA{255, 255, 255, 255}
+	Gray        = color.RGBA{128, 128, 128, 255}
+	Red         = color.RGBA{255, 0, 0, 255}
+	Green       = color.RGBA{0, 255, 0, 255}
+	Blue        = color.RGBA{0, 0, 255, 255}
+	Yellow      = color.RGBA{255, 255, 0, 255}
+	Cyan        = color.RGBA{0, 255, 255, 255}
+	Magenta     = color.RGBA{255, 0, 255, 255}
+	Transparent = color.RGBA{0, 0, 0, 0}
+)
+
+// RGB returns a fully opaque color with the given red, green and blue
+// components.
+func RGB(r, g, b uint8) color.RGBA {
+	return color.RGBA{r, g, b, 255}
+}
+
+// RGBA returns a color with the given red, green and blue components and alpha
+// value. The components are the color as it would look when fully opaque
+// (straight alpha, like in CSS or most image editors), which is converted to
+// the premultiplied form used by this package. For example, RGBA(255, 0, 0,
+// 128) is a half-transparent red. See Premultiply for details.
+func RGBA(r, g, b, a uint8) color.RGBA {
+	return premultiply(r, g, b, a)
+}
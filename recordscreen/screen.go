@@ -0,0 +1,101 @@
+// Package recordscreen wraps a screen to record all updates sent to it, for
+// testing. This makes it possible to check which areas of the screen are
+// updated by the tilegraphics engine, and how.
+package recordscreen
+
+import "image/color"
+
+// Displayer is the display interface as used by tilegraphics. It is redefined
+// here so that this package can be used in tests of the tilegraphics package
+// itself without an import cycle.
+type Displayer interface {
+	Size() (int16, int16)
+	Display() error
+	FillRectangle(x, y, width, height int16, c color.RGBA) error
+	FillRectangleWithBuffer(x, y, width, height int16, buffer []color.RGBA) error
+}
+
+// screenFiller is implemented by screens that can fill the whole screen at
+// once, see FillScreen.
+type screenFiller interface {
+	FillScreen(c color.RGBA) error
+}
+
+// Call is a single recorded update.
+type Call struct {
+	X, Y, Width, Height int16
+
+	// Buffer is true for FillRectangleWithBuffer calls and false for
+	// FillRectangle calls.
+	Buffer bool
+
+	// Screen is true for FillScreen calls, which are recorded with the size of
+	// the whole screen.
+	Screen bool
+
+	// Color is the fill color of FillRectangle calls.
+	Color color.RGBA
+}
+
+// Screen passes all updates to the wrapped screen, while recording them.
+type Screen struct {
+	display Displayer
+
+	// Calls contains all updates since the screen was created or since the
+	// last call to Reset, in order.
+	Calls []Call
+
+	// Displays is the number of calls to Display since the screen was created
+	// or since the last call to Reset.
+	Displays int
+}
+
+// NewScreen returns a new screen that records all updates before passing them
+// on to the given screen.
+func NewScreen(display Displayer) *Screen {
+	return &Screen{
+		display: display,
+	}
+}
+
+// Reset clears all recorded updates.
+func (s *Screen) Reset() {
+	s.Calls = s.Calls[:0]
+	s.Displays = 0
+}
+
+// Size returns the size of the wrapped screen.
+func (s *Screen) Size() (int16, int16) {
+	return s.display.Size()
+}
+
+// Display records the call and passes it to the wrapped screen.
+func (s *Screen) Display() error {
+	s.Displays++
+	return s.display.Display()
+}
+
+// FillRectangle records the call and passes it to the wrapped screen.
+func (s *Screen) FillRectangle(x, y, width, height int16, c color.RGBA) error {
+	s.Calls = append(s.Calls, Call{X: x, Y: y, Width: width, Height: height, Color: c})
+	return s.display.FillRectangle(x, y, width, height, c)
+}
+
+// FillScreen records the call and passes it to the wrapped screen. If the
+// wrapped screen has no FillScreen method, it is filled using FillRectangle
+// instead.
+func (s *Screen) FillScreen(c color.RGBA) error {
+	width, height := s.display.Size()
+	s.Calls = append(s.Calls, Call{Width: width, Height: height, Screen: true, Color: c})
+	if filler, ok := s.display.(screenFiller); ok {
+		return filler.FillScreen(c)
+	}
+	return s.display.FillRectangle(0, 0, width, height, c)
+}
+
+// FillRectangleWithBuffer records the call and passes it to the wrapped
+// screen.
+func (s *Screen) FillRectangleWithBuffer(x, y, width, height int16, buffer []color.RGBA) error {
+	s.Calls = append(s.Calls, Call{X: x, Y: y, Width: width, Height: height, Buffer: true})
+	return s.display.FillRectangleWithBuffer(x, y, width, height, buffer)
+}
@@ -0,0 +1,59 @@
+package recordscreen
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/aykevl/tilegraphics"
+	"github.com/aykevl/tilegraphics/imagescreen"
+)
+
+var _ tilegraphics.Displayer = (*Screen)(nil)
+
+// Check that all calls are recorded in order and passed through.
+func TestRecord(t *testing.T) {
+	display := imagescreen.NewScreen(20, 10)
+	screen := NewScreen(display)
+	red := color.RGBA{255, 0, 0, 255}
+	screen.FillRectangle(1, 2, 3, 4, red)
+	screen.FillRectangleWithBuffer(5, 6, 2, 1, []color.RGBA{red, red})
+	screen.Display()
+
+	expected := []Call{
+		{X: 1, Y: 2, Width: 3, Height: 4, Color: red},
+		{X: 5, Y: 6, Width: 2, Height: 1, Buffer: true},
+	}
+	if len(screen.Calls) != len(expected) {
+		t.Fatalf("expected %d calls, got %d: %v", len(expected), len(screen.Calls), screen.Calls)
+	}
+	for i, call := range screen.Calls {
+		if call != expected[i] {
+			t.Errorf("call %d: expected %+v, got %+v", i, expected[i], call)
+		}
+	}
+	if screen.Displays != 1 {
+		t.Errorf("expected 1 call to Display, got %d", screen.Displays)
+	}
+	if c := display.RGBAAt(3, 5); c != red {
+		t.Errorf("FillRectangle was not passed through: got %v", c)
+	}
+	if c := display.RGBAAt(6, 6); c != red {
+		t.Errorf("FillRectangleWithBuffer was not passed through: got %v", c)
+	}
+
+	// FillScreen falls back to FillRectangle, as imagescreen doesn't support
+	// it directly.
+	blue := color.RGBA{0, 0, 255, 255}
+	screen.FillScreen(blue)
+	if call := screen.Calls[len(screen.Calls)-1]; call != (Call{Width: 20, Height: 10, Screen: true, Color: blue}) {
+		t.Errorf("unexpected FillScreen call: %+v", call)
+	}
+	if c := display.RGBAAt(19, 9); c != blue {
+		t.Errorf("FillScreen was not passed through: got %v", c)
+	}
+
+	screen.Reset()
+	if len(screen.Calls) != 0 || screen.Displays != 0 {
+		t.Errorf("expected no calls after Reset, got %v and %d displays", screen.Calls, screen.Displays)
+	}
+}
@@ -0,0 +1,59 @@
+package tilegraphics
+
+import (
+	"image/color"
+	"unsafe"
+)
+
+// Displayer565 is an optional variant of the Displayer interface for displays
+// that natively work with 16-bit RGB565 colors (such as the ST7735, ST7789 and
+// ILI9341). When the display implements it, the engine converts each painted
+// tile to RGB565 itself and sends it with FillRectangleWithBuffer565 instead of
+// FillRectangleWithBuffer.
+//
+// Objects are still painted and blended in a single color.RGBA tile, as
+// transparent layers need an alpha channel. After painting, the tile is
+// converted to RGB565 in place, so no extra memory is needed. Only with
+// asynchronous flushing (see Engine.SetAsyncFlush) is the tile converted into
+// a separate RGB565 buffer, so that the next tile can be painted while it is
+// being sent. This needs tileSize*tileSize*2 bytes of extra memory instead of
+// the tileSize*tileSize*4 bytes of a second color.RGBA tile. It also halves the
+// amount of pixel data that is passed to the display, and avoids a conversion
+// buffer in the display driver.
+type Displayer565 interface {
+	Displayer
+
+	// FillRectangleWithBuffer565 fills the given rectangle with a slice of
+	// RGB565 colors. Like with FillRectangleWithBuffer, the buffer is stored in
+	// row major order, has a length of exactly width*height, and is only valid
+	// during the call.
+	FillRectangleWithBuffer565(x, y, width, height int16, buffer []uint16) error
+}
+
+// ToRGB565 converts a color to a 16-bit RGB565 value: 5 bits of red, 6 bits of
+// green and 5 bits of blue, by dropping the lowest bits of each color channel.
+// The alpha channel is ignored.
+func ToRGB565(c color.RGBA) uint16 {
+	return uint16(c.R>>3)<<11 | uint16(c.G>>2)<<5 | uint16(c.B>>3)
+}
+
+// convertRGB565 converts the given area in the top left of the tile to RGB565,
+// storing it in row major order in the buffer (which must have a length of at
+// least width*height). The buffer may be the one returned by pixels565, to
+// convert the tile in place.
+func (t *tile) convertRGB565(width, height int16, buffer []uint16) {
+	for y := int16(0); y < height; y++ {
+		row := buffer[y*width : (y+1)*width]
+		for x, c := range t.pixels[y*t.stride : y*t.stride+width] {
+			row[x] = ToRGB565(c)
+		}
+	}
+}
+
+// pixels565 returns the memory of the tile pixels as a slice of RGB565 colors.
+// Each color.RGBA pixel is twice as big as an RGB565 color, so converting the
+// tile from the start overwrites only pixels that have already been converted.
+// The tile must be painted again before it is used as a color.RGBA tile.
+func (t *tile) pixels565() []uint16 {
+	return unsafe.Slice((*uint16)(unsafe.Pointer(&t.pixels[0])), len(t.pixels)*2)
+}
@@ -0,0 +1,164 @@
+// Package rgb565screen wraps a display that natively works with 16-bit RGB565
+// colors (such as the ST7735, ST7789 and ILI9341) to implement the Displayer
+// interface as required by tilegraphics.
+package rgb565screen
+
+import (
+	"image/color"
+
+	"github.com/aykevl/tilegraphics"
+)
+
+// Device is a display that accepts RGB565 pixel data.
+type Device interface {
+	// Size returns the display size in pixels.
+	Size() (int16, int16)
+
+	// Display sends the last updates to the screen, if needed.
+	Display() error
+
+	// DrawRGBBitmap draws the given RGB565 buffer to the screen at the given
+	// coordinates. The buffer is stored in row major order.
+	DrawRGBBitmap(x, y int16, data []uint16, width, height int16) error
+}
+
+// fillRows is the number of rows that FillRectangle sends to the device at a
+// time, unless the scratch buffer is already big enough for more. It covers a
+// whole tile of the default size in a single update.
+const fillRows = 8
+
+// Screen converts all colors to RGB565 before sending them to the device.
+type Screen struct {
+	device Device
+	dither bool
+
+	// buffer is a scratch buffer that is reused for every conversion, to avoid
+	// allocating memory on each update.
+	buffer []uint16
+}
+
+// NewScreen returns a new screen that wraps the given 16-bit device.
+func NewScreen(device Device) *Screen {
+	return &Screen{
+		device: device,
+	}
+}
+
+// SetDither enables or disables ordered dithering. When enabled, the lower bits
+// that are lost in the conversion to RGB565 are approximated with a 4x4 Bayer
+// pattern that is aligned to the screen, which greatly reduces banding in
+// gradients. It is disabled by default as it makes every update slower. It
+// only affects updates sent after this call.
+func (s *Screen) SetDither(dither bool) {
+	s.dither = dither
+}
+
+// Size returns the size of the underlying device.
+func (s *Screen) Size() (int16, int16) {
+	return s.device.Size()
+}
+
+// Display sends the last updates to the underlying device.
+func (s *Screen) Display() error {
+	return s.device.Display()
+}
+
+// FillRectangle fills the given rectangle with the given color.
+func (s *Screen) FillRectangle(x, y, width, height int16, c color.RGBA) error {
+	if width <= 0 || height <= 0 {
+		return nil
+	}
+
+	// Send the rectangle in blocks of rows, to avoid a big scratch buffer for
+	// large rectangles. Use a bigger block when the scratch buffer has already
+	// grown that large.
+	rows := int16(len(s.buffer) / int(width))
+	if rows < fillRows {
+		rows = fillRows
+	}
+	if rows > height {
+		rows = height
+	}
+	buffer := s.getBuffer(int(width) * int(rows))
+	pixel := ToRGB565(c)
+	for i := range buffer {
+		buffer[i] = pixel
+	}
+	for rowY := y; rowY < y+height; rowY += rows {
+		if rowY+rows > y+height {
+			rows = y + height - rowY
+			buffer = buffer[:int(width)*int(rows)]
+		}
+		if s.dither {
+			// The pattern depends on the position on the screen, so it
+			// needs to be recalculated for every block.
+			for i := range buffer {
+				buffer[i] = ditherRGB565(c, x+int16(i%int(width)), rowY+int16(i/int(width)))
+			}
+		}
+		err := s.device.DrawRGBBitmap(x, rowY, buffer, width, rows)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FillRectangleWithBuffer converts the given buffer to RGB565 and sends it to
+// the device. The buffer must be in row major order.
+func (s *Screen) FillRectangleWithBuffer(x, y, width, height int16, buffer []color.RGBA) error {
+	converted := s.getBuffer(len(buffer))
+	if s.dither && width > 0 {
+		for i, c := range buffer {
+			converted[i] = ditherRGB565(c, x+int16(i%int(width)), y+int16(i/int(width)))
+		}
+	} else {
+		for i, c := range buffer {
+			converted[i] = ToRGB565(c)
+		}
+	}
+	return s.device.DrawRGBBitmap(x, y, converted, width, height)
+}
+
+// getBuffer returns the scratch buffer with the given length, growing it when
+// necessary.
+func (s *Screen) getBuffer(length int) []uint16 {
+	if len(s.buffer) < length {
+		s.buffer = make([]uint16, length)
+	}
+	return s.buffer[:length]
+}
+
+// ToRGB565 converts a color to a 16-bit RGB565 value: 5 bits of red, 6 bits of
+// green and 5 bits of blue. The alpha channel is ignored. It is the same as
+// tilegraphics.ToRGB565.
+func ToRGB565(c color.RGBA) uint16 {
+	return tilegraphics.ToRGB565(c)
+}
+
+// bayer4 is the 4x4 Bayer matrix used for ordered dithering.
+var bayer4 = [4][4]uint8{
+	{0, 8, 2, 10},
+	{12, 4, 14, 6},
+	{3, 11, 1, 9},
+	{15, 7, 13, 5},
+}
+
+// ditherRGB565 converts a color to RGB565 like ToRGB565, but first adds a
+// threshold from the Bayer matrix at the given screen position to each
+// channel. Red and blue lose 3 bits and green loses 2 bits, so the threshold is
+// scaled to the range 0-7 and 0-3 respectively.
+func ditherRGB565(c color.RGBA, x, y int16) uint16 {
+	threshold := bayer4[y&3][x&3]
+	return uint16(addSaturated(c.R, threshold>>1)>>3)<<11 |
+		uint16(addSaturated(c.G, threshold>>2)>>2)<<5 |
+		uint16(addSaturated(c.B, threshold>>1)>>3)
+}
+
+// addSaturated returns a+b, limited to 255.
+func addSaturated(a, b uint8) uint8 {
+	if a > 255-b {
+		return 255
+	}
+	return a + b
+}
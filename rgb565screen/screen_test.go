@@ -0,0 +1,186 @@
+package rgb565screen
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/aykevl/tilegraphics"
+)
+
+// Make sure Screen can be used by tilegraphics.
+var _ tilegraphics.Displayer = (*Screen)(nil)
+
+// testDevice is an in-memory RGB565 display.
+type testDevice struct {
+	width, height int16
+	pixels        []uint16
+	displayed     bool
+	draws         int // number of DrawRGBBitmap calls
+}
+
+func newTestDevice(width, height int16) *testDevice {
+	return &testDevice{
+		width:  width,
+		height: height,
+		pixels: make([]uint16, int(width)*int(height)),
+	}
+}
+
+func (d *testDevice) Size() (int16, int16) {
+	return d.width, d.height
+}
+
+func (d *testDevice) Display() error {
+	d.displayed = true
+	return nil
+}
+
+func (d *testDevice) DrawRGBBitmap(x, y int16, data []uint16, width, height int16) error {
+	d.draws++
+	for bufferY := int16(0); bufferY < height; bufferY++ {
+		for bufferX := int16(0); bufferX < width; bufferX++ {
+			d.pixels[int(y+bufferY)*int(d.width)+int(x+bufferX)] = data[int(bufferY)*int(width)+int(bufferX)]
+		}
+	}
+	return nil
+}
+
+func (d *testDevice) at(x, y int16) uint16 {
+	return d.pixels[int(y)*int(d.width)+int(x)]
+}
+
+func TestToRGB565(t *testing.T) {
+	testCases := []struct {
+		c        color.RGBA
+		expected uint16
+	}{
+		{color.RGBA{0, 0, 0, 255}, 0x0000},
+		{color.RGBA{255, 255, 255, 255}, 0xffff},
+		{color.RGBA{255, 0, 0, 255}, 0xf800},
+		{color.RGBA{0, 255, 0, 255}, 0x07e0},
+		{color.RGBA{0, 0, 255, 255}, 0x001f},
+		{color.RGBA{0x80, 0x80, 0x80, 255}, 0x8410},
+		{color.RGBA{0x12, 0x34, 0x56, 255}, 0x11aa},
+	}
+	for _, tc := range testCases {
+		if result := ToRGB565(tc.c); result != tc.expected {
+			t.Errorf("ToRGB565(%v): expected 0x%04x, got 0x%04x", tc.c, tc.expected, result)
+		}
+	}
+}
+
+func TestScreen(t *testing.T) {
+	device := newTestDevice(20, 10)
+	screen := NewScreen(device)
+	if width, height := screen.Size(); width != 20 || height != 10 {
+		t.Errorf("unexpected size: %dx%d", width, height)
+	}
+
+	// Fill the whole screen, and then a smaller rectangle with a buffer.
+	screen.FillRectangle(0, 0, 20, 10, color.RGBA{255, 0, 0, 255})
+	buffer := []color.RGBA{
+		{0, 255, 0, 255}, {0, 0, 255, 255}, {255, 255, 255, 255},
+		{0, 0, 0, 255}, {0x80, 0x80, 0x80, 255}, {0x12, 0x34, 0x56, 255},
+	}
+	screen.FillRectangleWithBuffer(5, 3, 3, 2, buffer)
+	screen.Display()
+
+	for y := int16(0); y < 10; y++ {
+		for x := int16(0); x < 20; x++ {
+			expected := uint16(0xf800)
+			if x >= 5 && x < 8 && y >= 3 && y < 5 {
+				expected = ToRGB565(buffer[(y-3)*3+(x-5)])
+			}
+			if pixel := device.at(x, y); pixel != expected {
+				t.Errorf("pixel at X=%d Y=%d: expected 0x%04x, got 0x%04x", x, y, expected, pixel)
+			}
+		}
+	}
+	if !device.displayed {
+		t.Error("Display was not passed through to the device")
+	}
+}
+
+// A uniform rectangle should be sent in as few blocks as possible, even when
+// the scratch buffer hasn't been used yet.
+func TestFillRectangleBlocks(t *testing.T) {
+	device := newTestDevice(32, 32)
+	screen := NewScreen(device)
+	screen.FillRectangle(0, 0, 8, 8, color.RGBA{255, 0, 0, 255})
+	if device.draws != 1 {
+		t.Errorf("expected 1 update for an 8x8 rectangle, got %d", device.draws)
+	}
+
+	// Larger rectangles are split in blocks of rows.
+	device.draws = 0
+	screen.FillRectangle(0, 0, 32, 20, color.RGBA{0, 0, 255, 255})
+	if device.draws != 3 {
+		t.Errorf("expected 3 updates for a 32x20 rectangle, got %d", device.draws)
+	}
+	for y := int16(0); y < 32; y++ {
+		for x := int16(0); x < 32; x++ {
+			expected := uint16(0)
+			if y < 20 {
+				expected = 0x001f
+			}
+			if pixel := device.at(x, y); pixel != expected {
+				t.Errorf("pixel at X=%d Y=%d: expected 0x%04x, got 0x%04x", x, y, expected, pixel)
+			}
+		}
+	}
+}
+
+// Render a subtle gradient with and without dithering. Without dithering, the
+// gradient only has a few bands of the same color. With dithering, the average
+// over each column more closely follows the gradient.
+func TestDither(t *testing.T) {
+	render := func(dither bool) *testDevice {
+		device := newTestDevice(64, 4)
+		screen := NewScreen(device)
+		screen.SetDither(dither)
+		engine := tilegraphics.NewEngine(screen)
+		engine.NewGradientRectangle(0, 0, 64, 4, color.RGBA{0, 0, 0, 255}, color.RGBA{32, 0, 0, 255}, false)
+		engine.Display()
+		return device
+	}
+
+	// Count the number of distinct values of the red channel, summed over
+	// each column.
+	distinct := func(device *testDevice) int {
+		values := make(map[int]struct{})
+		for x := int16(0); x < device.width; x++ {
+			sum := 0
+			for y := int16(0); y < device.height; y++ {
+				sum += int(device.at(x, y) >> 11)
+			}
+			values[sum] = struct{}{}
+		}
+		return len(values)
+	}
+
+	plain := distinct(render(false))
+	dithered := distinct(render(true))
+	if dithered <= plain {
+		t.Errorf("expected more distinct values with dithering, got %d with and %d without", dithered, plain)
+	}
+
+	// Dithering must not change colors that can be represented exactly.
+	device := newTestDevice(4, 4)
+	screen := NewScreen(device)
+	screen.SetDither(true)
+	screen.FillRectangle(0, 0, 4, 4, color.RGBA{255, 0, 0, 255})
+	screen.FillRectangleWithBuffer(1, 1, 2, 1, []color.RGBA{{0, 0, 0, 255}, {255, 255, 255, 255}})
+	for y := int16(0); y < 4; y++ {
+		for x := int16(0); x < 4; x++ {
+			expected := uint16(0xf800)
+			if y == 1 && x == 1 {
+				expected = 0x0000
+			} else if y == 1 && x == 2 {
+				expected = 0xffff
+			}
+			if pixel := device.at(x, y); pixel != expected {
+				t.Errorf("pixel at X=%d Y=%d: expected 0x%04x, got 0x%04x", x, y, expected, pixel)
+			}
+		}
+	}
+}
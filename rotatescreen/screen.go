@@ -0,0 +1,112 @@
+// Package rotatescreen wraps a Displayer to rotate everything that is drawn on
+// it, for displays that are mounted sideways or upside down.
+package rotatescreen
+
+import (
+	"image/color"
+
+	"github.com/aykevl/tilegraphics"
+)
+
+// Rotation is the clockwise rotation of the image on the display.
+type Rotation uint8
+
+// All supported rotations.
+const (
+	Rotate0 Rotation = iota
+	Rotate90
+	Rotate180
+	Rotate270
+)
+
+// Screen rotates all updates before sending them to the wrapped display.
+type Screen struct {
+	display  tilegraphics.Displayer
+	rotation Rotation
+
+	// buffer is a scratch buffer used to rotate pixel buffers, reused for
+	// every update to avoid allocating memory.
+	buffer []color.RGBA
+}
+
+// NewScreen returns a new screen that shows everything drawn on it rotated
+// clockwise by the given rotation on the wrapped display.
+func NewScreen(display tilegraphics.Displayer, rotation Rotation) *Screen {
+	return &Screen{
+		display:  display,
+		rotation: rotation % 4,
+	}
+}
+
+// Size returns the size of the display after rotation: width and height are
+// swapped when rotating by 90 or 270 degrees.
+func (s *Screen) Size() (int16, int16) {
+	width, height := s.display.Size()
+	if s.rotation == Rotate90 || s.rotation == Rotate270 {
+		return height, width
+	}
+	return width, height
+}
+
+// Display sends the last updates to the wrapped display.
+func (s *Screen) Display() error {
+	return s.display.Display()
+}
+
+// FillRectangle fills the given rectangle with the given color.
+func (s *Screen) FillRectangle(x, y, width, height int16, c color.RGBA) error {
+	x, y, width, height = s.rotateRect(x, y, width, height)
+	return s.display.FillRectangle(x, y, width, height, c)
+}
+
+// FillRectangleWithBuffer fills the given rectangle with a slice of colors,
+// rotating the buffer as needed. The buffer must be in row major order.
+func (s *Screen) FillRectangleWithBuffer(x, y, width, height int16, buffer []color.RGBA) error {
+	if s.rotation == Rotate0 {
+		return s.display.FillRectangleWithBuffer(x, y, width, height, buffer)
+	}
+	if len(buffer) != int(width)*int(height) {
+		// Let the wrapped display report the error.
+		return s.display.FillRectangleWithBuffer(x, y, width, height, buffer)
+	}
+
+	if len(s.buffer) < len(buffer) {
+		s.buffer = make([]color.RGBA, len(buffer))
+	}
+	rotated := s.buffer[:len(buffer)]
+	w := int(width)
+	h := int(height)
+	for by := 0; by < h; by++ {
+		for bx := 0; bx < w; bx++ {
+			var index int
+			switch s.rotation {
+			case Rotate90:
+				// The rotated buffer is h wide and w high.
+				index = bx*h + (h - 1 - by)
+			case Rotate180:
+				index = (h-1-by)*w + (w - 1 - bx)
+			case Rotate270:
+				index = (w-1-bx)*h + by
+			}
+			rotated[index] = buffer[by*w+bx]
+		}
+	}
+	x, y, width, height = s.rotateRect(x, y, width, height)
+	return s.display.FillRectangleWithBuffer(x, y, width, height, rotated)
+}
+
+// rotateRect converts a rectangle in the rotated coordinate system to the
+// coordinate system of the wrapped display.
+func (s *Screen) rotateRect(x, y, width, height int16) (int16, int16, int16, int16) {
+	displayWidth, displayHeight := s.display.Size()
+	switch s.rotation {
+	case Rotate90:
+		return displayWidth - (y + height), x, height, width
+	case Rotate180:
+		return displayWidth - (x + width), displayHeight - (y + height), width, height
+	case Rotate270:
+		return y, displayHeight - (x + width), height, width
+	default:
+		return x, y, width, height
+	}
+}
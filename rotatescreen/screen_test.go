@@ -0,0 +1,66 @@
+package rotatescreen
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/aykevl/tilegraphics/imagescreen"
+)
+
+// Draw an asymmetric pattern with all rotations, and check whether every pixel
+// ends up at the expected position on the underlying screen.
+func TestRotation(t *testing.T) {
+	const displayWidth = 30
+	const displayHeight = 20
+	background := color.RGBA{0, 0, 0, 255}
+	fill := color.RGBA{255, 0, 0, 255}
+
+	// The pattern is a 3x2 buffer with distinct colors, placed next to a
+	// filled rectangle.
+	buffer := []color.RGBA{
+		{1, 0, 0, 255}, {2, 0, 0, 255}, {3, 0, 0, 255},
+		{4, 0, 0, 255}, {5, 0, 0, 255}, {6, 0, 0, 255},
+	}
+
+	for _, rotation := range []Rotation{Rotate0, Rotate90, Rotate180, Rotate270} {
+		display := imagescreen.NewScreen(displayWidth, displayHeight)
+		screen := NewScreen(display, rotation)
+		width, height := screen.Size()
+		if rotation == Rotate90 || rotation == Rotate270 {
+			if width != displayHeight || height != displayWidth {
+				t.Errorf("rotation %d: unexpected size %dx%d", rotation, width, height)
+			}
+		} else if width != displayWidth || height != displayHeight {
+			t.Errorf("rotation %d: unexpected size %dx%d", rotation, width, height)
+		}
+
+		screen.FillRectangle(0, 0, width, height, background)
+		screen.FillRectangle(1, 2, 4, 3, fill)
+		screen.FillRectangleWithBuffer(6, 7, 3, 2, buffer)
+
+		// Check every pixel in the rotated coordinate system.
+		for y := 0; y < int(height); y++ {
+			for x := 0; x < int(width); x++ {
+				expected := background
+				if x >= 1 && x < 5 && y >= 2 && y < 5 {
+					expected = fill
+				}
+				if x >= 6 && x < 9 && y >= 7 && y < 9 {
+					expected = buffer[(y-7)*3+(x-6)]
+				}
+				displayX, displayY := x, y
+				switch rotation {
+				case Rotate90:
+					displayX, displayY = displayWidth-1-y, x
+				case Rotate180:
+					displayX, displayY = displayWidth-1-x, displayHeight-1-y
+				case Rotate270:
+					displayX, displayY = y, displayHeight-1-x
+				}
+				if c := display.RGBAAt(displayX, displayY); c != expected {
+					t.Errorf("rotation %d: pixel X=%d Y=%d (display X=%d Y=%d): expected %v, got %v", rotation, x, y, displayX, displayY, expected, c)
+				}
+			}
+		}
+	}
+}
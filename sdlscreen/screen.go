@@ -106,7 +106,7 @@ func (s *Screen) FillRectangleWithBuffer(x, y, width, height int16, buffer []col
 	}
 	for bufferX := int16(0); bufferX < width; bufferX++ {
 		for bufferY := int16(0); bufferY < height; bufferY++ {
-			s.SetPixel(bufferX+x, bufferY+y, buffer[bufferX+bufferY*height])
+			s.SetPixel(bufferX+x, bufferY+y, buffer[bufferX+bufferY*width])
 		}
 	}
 	return nil
@@ -118,7 +118,7 @@ func (s *Screen) FillRectangleWithBuffer(x, y, width, height int16, buffer []col
 func (s *Screen) SetPixel(x, y int16, c color.RGBA) {
 	surfaceX := int(x)
 	surfaceY := int(y)
-	if surfaceX >= 0 && surfaceY >= 0 && surfaceX < int(s.surface.W) || surfaceY < int(s.surface.H) {
+	if surfaceX >= 0 && surfaceY >= 0 && surfaceX < int(s.surface.W) && surfaceY < int(s.surface.H) {
 		s.surface.Set(surfaceX, surfaceY, c)
 	}
 }
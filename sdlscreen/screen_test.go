@@ -0,0 +1,48 @@
+package sdlscreen
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// Draw a buffer that extends past the bottom right edge of the surface, and
+// check that only the pixels within the surface are changed.
+func TestBufferOutOfBounds(t *testing.T) {
+	const width, height = 10, 8
+	surface, err := sdl.CreateRGBSurface(0, width, height, 32, 0x00ff0000, 0x0000ff00, 0x000000ff, 0xff000000)
+	if err != nil {
+		t.Fatal("could not create surface:", err)
+	}
+	defer surface.Free()
+	s := &Screen{surface: surface}
+
+	background := color.RGBA{0, 0, 0, 255}
+	s.FillRectangle(0, 0, width, height, background)
+
+	// A non-square buffer, so that swapped width and height show up in the
+	// result.
+	const bufferX, bufferY, bufferWidth, bufferHeight = 6, 5, 7, 4
+	buffer := make([]color.RGBA, bufferWidth*bufferHeight)
+	for i := range buffer {
+		buffer[i] = color.RGBA{uint8(i + 1), 0, 0, 255}
+	}
+	s.FillRectangleWithBuffer(bufferX, bufferY, bufferWidth, bufferHeight, buffer)
+	s.SetPixel(-1, 3, color.RGBA{255, 255, 255, 255})
+	s.SetPixel(3, height, color.RGBA{255, 255, 255, 255})
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			expected := background
+			if x >= bufferX && y >= bufferY {
+				expected = buffer[(y-bufferY)*bufferWidth+(x-bufferX)]
+			}
+			r, g, b, a := surface.At(x, y).RGBA()
+			c := color.RGBA{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8), uint8(a >> 8)}
+			if c != expected {
+				t.Errorf("pixel mismatch at X=%d Y=%d: expected %v, got %v", x, y, expected, c)
+			}
+		}
+	}
+}
@@ -5,10 +5,11 @@ package testscreen
 import (
 	"machine"
 
+	"github.com/aykevl/tilegraphics/driverscreen"
 	"tinygo.org/x/drivers/st7735"
 )
 
-func NewScreen(name string) *st7735.Device {
+func NewScreen(name string) *driverscreen.Screen {
 	machine.SPI0.Configure(machine.SPIConfig{
 		SCK:       29,
 		MOSI:      30,
@@ -22,5 +23,5 @@ func NewScreen(name string) *st7735.Device {
 		RowOffset:    -1,
 		ColumnOffset: -1,
 	})
-	return &screen
+	return driverscreen.NewScreen(&screen)
 }